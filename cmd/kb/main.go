@@ -3,20 +3,31 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
+	"sync"
 	"syscall"
 	"text/tabwriter"
+	"time"
 
+	"github.com/esakat/markdown-kb/internal/charset"
 	"github.com/esakat/markdown-kb/internal/config"
+	gitpkg "github.com/esakat/markdown-kb/internal/git"
+	"github.com/esakat/markdown-kb/internal/ignore"
 	"github.com/esakat/markdown-kb/internal/index"
-	"github.com/esakat/markdown-kb/internal/parser"
+	"github.com/esakat/markdown-kb/internal/llms"
+	"github.com/esakat/markdown-kb/internal/mcp"
+	"github.com/esakat/markdown-kb/internal/progress"
+	"github.com/esakat/markdown-kb/internal/revision"
 	"github.com/esakat/markdown-kb/internal/scanner"
 	"github.com/esakat/markdown-kb/internal/server"
 	"github.com/esakat/markdown-kb/internal/watcher"
@@ -34,6 +45,8 @@ func main() {
 
 	rootCmd.AddCommand(newServeCmd())
 	rootCmd.AddCommand(newIndexCmd())
+	rootCmd.AddCommand(newMCPCmd())
+	rootCmd.AddCommand(newExportCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -54,30 +67,279 @@ func validateRootDir(rootDir string) error {
 	return nil
 }
 
-func scanAndIndex(rootDir string) (*index.Store, []scanner.Document, error) {
-	docs, err := scanner.Scan(rootDir)
+// newIgnoreMatcher builds the ignore rules shared by scanning and watching.
+func newIgnoreMatcher(rootDir string, repoCfg config.RepoConfig) *ignore.Matcher {
+	return ignore.New(rootDir, repoCfg.Include, repoCfg.Exclude)
+}
+
+// symlinkRoots resolves the configured symlink roots against rootDir.
+func symlinkRoots(rootDir string, repoCfg config.RepoConfig) []string {
+	roots := make([]string, 0, len(repoCfg.SymlinkRoots))
+	for _, r := range repoCfg.SymlinkRoots {
+		if !filepath.IsAbs(r) {
+			r = filepath.Join(rootDir, r)
+		}
+		if abs, err := filepath.Abs(r); err == nil {
+			roots = append(roots, abs)
+		}
+	}
+	return roots
+}
+
+// defaultBatchWindow is how long file changes are collected before they are
+// indexed together.
+const defaultBatchWindow = 250 * time.Millisecond
+
+// watchFlags holds the --watch, --poll-interval and --batch-window flags.
+type watchFlags struct {
+	mode     string
+	interval time.Duration
+	batch    time.Duration
+}
+
+func (f *watchFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.mode, "watch", "fsnotify", "How to detect file changes: fsnotify (falls back to poll if it can't start) or poll")
+	cmd.Flags().DurationVar(&f.interval, "poll-interval", watcher.DefaultPollInterval, "Rescan interval for --watch=poll")
+	cmd.Flags().DurationVar(&f.batch, "batch-window", defaultBatchWindow, "Collect file changes for this long and index them in one batch (0 to disable)")
+}
+
+func (f watchFlags) validate() error {
+	if f.mode != "fsnotify" && f.mode != "poll" {
+		return fmt.Errorf("invalid --watch %q: want fsnotify or poll", f.mode)
+	}
+	if f.interval <= 0 {
+		return fmt.Errorf("invalid --poll-interval %s: must be positive", f.interval)
+	}
+	if f.batch < 0 {
+		return fmt.Errorf("invalid --batch-window %s: must not be negative", f.batch)
+	}
+	return nil
+}
+
+// watcherOptions builds the watcher settings matching scanDocuments.
+func watcherOptions(rootDir string, repoCfg config.RepoConfig, watch watchFlags) watcher.Options {
+	return watcher.Options{
+		Ignore:         newIgnoreMatcher(rootDir, repoCfg),
+		Extensions:     repoCfg.Extensions,
+		FollowSymlinks: repoCfg.FollowSymlinks,
+		SymlinkRoots:   symlinkRoots(rootDir, repoCfg),
+		Poll:           watch.mode == "poll",
+		PollInterval:   watch.interval,
+		OnFallback: func(err error) {
+			fmt.Fprintf(os.Stderr, "Warning: fsnotify failed for %q (%v); polling every %s instead\n", rootDir, err, watch.interval)
+		},
+	}
+}
+
+// scanDocuments scans rootDir with the repo's ignore and extension
+// settings, reporting progress and files that can't be loaded on stderr.
+// onProgress, if non-nil, also receives scan progress.
+func scanDocuments(rootDir string, repoCfg config.RepoConfig, onProgress func(done, total int)) ([]scanner.Document, []scanner.Diagnostic, error) {
+	report := progress.New(os.Stderr, "Scanning")
+	var diags []scanner.Diagnostic
+	docs, err := scanner.ScanWithOptions(rootDir, scanner.Options{
+		Ignore:         newIgnoreMatcher(rootDir, repoCfg),
+		Extensions:     repoCfg.Extensions,
+		FollowSymlinks: repoCfg.FollowSymlinks,
+		SymlinkRoots:   symlinkRoots(rootDir, repoCfg),
+		Progress: func(done, total int) {
+			report.Update(done, total)
+			if onProgress != nil {
+				onProgress(done, total)
+			}
+		},
+		OnDiagnostic: func(d scanner.Diagnostic) {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %q: %s\n", d.Path, d.Message)
+			diags = append(diags, d)
+		},
+	})
 	if err != nil {
 		return nil, nil, fmt.Errorf("scanning directory: %w", err)
 	}
+	report.Finish(len(docs))
 
 	if len(docs) == 0 {
 		fmt.Fprintf(os.Stderr, "Warning: no markdown files found in %q\n", rootDir)
 	}
+	return docs, diags, nil
+}
 
+// newStore creates an empty in-memory index configured from repoCfg.
+func newStore(repoCfg config.RepoConfig) (*index.Store, error) {
 	store, err := index.New()
 	if err != nil {
-		return nil, nil, fmt.Errorf("creating index: %w", err)
+		return nil, fmt.Errorf("creating index: %w", err)
 	}
+	store.SetChunkTokens(repoCfg.Chunks.MaxTokens)
+	store.SetExtensions(repoCfg.Extensions)
+	return store, nil
+}
 
-	for _, doc := range docs {
-		if err := store.IndexDocument(doc); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to index %q: %v\n", doc.RelPath, err)
+// indexDocuments bulk-loads docs into store and records diags, reporting
+// progress on stderr. onProgress, if non-nil, also receives indexing
+// progress.
+func indexDocuments(store *index.Store, docs []scanner.Document, diags []scanner.Diagnostic, onProgress func(done, total int)) {
+	report := progress.New(os.Stderr, "Indexing")
+	err := store.IndexBatch(docs, func(done, total int) {
+		report.Update(done, total)
+		if onProgress != nil {
+			onProgress(done, total)
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to index some documents:\n%v\n", err)
+	}
+	report.Finish(len(docs))
+
+	for _, d := range diags {
+		if err := store.SetDiagnostic(d); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to record diagnostic for %q: %v\n", d.Path, err)
 		}
 	}
+}
+
+func scanAndIndex(rootDir string, repoCfg config.RepoConfig) (*index.Store, []scanner.Document, error) {
+	docs, diags, err := scanDocuments(rootDir, repoCfg, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	store, err := newStore(repoCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	indexDocuments(store, docs, diags, nil)
 
 	return store, docs, nil
 }
 
+// deferredChanges holds watcher events that arrive while the initial
+// index is being built and replays them once it is done, so that a stale
+// scan result can't overwrite a newer edit.
+type deferredChanges struct {
+	handle func(e watcher.Event)
+
+	mu      sync.Mutex
+	ready   bool
+	pending []watcher.Event
+	queued  map[watcher.Event]bool
+}
+
+func newDeferredChanges(handle func(e watcher.Event)) *deferredChanges {
+	return &deferredChanges{handle: handle, queued: make(map[watcher.Event]bool)}
+}
+
+// Handle processes e immediately once released, or queues it.
+func (d *deferredChanges) Handle(e watcher.Event) {
+	d.mu.Lock()
+	if !d.ready {
+		if !d.queued[e] {
+			d.queued[e] = true
+			d.pending = append(d.pending, e)
+		}
+		d.mu.Unlock()
+		return
+	}
+	d.mu.Unlock()
+	d.handle(e)
+}
+
+// Release replays queued events in arrival order and lets later events
+// through directly.
+func (d *deferredChanges) Release() {
+	for {
+		d.mu.Lock()
+		batch := d.pending
+		d.pending = nil
+		d.queued = make(map[watcher.Event]bool)
+		if len(batch) == 0 {
+			d.ready = true
+			d.mu.Unlock()
+			return
+		}
+		d.mu.Unlock()
+
+		for _, e := range batch {
+			d.handle(e)
+		}
+	}
+}
+
+// batchedChange is a document changed within a batching window.
+type batchedChange struct {
+	path    string // relative to the root
+	created bool   // the first event in the window created the file
+}
+
+// changeBatcher collects the file events that arrive within a window of
+// the first one and hands them over as one batch, so that a formatter or
+// editor touching many files causes one index transaction and one
+// broadcast. Other events flush the pending batch first and are passed on
+// as they are. A zero window disables batching.
+type changeBatcher struct {
+	window time.Duration
+	handle func(e watcher.Event)
+	flush  func(batch []batchedChange)
+
+	run   sync.Mutex // serializes handle and flush
+	mu    sync.Mutex
+	batch []batchedChange
+	seen  map[string]bool
+	timer *time.Timer
+}
+
+func newChangeBatcher(window time.Duration, handle func(e watcher.Event), flush func(batch []batchedChange)) *changeBatcher {
+	return &changeBatcher{window: window, handle: handle, flush: flush, seen: make(map[string]bool)}
+}
+
+// Handle adds a file event to the current batch, or flushes the batch and
+// processes any other event.
+func (b *changeBatcher) Handle(e watcher.Event) {
+	if b.window > 0 && (e.Op == watcher.FileChanged || e.Op == watcher.FileCreated || e.Op == watcher.FileRemoved) {
+		b.mu.Lock()
+		if !b.seen[e.Path] {
+			b.seen[e.Path] = true
+			b.batch = append(b.batch, batchedChange{path: e.Path, created: e.Op == watcher.FileCreated})
+		}
+		if b.timer == nil {
+			b.timer = time.AfterFunc(b.window, b.Flush)
+		}
+		b.mu.Unlock()
+		return
+	}
+	b.Flush()
+	b.run.Lock()
+	defer b.run.Unlock()
+	b.handle(e)
+}
+
+// Flush processes the pending batch now.
+func (b *changeBatcher) Flush() {
+	b.run.Lock()
+	defer b.run.Unlock()
+	if batch := b.take(); len(batch) > 0 {
+		b.flush(batch)
+	}
+}
+
+// Stop drops the pending batch.
+func (b *changeBatcher) Stop() {
+	b.take()
+}
+
+func (b *changeBatcher) take() []batchedChange {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	batch := b.batch
+	b.batch = nil
+	b.seen = make(map[string]bool)
+	return batch
+}
+
 func openBrowser(url string) {
 	var cmd *exec.Cmd
 	switch runtime.GOOS {
@@ -95,61 +357,84 @@ func openBrowser(url string) {
 
 func newServeCmd() *cobra.Command {
 	var cfg config.ServeConfig
+	var globalConfig, rev string
+	var global bool
+	var watch watchFlags
 
 	cmd := &cobra.Command{
-		Use:   "serve [path]",
+		Use:   "serve [path...]",
 		Short: "Start the web server",
-		Args:  cobra.MaximumNArgs(1),
+		Long: "Start the web server for one or more repositories. Without paths, the working\n" +
+			"directory is served, or with --global or --config the repositories listed under\n" +
+			"repos: in the global config.",
+		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) > 0 {
-				cfg.RootDir = args[0]
-			}
-			if cfg.RootDir == "" {
-				wd, err := os.Getwd()
-				if err != nil {
-					return fmt.Errorf("getting working directory: %w", err)
-				}
-				cfg.RootDir = wd
-			}
-
-			if err := validateRootDir(cfg.RootDir); err != nil {
+			if err := watch.validate(); err != nil {
 				return err
 			}
-
-			// Load per-repo config (.markdown-kb.yml)
-			repoCfg, err := config.LoadRepoConfig(cfg.RootDir)
+			sources, err := repoSources(args, globalConfig, global)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to load .markdown-kb.yml: %v\n", err)
-			}
-			// CLI --title flag overrides config file
-			if titleFlag, _ := cmd.Flags().GetString("title"); titleFlag != "" {
-				repoCfg.Title = titleFlag
-			}
-			if themeFlag, _ := cmd.Flags().GetString("theme"); themeFlag != "" {
-				repoCfg.Theme = themeFlag
-			}
-			if fontFlag, _ := cmd.Flags().GetString("font"); fontFlag != "" {
-				repoCfg.Font = fontFlag
+				return err
 			}
-			cfg.Repo = repoCfg
 
-			store, docs, err := scanAndIndex(cfg.RootDir)
-			if err != nil {
-				return err
+			// Load per-repo config (.markdown-kb.yml). CLI flags override
+			// config files, also when they are reloaded. A title only makes
+			// sense for a single repository.
+			loadConfig := func(rootDir string) (config.RepoConfig, error) {
+				repoCfg, err := config.LoadValidRepoConfig(rootDir)
+				if titleFlag, _ := cmd.Flags().GetString("title"); titleFlag != "" && len(sources) == 1 {
+					repoCfg.Title = titleFlag
+				}
+				if themeFlag, _ := cmd.Flags().GetString("theme"); themeFlag != "" {
+					repoCfg.Theme = themeFlag
+				}
+				if fontFlag, _ := cmd.Flags().GetString("font"); fontFlag != "" {
+					repoCfg.Font = fontFlag
+				}
+				return repoCfg, err
 			}
-			defer store.Close()
 
-			srv := server.New(cfg, store)
+			var repos []server.Repo
+			for _, src := range sources {
+				if err := validateRootDir(src.Path); err != nil {
+					return err
+				}
 
-			// Start file watcher for live reload
-			w := watcher.New(cfg.RootDir)
-			if err := w.Start(func(relPath string) {
-				handleFileChange(cfg.RootDir, relPath, store, srv.Hub())
-			}); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: file watcher failed to start: %v\n", err)
-			} else {
-				defer w.Stop()
-				fmt.Println("File watcher started")
+				repoCfg, err := loadConfig(src.Path)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: failed to load .markdown-kb.yml in %q: %v\n", src.Path, err)
+				}
+
+				var commit string
+				if rev != "" {
+					if commit, err = gitpkg.ResolveRevision(src.Path, rev); err != nil {
+						return fmt.Errorf("%s: %w", src.Path, err)
+					}
+				}
+
+				store, err := newStore(repoCfg)
+				if err != nil {
+					return err
+				}
+				defer store.Close()
+
+				revisions := revision.NewCache(src.Path, repoCfg, 0)
+				defer revisions.Close()
+
+				repos = append(repos, server.Repo{
+					Name:      src.Name,
+					RootDir:   src.Path,
+					Config:    repoCfg,
+					Store:     store,
+					Commit:    commit,
+					Revisions: revisions,
+				})
+			}
+
+			srv := server.NewMulti(cfg, repos)
+			for _, repo := range repos {
+				stopWatcher := serveRepo(srv.Repo(repo.Name), repo, loadConfig, watch)
+				defer stopWatcher()
 			}
 
 			// Graceful shutdown
@@ -162,7 +447,15 @@ func newServeCmd() *cobra.Command {
 			}()
 
 			url := fmt.Sprintf("http://localhost:%d", cfg.Port)
-			fmt.Printf("Serving %d documents from %s on :%d\n", len(docs), cfg.RootDir, cfg.Port)
+			if len(repos) == 1 {
+				fmt.Printf("Serving %s on :%d (indexing in background)\n", repos[0].RootDir, cfg.Port)
+			} else {
+				names := make([]string, len(repos))
+				for i, repo := range repos {
+					names[i] = repo.Name
+				}
+				fmt.Printf("Serving %d repositories (%s) on :%d (indexing in background)\n", len(repos), strings.Join(names, ", "), cfg.Port)
+			}
 
 			if cfg.Open {
 				openBrowser(url)
@@ -183,9 +476,258 @@ func newServeCmd() *cobra.Command {
 
 	cmd.Flags().IntVar(&cfg.Port, "port", 3000, "Port to listen on")
 	cmd.Flags().BoolVar(&cfg.Open, "open", false, "Open browser after starting")
+	cmd.Flags().StringVar(&rev, "rev", "", "Serve a Git revision (branch, tag or commit) read from git objects instead of the working tree")
+	cmd.Flags().BoolVar(&global, "global", false, "Serve the repositories listed in the global config")
+	cmd.Flags().StringVar(&globalConfig, "config", "", "Global config file listing repositories (default: markdown-kb/config.yml in the user config directory); implies --global")
 	cmd.Flags().String("title", "", "Override display title (default: directory name or .markdown-kb.yml)")
 	cmd.Flags().String("theme", "", "Color theme: default, tokyo-night, dracula, nord, solarized, monokai, github, catppuccin, gruvbox, rose-pine")
 	cmd.Flags().String("font", "", "Font preset: default, noto-sans, rounded, serif, zen-kaku")
+	watch.register(cmd)
+
+	return cmd
+}
+
+// repoSources picks the repositories to serve: the paths given on the
+// command line, else with global or an explicit configPath those listed in
+// the global config, which must list some, else the working directory.
+func repoSources(args []string, configPath string, global bool) ([]config.RepoSource, error) {
+	if len(args) > 0 {
+		return config.RepoSources(args), nil
+	}
+
+	if configPath == "" && global {
+		p, err := config.DefaultGlobalConfigPath()
+		if err != nil {
+			return nil, fmt.Errorf("locating global config: %w", err)
+		}
+		configPath = p
+	}
+	if configPath != "" {
+		globalCfg, err := config.LoadGlobalConfig(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", configPath, err)
+		}
+		if len(globalCfg.Repos) == 0 {
+			return nil, fmt.Errorf("%s lists no repositories", configPath)
+		}
+		return globalCfg.Repos, nil
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("getting working directory: %w", err)
+	}
+	return config.RepoSources([]string{wd}), nil
+}
+
+// serveRepo starts watching repo and builds its initial index in the
+// background, reporting progress through rs. Changes seen before the
+// initial index is built are replayed afterwards. A repository pinned to a
+// commit is indexed from git objects and not watched. The returned function
+// stops the watcher.
+func serveRepo(rs *server.Server, repo server.Repo, loadConfig func(rootDir string) (config.RepoConfig, error), watch watchFlags) func() {
+	rs.SetIndexProgress("scanning", 0, 0)
+
+	if repo.Commit != "" {
+		go func() {
+			if err := revision.Load(repo.Store, repo.RootDir, repo.Config, repo.Commit, rs.SetIndexProgress); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to index %s at %s: %v\n", repo.Name, repo.Commit, err)
+			}
+			rs.SetIndexComplete()
+			_, total, _ := repo.Store.ListDocuments(0, 0)
+			fmt.Printf("Indexed %d documents in %s at %.12s\n", total, repo.Name, repo.Commit)
+		}()
+		return func() {}
+	}
+
+	// Git history is read once in the background and then updated as HEAD
+	// moves.
+	refreshGit := func() {}
+	if _, _, err := gitpkg.Dirs(repo.RootDir); err == nil {
+		gitMeta := gitpkg.NewMetaCache(repo.RootDir)
+		refreshGit = func() {
+			if err := gitMeta.Refresh(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to read git history of %s: %v\n", repo.Name, err)
+				return
+			}
+			rs.SetGitMeta(gitMeta)
+		}
+		go refreshGit()
+	}
+
+	batcher := newChangeBatcher(watch.batch, func(e watcher.Event) {
+		if e.Op == watcher.HeadMoved {
+			refreshGit()
+		}
+		handleEvent(repo.RootDir, e, repo.Store, rs.Broadcast, os.Stdout)
+	}, func(batch []batchedChange) {
+		handleBatch(repo.RootDir, batch, repo.Store, rs.Broadcast, os.Stdout)
+	})
+	changes := newDeferredChanges(batcher.Handle)
+	stop := func() {}
+	w := watcher.NewWithOptions(repo.RootDir, watcherOptions(repo.RootDir, repo.Config, watch))
+	if err := w.Watch(func(e watcher.Event) {
+		if e.Op == watcher.ConfigChanged {
+			reloadConfig(rs, repo.RootDir, loadConfig)
+			return
+		}
+		changes.Handle(e)
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: file watcher failed to start for %q: %v\n", repo.RootDir, err)
+	} else {
+		stop = func() {
+			w.Stop()
+			batcher.Stop()
+		}
+		if watch.mode == "poll" {
+			fmt.Printf("File watcher started for %s (polling every %s)\n", repo.Name, watch.interval)
+		} else {
+			fmt.Printf("File watcher started for %s\n", repo.Name)
+		}
+	}
+
+	// Build the initial index while the server is already answering.
+	go func() {
+		docs, diags, err := scanDocuments(repo.RootDir, repo.Config, func(done, total int) {
+			rs.SetIndexProgress("scanning", done, total)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			indexDocuments(repo.Store, docs, diags, func(done, total int) {
+				rs.SetIndexProgress("indexing", done, total)
+			})
+		}
+		rs.SetIndexComplete()
+		changes.Release()
+		fmt.Printf("Indexed %d documents in %s\n", len(docs), repo.Name)
+	}()
+
+	return stop
+}
+
+func newMCPCmd() *cobra.Command {
+	var watch watchFlags
+
+	cmd := &cobra.Command{
+		Use:   "mcp [path]",
+		Short: "Run a Model Context Protocol server over stdio",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := watch.validate(); err != nil {
+				return err
+			}
+			rootDir := "."
+			if len(args) > 0 {
+				rootDir = args[0]
+			}
+
+			if err := validateRootDir(rootDir); err != nil {
+				return err
+			}
+
+			repoCfg, err := config.LoadRepoConfig(rootDir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to load .markdown-kb.yml: %v\n", err)
+			}
+
+			store, _, err := scanAndIndex(rootDir, repoCfg)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			// Keep the index fresh while the agent edits documents. stdout
+			// carries the protocol, so watcher logs go to stderr. There are
+			// no WebSocket clients to notify.
+			discard := func(server.WSEvent) {}
+			batcher := newChangeBatcher(watch.batch, func(e watcher.Event) {
+				handleEvent(rootDir, e, store, discard, os.Stderr)
+			}, func(batch []batchedChange) {
+				handleBatch(rootDir, batch, store, discard, os.Stderr)
+			})
+			w := watcher.NewWithOptions(rootDir, watcherOptions(rootDir, repoCfg, watch))
+			if err := w.Watch(batcher.Handle); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: file watcher failed to start: %v\n", err)
+			} else {
+				defer batcher.Stop()
+				defer w.Stop()
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			srv := mcp.New(store, rootDir, repoCfg.Title, version)
+			return srv.Serve(ctx, os.Stdin, os.Stdout)
+		},
+	}
+
+	watch.register(cmd)
+
+	return cmd
+}
+
+func newExportCmd() *cobra.Command {
+	var format, baseURL, output string
+
+	cmd := &cobra.Command{
+		Use:   "export [path]",
+		Short: "Export the knowledge base for LLM tooling",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rootDir := "."
+			if len(args) > 0 {
+				rootDir = args[0]
+			}
+
+			if err := validateRootDir(rootDir); err != nil {
+				return err
+			}
+
+			absRoot, err := filepath.Abs(rootDir)
+			if err != nil {
+				return fmt.Errorf("resolving root directory: %w", err)
+			}
+			repoCfg, err := config.LoadRepoConfig(absRoot)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to load .markdown-kb.yml: %v\n", err)
+			}
+
+			store, _, err := scanAndIndex(absRoot, repoCfg)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			entries, err := llms.Collect(store)
+			if err != nil {
+				return fmt.Errorf("collecting documents: %w", err)
+			}
+
+			out := os.Stdout
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return fmt.Errorf("creating output file: %w", err)
+				}
+				defer f.Close()
+				out = f
+			}
+
+			switch format {
+			case "llms":
+				return llms.WriteIndex(out, repoCfg.Title, repoCfg.Description, baseURL, entries)
+			case "llms-full":
+				return llms.WriteFull(out, repoCfg.Title, repoCfg.Description, entries)
+			default:
+				return fmt.Errorf("unknown format %q (use llms or llms-full)", format)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "llms", "Export format (llms|llms-full)")
+	cmd.Flags().StringVar(&baseURL, "base-url", "", "URL prefix for document links in llms.txt (default: relative paths)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write to file instead of stdout")
 
 	return cmd
 }
@@ -207,13 +749,14 @@ func newIndexCmd() *cobra.Command {
 				return err
 			}
 
-			docs, err := scanner.Scan(rootDir)
+			repoCfg, err := config.LoadRepoConfig(rootDir)
 			if err != nil {
-				return fmt.Errorf("scanning directory: %w", err)
+				fmt.Fprintf(os.Stderr, "Warning: failed to load .markdown-kb.yml: %v\n", err)
 			}
 
-			if len(docs) == 0 {
-				fmt.Fprintf(os.Stderr, "Warning: no markdown files found in %q\n", rootDir)
+			docs, _, err := scanDocuments(rootDir, repoCfg, nil)
+			if err != nil {
+				return err
 			}
 
 			switch format {
@@ -233,19 +776,21 @@ func newIndexCmd() *cobra.Command {
 }
 
 type indexEntry struct {
-	Path   string         `json:"path"`
-	Title  string         `json:"title"`
-	Status string         `json:"status,omitempty"`
-	Tags   []string       `json:"tags,omitempty"`
-	Size   int64          `json:"size"`
-	Meta   map[string]any `json:"meta,omitempty"`
+	Path     string         `json:"path"`
+	Title    string         `json:"title"`
+	Status   string         `json:"status,omitempty"`
+	Tags     []string       `json:"tags,omitempty"`
+	Size     int64          `json:"size"`
+	Encoding string         `json:"encoding,omitempty"`
+	Meta     map[string]any `json:"meta,omitempty"`
 }
 
 func docToEntry(doc scanner.Document) indexEntry {
 	entry := indexEntry{
-		Path: doc.RelPath,
-		Size: doc.Size,
-		Meta: doc.Frontmatter,
+		Path:     doc.RelPath,
+		Size:     doc.Size,
+		Encoding: doc.Encoding,
+		Meta:     doc.Frontmatter,
 	}
 	if doc.Frontmatter != nil {
 		entry.Title, _ = doc.Frontmatter["title"].(string)
@@ -271,50 +816,230 @@ func outputJSON(docs []scanner.Document) error {
 	return enc.Encode(entries)
 }
 
-// handleFileChange re-indexes a changed file and broadcasts the event.
-func handleFileChange(rootDir, relPath string, store *index.Store, hub *server.Hub) {
+// reloadConfig re-reads the repository config after its file changed and
+// applies it to rs. A config that fails to load or validate is reported
+// and the last good one stays in effect.
+func reloadConfig(rs *server.Server, rootDir string, loadConfig func(rootDir string) (config.RepoConfig, error)) {
+	repoCfg, err := loadConfig(rootDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: keeping previous config; failed to reload .markdown-kb.yml in %q: %v\n", rootDir, err)
+		return
+	}
+
+	if keys := indexSettingsChanged(rs.RepoConfig(), repoCfg); len(keys) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: changes to %s in %q take effect after a restart\n", strings.Join(keys, ", "), rootDir)
+	}
+	rs.SetRepoConfig(repoCfg)
+	fmt.Printf("[watcher] reloaded config: %s\n", rootDir)
+}
+
+// indexSettingsChanged returns the config keys that differ between old and
+// new and only apply when the index is built.
+func indexSettingsChanged(old, new config.RepoConfig) []string {
+	var keys []string
+	if !slices.Equal(old.Include, new.Include) {
+		keys = append(keys, "include")
+	}
+	if !slices.Equal(old.Exclude, new.Exclude) {
+		keys = append(keys, "exclude")
+	}
+	if !slices.Equal(old.Extensions, new.Extensions) {
+		keys = append(keys, "extensions")
+	}
+	if old.Chunks != new.Chunks {
+		keys = append(keys, "chunks")
+	}
+	if old.FollowSymlinks != new.FollowSymlinks || !slices.Equal(old.SymlinkRoots, new.SymlinkRoots) {
+		keys = append(keys, "follow_symlinks")
+	}
+	return keys
+}
+
+// handleEvent applies a watcher event to the index and broadcasts it.
+// Directory events update every document under the directory and are
+// broadcast once, listing the affected paths. Each change is logged to log.
+func handleEvent(rootDir string, e watcher.Event, store *index.Store, broadcast func(server.WSEvent), log io.Writer) {
+	switch e.Op {
+	case watcher.DirRemoved:
+		dir := filepath.ToSlash(e.Path)
+		paths, err := store.RemoveDir(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove %q from index: %v\n", dir, err)
+			return
+		}
+		broadcast(server.WSEvent{Type: "dir_deleted", Path: dir, Paths: paths})
+		fmt.Fprintf(log, "[watcher] deleted directory: %s (%d documents)\n", dir, len(paths))
+	case watcher.DirRenamed:
+		from, to := filepath.ToSlash(e.From), filepath.ToSlash(e.Path)
+		paths, err := store.RenameDir(from, to)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to move %q to %q in index: %v\n", from, to, err)
+			return
+		}
+		broadcast(server.WSEvent{Type: "dir_renamed", Path: to, From: from, Paths: paths})
+		fmt.Fprintf(log, "[watcher] renamed directory: %s -> %s (%d documents)\n", from, to, len(paths))
+	case watcher.FileRenamed:
+		from := filepath.ToSlash(e.From)
+		if err := store.RemoveDocument(from); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove %q from index: %v\n", from, err)
+		}
+		handleFileChange(rootDir, e.Path, "renamed", from, store, broadcast, log)
+	case watcher.FileCreated:
+		handleFileChange(rootDir, e.Path, "created", "", store, broadcast, log)
+	case watcher.HeadMoved:
+		handleHeadMove(rootDir, e.Head, store, broadcast, log)
+	case watcher.ConfigChanged:
+		// Not a document; config reloads are up to the caller.
+	default:
+		handleFileChange(rootDir, e.Path, "updated", "", store, broadcast, log)
+	}
+}
+
+// handleFileChange re-indexes a changed file and broadcasts the event as
+// eventType ("created", "updated" or "renamed" from from), or as
+// "deleted" if the file is gone.
+func handleFileChange(rootDir, relPath, eventType, from string, store *index.Store, broadcast func(server.WSEvent), log io.Writer) {
 	absPath := filepath.Join(rootDir, relPath)
 
-	info, err := os.Stat(absPath)
+	// A renamed document that can't be indexed at its new path is gone.
+	dropFrom := func() {
+		if from != "" {
+			broadcast(server.WSEvent{Type: "deleted", Path: from})
+		}
+	}
+
+	_, err := os.Stat(absPath)
 	if os.IsNotExist(err) {
+		dropFrom()
 		// File was deleted
 		if removeErr := store.RemoveDocument(relPath); removeErr != nil {
 			fmt.Fprintf(os.Stderr, "Warning: failed to remove %q from index: %v\n", relPath, removeErr)
 		}
-		hub.Broadcast(server.WSEvent{Type: "deleted", Path: relPath})
-		fmt.Printf("[watcher] deleted: %s\n", relPath)
+		broadcast(server.WSEvent{Type: "deleted", Path: relPath})
+		fmt.Fprintf(log, "[watcher] deleted: %s\n", relPath)
 		return
 	}
 	if err != nil {
+		dropFrom()
 		return
 	}
 
-	content, err := os.ReadFile(absPath)
+	doc, err := scanner.ReadDocument(rootDir, relPath)
+	if errors.Is(err, charset.ErrUnknownEncoding) {
+		// Keep the file visible as a diagnostic instead of serving stale content
+		fmt.Fprintf(os.Stderr, "Warning: skipping %q: %v\n", relPath, err)
+		if err := store.SetDiagnostic(scanner.Diagnostic{Path: relPath, Message: err.Error()}); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to record diagnostic for %q: %v\n", relPath, err)
+		}
+		dropFrom()
+		broadcast(server.WSEvent{Type: "deleted", Path: relPath})
+		return
+	}
 	if err != nil {
+		dropFrom()
 		return
 	}
 
-	meta, body, parseErr := parser.ParseFrontmatter(strings.NewReader(string(content)))
-	doc := scanner.Document{
-		RelPath: relPath,
-		AbsPath: absPath,
-		ModTime: info.ModTime(),
-		Size:    info.Size(),
+	if err := store.IndexDocument(doc); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to index %q: %v\n", relPath, err)
+		dropFrom()
+		return
 	}
-	if parseErr != nil {
-		doc.Body = string(content)
+
+	broadcast(server.WSEvent{Type: eventType, Path: relPath, From: from})
+	if from != "" {
+		fmt.Fprintf(log, "[watcher] %s: %s -> %s\n", eventType, from, relPath)
 	} else {
-		doc.Frontmatter = meta
-		doc.Body = body
+		fmt.Fprintf(log, "[watcher] %s: %s\n", eventType, relPath)
 	}
+}
 
-	if err := store.IndexDocument(doc); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to index %q: %v\n", relPath, err)
+// handleHeadMove applies the documents changed by a checkout or pull in
+// one transaction and broadcasts a single "reindexed" event listing them.
+func handleHeadMove(rootDir string, move *watcher.HeadMove, store *index.Store, broadcast func(server.WSEvent), log io.Writer) {
+	if move.Empty() {
 		return
 	}
+	changes := applyChangeSet(rootDir, move.Added, move.Modified, move.Removed, store)
+	broadcast(server.WSEvent{Type: "reindexed", Commit: move.To, Changes: changes})
+	fmt.Fprintf(log, "[watcher] HEAD moved to %.7s: %d created, %d updated, %d deleted\n",
+		move.To, len(changes.Created), len(changes.Updated), len(changes.Deleted))
+}
 
-	hub.Broadcast(server.WSEvent{Type: "updated", Path: relPath})
-	fmt.Printf("[watcher] updated: %s\n", relPath)
+// handleBatch applies a burst of file changes collected by changeBatcher.
+// A single change is handled and broadcast as usual; several are applied
+// in one transaction and broadcast as a single "batch" event.
+func handleBatch(rootDir string, batch []batchedChange, store *index.Store, broadcast func(server.WSEvent), log io.Writer) {
+	if len(batch) == 1 {
+		eventType := "updated"
+		if batch[0].created {
+			eventType = "created"
+		}
+		handleFileChange(rootDir, batch[0].path, eventType, "", store, broadcast, log)
+		return
+	}
+
+	var created, updated, removed []string
+	for _, c := range batch {
+		_, err := os.Stat(filepath.Join(rootDir, c.path))
+		switch {
+		case os.IsNotExist(err):
+			if !c.created {
+				removed = append(removed, c.path)
+			}
+		case err != nil:
+			// Unreadable; a later event covers it.
+		case c.created:
+			created = append(created, c.path)
+		default:
+			updated = append(updated, c.path)
+		}
+	}
+	changes := applyChangeSet(rootDir, created, updated, removed, store)
+	broadcast(server.WSEvent{Type: "batch", Changes: changes})
+	fmt.Fprintf(log, "[watcher] batch: %d created, %d updated, %d deleted\n",
+		len(changes.Created), len(changes.Updated), len(changes.Deleted))
+}
+
+// applyChangeSet indexes the documents at created and updated and removes
+// those at removed in one transaction. Paths are relative to rootDir.
+// Documents with an unknown encoding are recorded as diagnostics and
+// listed as deleted.
+func applyChangeSet(rootDir string, created, updated, removed []string, store *index.Store) *server.ChangeSet {
+	changes := &server.ChangeSet{Created: []string{}, Updated: []string{}, Deleted: []string{}}
+	var docs []scanner.Document
+	read := func(paths []string, list *[]string) {
+		for _, path := range paths {
+			relPath := filepath.ToSlash(path)
+			doc, err := scanner.ReadDocument(rootDir, path)
+			if errors.Is(err, charset.ErrUnknownEncoding) {
+				fmt.Fprintf(os.Stderr, "Warning: skipping %q: %v\n", relPath, err)
+				if err := store.SetDiagnostic(scanner.Diagnostic{Path: relPath, Message: err.Error()}); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: failed to record diagnostic for %q: %v\n", relPath, err)
+				}
+				changes.Deleted = append(changes.Deleted, relPath)
+				continue
+			}
+			if err != nil {
+				// Gone again or unreadable; a later event covers it.
+				continue
+			}
+			docs = append(docs, doc)
+			*list = append(*list, relPath)
+		}
+	}
+	read(created, &changes.Created)
+	read(updated, &changes.Updated)
+	var gone []string
+	for _, path := range removed {
+		gone = append(gone, filepath.ToSlash(path))
+	}
+	changes.Deleted = append(changes.Deleted, gone...)
+
+	if err := store.ApplyChanges(docs, gone); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to apply changes: %v\n", err)
+	}
+	return changes
 }
 
 func outputText(docs []scanner.Document) error {
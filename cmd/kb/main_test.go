@@ -1,12 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/esakat/markdown-kb/internal/config"
 	"github.com/esakat/markdown-kb/internal/scanner"
+	"github.com/esakat/markdown-kb/internal/server"
+	"github.com/esakat/markdown-kb/internal/watcher"
 )
 
 func createTestDir(t *testing.T) string {
@@ -48,7 +56,7 @@ func TestValidateRootDir_NotADir(t *testing.T) {
 
 func TestScanAndIndex(t *testing.T) {
 	tmp := createTestDir(t)
-	store, docs, err := scanAndIndex(tmp)
+	store, docs, err := scanAndIndex(tmp, config.RepoConfig{})
 	if err != nil {
 		t.Fatalf("scanAndIndex() error = %v", err)
 	}
@@ -72,7 +80,7 @@ func TestScanAndIndex(t *testing.T) {
 
 func TestScanAndIndex_EmptyDir(t *testing.T) {
 	tmp := t.TempDir()
-	store, docs, err := scanAndIndex(tmp)
+	store, docs, err := scanAndIndex(tmp, config.RepoConfig{})
 	if err != nil {
 		t.Fatalf("scanAndIndex() error = %v", err)
 	}
@@ -183,3 +191,261 @@ func TestOutputText(t *testing.T) {
 		t.Error("expected non-empty text output")
 	}
 }
+
+func TestDeferredChanges(t *testing.T) {
+	var handled []string
+	d := newDeferredChanges(func(e watcher.Event) {
+		handled = append(handled, e.Path)
+	})
+
+	d.Handle(watcher.Event{Path: "a.md"})
+	d.Handle(watcher.Event{Path: "b.md"})
+	d.Handle(watcher.Event{Path: "a.md"})
+	if len(handled) != 0 {
+		t.Fatalf("expected events to be queued, got %v", handled)
+	}
+
+	d.Release()
+	if strings.Join(handled, ",") != "a.md,b.md" {
+		t.Errorf("replayed = %v, want [a.md b.md]", handled)
+	}
+
+	d.Handle(watcher.Event{Path: "c.md"})
+	if len(handled) != 3 || handled[2] != "c.md" {
+		t.Errorf("expected c.md to be handled directly, got %v", handled)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	dir := t.TempDir()
+	store, err := newStore(config.RepoConfig{})
+	if err != nil {
+		t.Fatalf("newStore() error = %v", err)
+	}
+	defer store.Close()
+	srv := server.New(config.ServeConfig{RootDir: dir, Repo: config.RepoConfig{Title: "Before"}}, store)
+
+	os.WriteFile(filepath.Join(dir, ".markdown-kb.yml"), []byte("title: After\ntheme: nord\n"), 0o644)
+	reloadConfig(srv, dir, config.LoadValidRepoConfig)
+	if got := srv.RepoConfig(); got.Title != "After" || got.Theme != "nord" {
+		t.Errorf("config after reload = %+v", got)
+	}
+
+	// A broken edit keeps the last good config.
+	os.WriteFile(filepath.Join(dir, ".markdown-kb.yml"), []byte("title: [unclosed\n"), 0o644)
+	reloadConfig(srv, dir, config.LoadValidRepoConfig)
+	if got := srv.RepoConfig(); got.Title != "After" {
+		t.Errorf("config after bad reload = %+v, want previous", got)
+	}
+
+	// So does one with values that would fall back to defaults.
+	for _, content := range []string{
+		"title: Typo\ntheme: nrod\n",
+		"title: Typo\nfont: comic\n",
+		"title: Typo\ntag_icons:\n  - tag: go\n",
+		"title: Typo\nexclude: [\"drafts/[\"]\n",
+		"title: Typo\nextensions: [\"md\"]\n",
+	} {
+		os.WriteFile(filepath.Join(dir, ".markdown-kb.yml"), []byte(content), 0o644)
+		reloadConfig(srv, dir, config.LoadValidRepoConfig)
+		if got := srv.RepoConfig(); got.Title != "After" || got.Theme != "nord" {
+			t.Errorf("config after reloading %q = %+v, want previous", content, got)
+		}
+	}
+}
+
+func TestIndexSettingsChanged(t *testing.T) {
+	old := config.RepoConfig{Title: "A", Exclude: []string{"drafts/**"}}
+	if keys := indexSettingsChanged(old, config.RepoConfig{Title: "B", Exclude: []string{"drafts/**"}}); len(keys) != 0 {
+		t.Errorf("title change reported %v", keys)
+	}
+	keys := indexSettingsChanged(old, config.RepoConfig{Extensions: []string{".mdx"}, FollowSymlinks: true})
+	if strings.Join(keys, ",") != "exclude,extensions,follow_symlinks" {
+		t.Errorf("keys = %v", keys)
+	}
+}
+
+func TestWatchFlags(t *testing.T) {
+	for _, f := range []watchFlags{{"fsnotify", time.Second, 0}, {"poll", 5 * time.Second, time.Second}} {
+		if err := f.validate(); err != nil {
+			t.Errorf("validate(%+v) error = %v", f, err)
+		}
+	}
+	for _, f := range []watchFlags{{"inotify", time.Second, 0}, {"poll", 0, 0}, {"fsnotify", time.Second, -time.Second}} {
+		if err := f.validate(); err == nil {
+			t.Errorf("validate(%+v) = nil, want error", f)
+		}
+	}
+	if opts := watcherOptions(t.TempDir(), config.RepoConfig{}, watchFlags{"poll", time.Second, 0}); !opts.Poll || opts.PollInterval != time.Second {
+		t.Errorf("watcherOptions(poll) = %+v", opts)
+	}
+}
+
+func TestRepoSources(t *testing.T) {
+	got, err := repoSources([]string{"/a/docs", "/b/docs"}, "", true)
+	if err != nil {
+		t.Fatalf("repoSources() error = %v", err)
+	}
+	if len(got) != 2 || got[0].Name != "docs" || got[1].Name != "docs-2" || got[1].Path != "/b/docs" {
+		t.Errorf("repoSources(args) = %+v", got)
+	}
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yml")
+	os.WriteFile(cfgPath, []byte("repos:\n  - name: kb\n    path: docs\n"), 0o644)
+	got, err = repoSources(nil, cfgPath, false)
+	if err != nil {
+		t.Fatalf("repoSources() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "kb" || got[0].Path != filepath.Join(dir, "docs") {
+		t.Errorf("repoSources(config) = %+v", got)
+	}
+
+	// An explicit config without repositories is an error.
+	os.WriteFile(cfgPath, []byte("repos: []\n"), 0o644)
+	if _, err := repoSources(nil, cfgPath, false); err == nil {
+		t.Error("expected error for config without repositories")
+	}
+
+	// Without paths or a config, the working directory is served even if
+	// a global config lists repositories.
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	os.MkdirAll(filepath.Join(dir, "markdown-kb"), 0o755)
+	os.WriteFile(filepath.Join(dir, "markdown-kb", "config.yml"), []byte("repos:\n  - name: kb\n    path: docs\n"), 0o644)
+	wd, _ := os.Getwd()
+	got, err = repoSources(nil, "", false)
+	if err != nil || len(got) != 1 || got[0].Path != wd {
+		t.Errorf("repoSources() = %+v, %v, want the working directory", got, err)
+	}
+	got, err = repoSources(nil, "", true)
+	if err != nil || len(got) != 1 || got[0].Name != "kb" {
+		t.Errorf("repoSources(global) = %+v, %v, want the global config", got, err)
+	}
+}
+
+func TestHandleHeadMove(t *testing.T) {
+	tmp := createTestDir(t)
+	store, _, err := scanAndIndex(tmp, config.RepoConfig{})
+	if err != nil {
+		t.Fatalf("scanAndIndex() error = %v", err)
+	}
+	defer store.Close()
+
+	// What a checkout leaves on disk.
+	os.WriteFile(filepath.Join(tmp, "hello.md"), []byte("---\ntitle: Hello again\n---\n"), 0o644)
+	os.WriteFile(filepath.Join(tmp, "new.md"), []byte("# New\n"), 0o644)
+	os.Remove(filepath.Join(tmp, "world.md"))
+
+	var events []server.WSEvent
+	handleEvent(tmp, watcher.Event{Op: watcher.HeadMoved, Head: &watcher.HeadMove{
+		From:     "aaa",
+		To:       "bbb",
+		Added:    []string{"new.md"},
+		Modified: []string{"hello.md"},
+		Removed:  []string{"world.md"},
+	}}, store, func(e server.WSEvent) { events = append(events, e) }, io.Discard)
+
+	if len(events) != 1 {
+		t.Fatalf("broadcast %d events, want 1: %+v", len(events), events)
+	}
+	e := events[0]
+	if e.Type != "reindexed" || e.Commit != "bbb" || e.Changes == nil ||
+		strings.Join(e.Changes.Created, ",") != "new.md" ||
+		strings.Join(e.Changes.Updated, ",") != "hello.md" ||
+		strings.Join(e.Changes.Deleted, ",") != "world.md" {
+		t.Errorf("event = %+v (changes %+v)", e, e.Changes)
+	}
+
+	if doc, _ := store.GetDocument("hello.md"); doc == nil || doc.Title != "Hello again" {
+		t.Errorf("hello.md = %+v, want updated title", doc)
+	}
+	if doc, _ := store.GetDocument("new.md"); doc == nil {
+		t.Error("expected new.md in index")
+	}
+	if doc, _ := store.GetDocument("world.md"); doc != nil {
+		t.Error("expected world.md to be removed")
+	}
+}
+
+func TestChangeBatcher(t *testing.T) {
+	var mu sync.Mutex
+	var handled []string
+	var batches [][]batchedChange
+	b := newChangeBatcher(50*time.Millisecond, func(e watcher.Event) {
+		mu.Lock()
+		handled = append(handled, e.Path)
+		mu.Unlock()
+	}, func(batch []batchedChange) {
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+	})
+
+	b.Handle(watcher.Event{Op: watcher.FileCreated, Path: "a.md"})
+	b.Handle(watcher.Event{Op: watcher.FileChanged, Path: "b.md"})
+	b.Handle(watcher.Event{Op: watcher.FileChanged, Path: "a.md"})
+	time.Sleep(150 * time.Millisecond)
+
+	mu.Lock()
+	want := []batchedChange{{path: "a.md", created: true}, {path: "b.md"}}
+	if len(batches) != 1 || !slices.Equal(batches[0], want) {
+		t.Errorf("batches = %+v, want [%+v]", batches, want)
+	}
+	mu.Unlock()
+
+	// Other events flush the pending batch first.
+	b.Handle(watcher.Event{Op: watcher.FileChanged, Path: "c.md"})
+	b.Handle(watcher.Event{Op: watcher.DirRemoved, Path: "sub"})
+	mu.Lock()
+	if len(batches) != 2 || batches[1][0].path != "c.md" || strings.Join(handled, ",") != "sub" {
+		t.Errorf("batches = %+v, handled = %v", batches, handled)
+	}
+	mu.Unlock()
+}
+
+func TestHandleBatch(t *testing.T) {
+	tmp := createTestDir(t)
+	store, _, err := scanAndIndex(tmp, config.RepoConfig{})
+	if err != nil {
+		t.Fatalf("scanAndIndex() error = %v", err)
+	}
+	defer store.Close()
+
+	os.WriteFile(filepath.Join(tmp, "hello.md"), []byte("---\ntitle: Hello again\n---\n"), 0o644)
+	os.WriteFile(filepath.Join(tmp, "new.md"), []byte("# New\n"), 0o644)
+	os.Remove(filepath.Join(tmp, "world.md"))
+
+	var events []server.WSEvent
+	var log bytes.Buffer
+	handleBatch(tmp, []batchedChange{
+		{path: "hello.md"},
+		{path: "new.md", created: true},
+		{path: "world.md"},
+		{path: "gone.md", created: true}, // created and removed again
+	}, store, func(e server.WSEvent) { events = append(events, e) }, &log)
+
+	if len(events) != 1 || events[0].Type != "batch" {
+		t.Fatalf("events = %+v, want one batch", events)
+	}
+	c := events[0].Changes
+	if strings.Join(c.Created, ",") != "new.md" || strings.Join(c.Updated, ",") != "hello.md" || strings.Join(c.Deleted, ",") != "world.md" {
+		t.Errorf("changes = %+v", c)
+	}
+	if got := log.String(); got != "[watcher] batch: 1 created, 1 updated, 1 deleted\n" {
+		t.Errorf("log = %q", got)
+	}
+	if doc, _ := store.GetDocument("hello.md"); doc == nil || doc.Title != "Hello again" {
+		t.Errorf("hello.md = %+v, want updated title", doc)
+	}
+	if doc, _ := store.GetDocument("world.md"); doc != nil {
+		t.Error("expected world.md to be removed")
+	}
+
+	// A single change keeps its own event.
+	events = nil
+	handleBatch(tmp, []batchedChange{{path: "new.md"}}, store, func(e server.WSEvent) { events = append(events, e) }, io.Discard)
+	if len(events) != 1 || events[0].Type != "updated" || events[0].Path != "new.md" {
+		t.Errorf("events = %+v, want one update", events)
+	}
+}
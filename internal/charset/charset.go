@@ -0,0 +1,121 @@
+// Package charset detects the text encoding of document files and
+// transcodes them to UTF-8. It recognizes byte order marks (UTF-8, UTF-16LE,
+// UTF-16BE) and the legacy Japanese encodings Shift_JIS (Windows-31J) and
+// EUC-JP.
+package charset
+
+//go:generate go run gen.go index-jis0208.txt
+
+import (
+	"bytes"
+	"errors"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding names reported by Decode.
+const (
+	UTF8     = "utf-8"
+	UTF16LE  = "utf-16le"
+	UTF16BE  = "utf-16be"
+	ShiftJIS = "shift_jis"
+	EUCJP    = "euc-jp"
+)
+
+// ErrUnknownEncoding is returned when data is not valid in any supported
+// encoding, e.g. because it is binary.
+var ErrUnknownEncoding = errors.New("unknown or unsupported text encoding")
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// Decode detects the encoding of data and returns its content as UTF-8
+// together with the detected encoding name. A byte order mark decides the
+// encoding when present and is removed. Otherwise valid UTF-8 is returned
+// as is, and Shift_JIS and EUC-JP are tried in turn; when both decode
+// cleanly the more plausibly Japanese result wins.
+func Decode(data []byte) (string, string, error) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		data = data[len(bomUTF8):]
+		if !utf8.Valid(data) {
+			return "", "", ErrUnknownEncoding
+		}
+		return string(data), UTF8, nil
+	case bytes.HasPrefix(data, bomUTF16LE):
+		s, err := decodeUTF16(data[2:], false)
+		return s, UTF16LE, err
+	case bytes.HasPrefix(data, bomUTF16BE):
+		s, err := decodeUTF16(data[2:], true)
+		return s, UTF16BE, err
+	}
+
+	// NUL bytes never appear in text outside UTF-16/32.
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", "", ErrUnknownEncoding
+	}
+	if utf8.Valid(data) {
+		return string(data), UTF8, nil
+	}
+
+	sjis, sjisErr := decodeShiftJIS(data)
+	euc, eucErr := decodeEUCJP(data)
+	switch {
+	case sjisErr == nil && eucErr == nil:
+		if japaneseScore(euc) > japaneseScore(sjis) {
+			return euc, EUCJP, nil
+		}
+		return sjis, ShiftJIS, nil
+	case sjisErr == nil:
+		return sjis, ShiftJIS, nil
+	case eucErr == nil:
+		return euc, EUCJP, nil
+	}
+	return "", "", ErrUnknownEncoding
+}
+
+func decodeUTF16(data []byte, bigEndian bool) (string, error) {
+	if len(data)%2 != 0 {
+		return "", ErrUnknownEncoding
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	runes := utf16.Decode(units)
+	for _, r := range runes {
+		if r == utf8.RuneError {
+			return "", ErrUnknownEncoding // unpaired surrogate
+		}
+	}
+	return string(runes), nil
+}
+
+// japaneseScore rates how plausible s is as Japanese text. Kana and common
+// kanji score positively; half-width katakana and private-use characters,
+// typical of decoding with the wrong Japanese encoding, score negatively.
+func japaneseScore(s string) int {
+	score := 0
+	for _, r := range s {
+		switch {
+		case r >= 0x3040 && r <= 0x30FF: // hiragana, katakana
+			score += 2
+		case r >= 0x4E00 && r <= 0x9FFF: // CJK unified ideographs
+			score++
+		case r >= 0x3000 && r <= 0x303F, r >= 0xFF01 && r <= 0xFF5E: // punctuation, full-width forms
+			score++
+		case r >= 0xFF61 && r <= 0xFF9F: // half-width katakana
+			score -= 2
+		case r >= 0xE000 && r <= 0xF8FF: // private use
+			score -= 4
+		}
+	}
+	return score
+}
@@ -0,0 +1,71 @@
+package charset
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		want     string
+		encoding string
+	}{
+		{"ascii", "# Title", "# Title", UTF8},
+		{"utf-8", "# 見出し", "# 見出し", UTF8},
+		{"utf-8 bom", "\xef\xbb\xbf# Title", "# Title", UTF8},
+		{"utf-16le bom", "\xff\xfe\x23\x00\x20\x00\x8b\x89\xfa\x51\x57\x30\x20\x00\x3d\xd8\x42\xde", "# 見出し 🙂", UTF16LE},
+		{"utf-16be bom", "\xfe\xff\x00\x23\x00\x20\x89\x8b\x51\xfa\x30\x57\x00\x20\xd8\x3d\xde\x42", "# 見出し 🙂", UTF16BE},
+		{"shift_jis", "\x23\x20\x8c\xa9\x8f\x6f\x82\xb5\x0a\x0a\x82\xb1\x82\xea\x82\xcd\x93\xfa\x96\x7b\x8c\xea\x82\xcc\x95\xb6\x8f\x91\x82\xc5\x82\xb7\x81\x42\x87\x40\x81\x60\xb6\xc5", "# 見出し\n\nこれは日本語の文書です。①～ｶﾅ", ShiftJIS},
+		{"euc-jp", "\x23\x20\xb8\xab\xbd\xd0\xa4\xb7\x0a\x0a\xa4\xb3\xa4\xec\xa4\xcf\xc6\xfc\xcb\xdc\xb8\xec\xa4\xce\xca\xb8\xbd\xf1\xa4\xc7\xa4\xb9\xa1\xa3\x8e\xb6\x8e\xc5", "# 見出し\n\nこれは日本語の文書です。ｶﾅ", EUCJP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, enc, err := Decode([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Decode() = %q, want %q", got, tt.want)
+			}
+			if enc != tt.encoding {
+				t.Errorf("encoding = %q, want %q", enc, tt.encoding)
+			}
+		})
+	}
+}
+
+func TestDecode_Undecodable(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"nul bytes", "PNG\x00\x01\x02"},
+		{"invalid lead byte", "\xff\xfd\xfc\x80"},
+		{"truncated double byte", "abc\x82"},
+		{"shift_jis with a stray 0x80", "\x93\xfa\x96\x7b\x8c\xea\x80"},
+		{"odd-length utf-16", "\xff\xfe\x41"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Decode([]byte(tt.data)); !errors.Is(err, ErrUnknownEncoding) {
+				t.Errorf("Decode() error = %v, want ErrUnknownEncoding", err)
+			}
+		})
+	}
+}
+
+func TestDecodeShiftJIS_Invalid(t *testing.T) {
+	for _, data := range []string{"a\x80", "\x82\xa0\x80"} {
+		if got, err := decodeShiftJIS([]byte(data)); err == nil {
+			t.Errorf("decodeShiftJIS(%q) = %q, want an error", data, got)
+		}
+	}
+}
+
+func TestJapaneseScore(t *testing.T) {
+	if japaneseScore("これは日本語") <= japaneseScore("ｺﾚﾊ\ue000") {
+		t.Error("expected kana and kanji to outscore half-width katakana and private use")
+	}
+}
@@ -0,0 +1,83 @@
+//go:build ignore
+
+// gen.go builds jis0208.go from a WHATWG-format index file
+// (https://encoding.spec.whatwg.org/index-jis0208.txt). A copy is committed
+// next to it, so go generate works offline.
+//
+// Usage: go run gen.go index-jis0208.txt
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxPointer is one past the largest pointer in the jis0208 index.
+const maxPointer = 11104
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatal("usage: go run gen.go index-jis0208.txt")
+	}
+	f, err := os.Open(os.Args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	var table [maxPointer]uint16
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		pointer, err := strconv.Atoi(fields[0])
+		if err != nil || pointer >= maxPointer {
+			log.Fatalf("bad pointer in %q", line)
+		}
+		cp, err := strconv.ParseUint(strings.TrimPrefix(fields[1], "0x"), 16, 16)
+		if err != nil {
+			log.Fatalf("bad code point in %q", line)
+		}
+		table[pointer] = uint16(cp)
+	}
+	if err := sc.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by gen.go; DO NOT EDIT.\n\n")
+	b.WriteString("package charset\n\n")
+	b.WriteString("// jis0208 maps WHATWG jis0208 index pointers to BMP code points.\n")
+	b.WriteString("// Zero means the pointer is unmapped.\n")
+	fmt.Fprintf(&b, "var jis0208 = [%d]uint16{\n", maxPointer)
+	for i := 0; i < maxPointer; i += 12 {
+		for j := i; j < i+12 && j < maxPointer; j++ {
+			fmt.Fprintf(&b, "0x%04X,", table[j])
+			if j < i+11 {
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("jis0208.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
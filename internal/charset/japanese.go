@@ -0,0 +1,112 @@
+package charset
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Pointers between these bounds are the Shift_JIS user-defined area, which
+// maps to the private use area instead of the jis0208 index.
+const (
+	userDefinedFirst = 8836
+	userDefinedLast  = 10715
+)
+
+// decodeShiftJIS decodes Shift_JIS as specified by the WHATWG Encoding
+// Standard (effectively Windows-31J). Invalid sequences are an error.
+func decodeShiftJIS(data []byte) (string, error) {
+	var b strings.Builder
+	b.Grow(len(data) * 3 / 2)
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c < 0x80:
+			b.WriteByte(c)
+			continue
+		case c == 0x80:
+			// WHATWG passes 0x80 through as U+0080, a C1 control no
+			// document uses; treat it as invalid like other stray bytes.
+			return "", ErrUnknownEncoding
+		case c >= 0xA1 && c <= 0xDF: // half-width katakana
+			b.WriteRune(0xFF61 + rune(c-0xA1))
+			continue
+		case !(c >= 0x81 && c <= 0x9F || c >= 0xE0 && c <= 0xFC):
+			return "", ErrUnknownEncoding
+		}
+
+		if i+1 >= len(data) {
+			return "", ErrUnknownEncoding
+		}
+		t := data[i+1]
+		if !(t >= 0x40 && t <= 0x7E || t >= 0x80 && t <= 0xFC) {
+			return "", ErrUnknownEncoding
+		}
+		i++
+
+		leadOffset, trailOffset := 0x81, 0x40
+		if c >= 0xA0 {
+			leadOffset = 0xC1
+		}
+		if t >= 0x7F {
+			trailOffset = 0x41
+		}
+		pointer := (int(c)-leadOffset)*188 + int(t) - trailOffset
+
+		if pointer >= userDefinedFirst && pointer <= userDefinedLast {
+			b.WriteRune(0xE000 + rune(pointer-userDefinedFirst))
+			continue
+		}
+		r := lookupJIS0208(pointer)
+		if r == utf8.RuneError {
+			return "", ErrUnknownEncoding
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String(), nil
+}
+
+// decodeEUCJP decodes EUC-JP (JIS X 0208 plus half-width katakana).
+// JIS X 0212 sequences are not supported and treated as invalid.
+func decodeEUCJP(data []byte) (string, error) {
+	var b strings.Builder
+	b.Grow(len(data) * 3 / 2)
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if c < 0x80 {
+			b.WriteByte(c)
+			continue
+		}
+		if i+1 >= len(data) {
+			return "", ErrUnknownEncoding
+		}
+		t := data[i+1]
+		i++
+
+		switch {
+		case c == 0x8E && t >= 0xA1 && t <= 0xDF: // half-width katakana
+			b.WriteRune(0xFF61 + rune(t-0xA1))
+		case c >= 0xA1 && c <= 0xFE && t >= 0xA1 && t <= 0xFE:
+			r := lookupJIS0208((int(c)-0xA1)*94 + int(t) - 0xA1)
+			if r == utf8.RuneError {
+				return "", ErrUnknownEncoding
+			}
+			b.WriteRune(r)
+		default:
+			return "", ErrUnknownEncoding
+		}
+	}
+
+	return b.String(), nil
+}
+
+// lookupJIS0208 returns the code point for pointer, or utf8.RuneError if
+// it is unmapped.
+func lookupJIS0208(pointer int) rune {
+	if pointer < 0 || pointer >= len(jis0208) || jis0208[pointer] == 0 {
+		return utf8.RuneError
+	}
+	return rune(jis0208[pointer])
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// RepoSource is a repository served by kb serve.
+type RepoSource struct {
+	Name string `yaml:"name"` // URL-safe name used in /api/v1/repos/{name}/
+	Path string `yaml:"path"`
+}
+
+// GlobalConfig holds settings that span repositories, loaded from the
+// user's config directory rather than from a repository.
+type GlobalConfig struct {
+	Repos []RepoSource `yaml:"repos"`
+}
+
+// validRepoName matches names that can be used as a single URL path segment.
+var validRepoName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// DefaultGlobalConfigPath returns the global config location, e.g.
+// ~/.config/markdown-kb/config.yml on Linux.
+func DefaultGlobalConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "markdown-kb", "config.yml"), nil
+}
+
+// LoadGlobalConfig reads the global config at path. A missing file yields an
+// empty config. A leading ~/ in repository paths is expanded, relative paths
+// are resolved against the config file's directory, and repositories without
+// a name are named after their directory.
+func LoadGlobalConfig(path string) (GlobalConfig, error) {
+	var cfg GlobalConfig
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return GlobalConfig{}, err
+	}
+
+	used := make(map[string]bool)
+	for i, r := range cfg.Repos {
+		if r.Path == "" {
+			return GlobalConfig{}, fmt.Errorf("repos[%d]: path is required", i)
+		}
+		if rest, ok := strings.CutPrefix(r.Path, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return GlobalConfig{}, fmt.Errorf("repos[%d]: expanding ~: %w", i, err)
+			}
+			cfg.Repos[i].Path = filepath.Join(home, rest)
+		} else if !filepath.IsAbs(r.Path) {
+			cfg.Repos[i].Path = filepath.Join(filepath.Dir(path), r.Path)
+		}
+		if r.Name == "" {
+			continue
+		}
+		if !validRepoName.MatchString(r.Name) {
+			return GlobalConfig{}, fmt.Errorf("repos[%d]: invalid name %q", i, r.Name)
+		}
+		if used[r.Name] {
+			return GlobalConfig{}, fmt.Errorf("repos[%d]: duplicate name %q", i, r.Name)
+		}
+		used[r.Name] = true
+	}
+	for i, r := range cfg.Repos {
+		if r.Name == "" {
+			cfg.Repos[i].Name = uniqueRepoName(RepoName(r.Path), used)
+		}
+	}
+	return cfg, nil
+}
+
+// RepoSources names the repositories at paths after their directories,
+// adding numeric suffixes to tell apart directories with the same name.
+func RepoSources(paths []string) []RepoSource {
+	used := make(map[string]bool)
+	sources := make([]RepoSource, len(paths))
+	for i, p := range paths {
+		sources[i] = RepoSource{Name: uniqueRepoName(RepoName(p), used), Path: p}
+	}
+	return sources
+}
+
+// RepoName derives a URL-safe repository name from a root directory.
+func RepoName(rootDir string) string {
+	if abs, err := filepath.Abs(rootDir); err == nil {
+		rootDir = abs
+	}
+	name := invalidRepoNameChars.ReplaceAllString(filepath.Base(rootDir), "-")
+	if !validRepoName.MatchString(name) {
+		return "default"
+	}
+	return name
+}
+
+var invalidRepoNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// uniqueRepoName returns name, or name with the lowest free "-N" suffix, and
+// marks the result as used.
+func uniqueRepoName(name string, used map[string]bool) string {
+	candidate := name
+	for n := 2; used[candidate]; n++ {
+		candidate = name + "-" + strconv.Itoa(n)
+	}
+	used[candidate] = true
+	return candidate
+}
@@ -1,8 +1,12 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/goccy/go-yaml"
 )
@@ -90,46 +94,64 @@ type TagIcon struct {
 	Emoji string `yaml:"emoji" json:"emoji"`
 }
 
+// ChunkConfig controls how documents are split for chunked retrieval.
+type ChunkConfig struct {
+	MaxTokens int `yaml:"max_tokens"`
+}
+
 // RepoConfig holds per-repository configuration loaded from .markdown-kb.yml.
 type RepoConfig struct {
-	Title    string    `yaml:"title"`
-	Theme    string    `yaml:"theme"`
-	Font     string    `yaml:"font"`
-	TagIcons []TagIcon `yaml:"tag_icons"`
+	Title       string      `yaml:"title"`
+	Description string      `yaml:"description"`
+	Theme       string      `yaml:"theme"`
+	Font        string      `yaml:"font"`
+	TagIcons    []TagIcon   `yaml:"tag_icons"`
+	Chunks      ChunkConfig `yaml:"chunks"`
+	Include     []string    `yaml:"include"`
+	Exclude     []string    `yaml:"exclude"`
+	Extensions  []string    `yaml:"extensions"`
+	// FollowSymlinks enables following symbolic links whose targets are
+	// inside the repository or one of SymlinkRoots (relative to the
+	// repository root or absolute).
+	FollowSymlinks bool     `yaml:"follow_symlinks"`
+	SymlinkRoots   []string `yaml:"symlink_roots"`
 }
 
 // LoadRepoConfig reads .markdown-kb.yml from rootDir.
 // Returns a config with sensible defaults if the file doesn't exist.
 func LoadRepoConfig(rootDir string) (RepoConfig, error) {
-	cfg := RepoConfig{
-		Title: filepath.Base(rootDir),
-		Theme: "default",
-		Font:  "default",
+	fileCfg, err := readRepoConfigFile(rootDir)
+	if err != nil {
+		return withDefaults(rootDir, RepoConfig{}), err
 	}
+	return withDefaults(rootDir, fileCfg), nil
+}
 
-	var data []byte
-	for _, name := range repoConfigFiles {
-		d, err := os.ReadFile(filepath.Join(rootDir, name))
-		if err == nil {
-			data = d
-			break
-		}
-		if !os.IsNotExist(err) {
-			return cfg, err
-		}
-	}
-	if data == nil {
-		return cfg, nil
+// LoadValidRepoConfig is LoadRepoConfig for callers that must not apply
+// settings that fail Validate; it reports them as an error. The file is
+// read once, so the returned config is built from the values validated.
+func LoadValidRepoConfig(rootDir string) (RepoConfig, error) {
+	fileCfg, err := readRepoConfigFile(rootDir)
+	if err != nil {
+		return withDefaults(rootDir, RepoConfig{}), err
 	}
+	return withDefaults(rootDir, fileCfg), fileCfg.Validate()
+}
 
-	var fileCfg RepoConfig
-	if err := yaml.Unmarshal(data, &fileCfg); err != nil {
-		return cfg, err
+// withDefaults returns the config in effect for rootDir given the settings
+// in its config file: unset, unknown or out of range values are replaced
+// by defaults.
+func withDefaults(rootDir string, fileCfg RepoConfig) RepoConfig {
+	cfg := RepoConfig{
+		Title: filepath.Base(rootDir),
+		Theme: "default",
+		Font:  "default",
 	}
 
 	if fileCfg.Title != "" {
 		cfg.Title = fileCfg.Title
 	}
+	cfg.Description = fileCfg.Description
 	if fileCfg.Theme != "" && isValidTheme(fileCfg.Theme) {
 		cfg.Theme = fileCfg.Theme
 	}
@@ -139,8 +161,101 @@ func LoadRepoConfig(rootDir string) (RepoConfig, error) {
 	if len(fileCfg.TagIcons) > 0 {
 		cfg.TagIcons = fileCfg.TagIcons
 	}
+	if fileCfg.Chunks.MaxTokens > 0 {
+		cfg.Chunks.MaxTokens = fileCfg.Chunks.MaxTokens
+	}
+	cfg.Include = fileCfg.Include
+	cfg.Exclude = fileCfg.Exclude
+	cfg.Extensions = fileCfg.Extensions
+	cfg.FollowSymlinks = fileCfg.FollowSymlinks
+	cfg.SymlinkRoots = fileCfg.SymlinkRoots
+
+	return cfg
+}
 
-	return cfg, nil
+// readRepoConfigFile returns the settings in rootDir's config file as
+// written, or the zero config when there is none.
+func readRepoConfigFile(rootDir string) (RepoConfig, error) {
+	var fileCfg RepoConfig
+	for _, name := range repoConfigFiles {
+		data, err := os.ReadFile(filepath.Join(rootDir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fileCfg, err
+		}
+		err = yaml.Unmarshal(data, &fileCfg)
+		return fileCfg, err
+	}
+	return fileCfg, nil
+}
+
+// Validate reports unknown themes and fonts, tag_icons entries without a
+// tag or an emoji, malformed include and exclude globs, extensions without
+// a leading dot and empty symlink_roots entries. Unset values are valid.
+func (c RepoConfig) Validate() error {
+	var errs []error
+	if c.Theme != "" && !isValidTheme(c.Theme) {
+		errs = append(errs, fmt.Errorf("unknown theme %q", c.Theme))
+	}
+	if c.Font != "" && GetFontPreset(c.Font) == nil {
+		errs = append(errs, fmt.Errorf("unknown font %q", c.Font))
+	}
+	for i, icon := range c.TagIcons {
+		if icon.Tag == "" || icon.Emoji == "" {
+			errs = append(errs, fmt.Errorf("tag_icons[%d]: tag and emoji are required", i))
+		}
+	}
+	for _, globs := range []struct {
+		key      string
+		patterns []string
+	}{{"include", c.Include}, {"exclude", c.Exclude}} {
+		for i, pattern := range globs.patterns {
+			if !validGlob(pattern) {
+				errs = append(errs, fmt.Errorf("%s[%d]: malformed pattern %q", globs.key, i, pattern))
+			}
+		}
+	}
+	for i, ext := range c.Extensions {
+		if len(ext) < 2 || ext[0] != '.' {
+			errs = append(errs, fmt.Errorf("extensions[%d]: %q must start with a dot", i, ext))
+		}
+	}
+	for i, root := range c.SymlinkRoots {
+		if strings.TrimSpace(root) == "" {
+			errs = append(errs, fmt.Errorf("symlink_roots[%d]: path is required", i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validGlob reports whether pattern is a glob the ignore rules can match
+// with: "**" or path.Match syntax in each slash-separated segment.
+func validGlob(pattern string) bool {
+	if strings.TrimSpace(pattern) == "" {
+		return false
+	}
+	for _, seg := range strings.Split(pattern, "/") {
+		if seg == "**" {
+			continue
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// IsRepoConfigFile reports whether name is a per-repository config file
+// name (.markdown-kb.yml or .markdown-kb.yaml).
+func IsRepoConfigFile(name string) bool {
+	for _, n := range repoConfigFiles {
+		if n == name {
+			return true
+		}
+	}
+	return false
 }
 
 func isValidTheme(name string) bool {
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -71,6 +72,37 @@ func TestLoadRepoConfig_InvalidTheme(t *testing.T) {
 	}
 }
 
+func TestLoadValidRepoConfig(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LoadValidRepoConfig(dir); err != nil {
+		t.Errorf("LoadValidRepoConfig() without a file = %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, ".markdown-kb.yml"), []byte("theme: nord\nfont: noto-sans\ntag_icons:\n  - tag: go\n    emoji: \"🐹\"\ninclude: [\"docs/**/*.md\"]\nextensions: [\".md\", \".mdx\"]\n"), 0o644)
+	cfg, err := LoadValidRepoConfig(dir)
+	if err != nil {
+		t.Errorf("LoadValidRepoConfig() = %v, want nil", err)
+	}
+	if cfg.Theme != "nord" || len(cfg.Extensions) != 2 {
+		t.Errorf("config = %+v", cfg)
+	}
+
+	os.WriteFile(filepath.Join(dir, ".markdown-kb.yml"), []byte("theme: nrod\nfont: comic\ntag_icons:\n  - tag: go\nexclude: [\"drafts/[\"]\nextensions: [\"md\"]\nsymlink_roots: [\"\"]\n"), 0o644)
+	cfg, err = LoadValidRepoConfig(dir)
+	if err == nil {
+		t.Fatal("LoadValidRepoConfig() = nil error, want errors")
+	}
+	for _, want := range []string{`unknown theme "nrod"`, `unknown font "comic"`, "tag_icons[0]", `exclude[0]: malformed pattern "drafts/["`, "extensions[0]", "symlink_roots[0]"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q missing %q", err, want)
+		}
+	}
+	// The config is still usable, with defaults in place of unknown values.
+	if cfg.Theme != "default" || cfg.Font != "default" {
+		t.Errorf("config = %+v, want default theme and font", cfg)
+	}
+}
+
 func TestLoadRepoConfig_InvalidYAML(t *testing.T) {
 	dir := t.TempDir()
 	content := []byte("title: [unclosed bracket\n")
@@ -176,6 +208,68 @@ func TestLoadRepoConfig_InvalidFont(t *testing.T) {
 	}
 }
 
+func TestLoadRepoConfig_Chunks(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("chunks:\n  max_tokens: 300\n")
+	os.WriteFile(filepath.Join(dir, ".markdown-kb.yml"), content, 0o644)
+
+	cfg, err := LoadRepoConfig(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Chunks.MaxTokens != 300 {
+		t.Errorf("Chunks.MaxTokens = %d, want 300", cfg.Chunks.MaxTokens)
+	}
+}
+
+func TestLoadRepoConfig_IncludeExclude(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("include:\n  - \"docs/**\"\nexclude:\n  - \"**/CHANGELOG.md\"\n  - \"docs/generated/**\"\n")
+	os.WriteFile(filepath.Join(dir, ".markdown-kb.yml"), content, 0o644)
+
+	cfg, err := LoadRepoConfig(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Include) != 1 || cfg.Include[0] != "docs/**" {
+		t.Errorf("Include = %v, want [docs/**]", cfg.Include)
+	}
+	if len(cfg.Exclude) != 2 || cfg.Exclude[1] != "docs/generated/**" {
+		t.Errorf("Exclude = %v", cfg.Exclude)
+	}
+}
+
+func TestLoadRepoConfig_Extensions(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("extensions: [\".md\", \".mdx\", \".markdown\"]\n")
+	os.WriteFile(filepath.Join(dir, ".markdown-kb.yml"), content, 0o644)
+
+	cfg, err := LoadRepoConfig(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Extensions) != 3 || cfg.Extensions[1] != ".mdx" {
+		t.Errorf("Extensions = %v", cfg.Extensions)
+	}
+}
+
+func TestLoadRepoConfig_FollowSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("follow_symlinks: true\nsymlink_roots: [\"../shared\"]\n")
+	os.WriteFile(filepath.Join(dir, ".markdown-kb.yml"), content, 0o644)
+
+	cfg, err := LoadRepoConfig(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.FollowSymlinks {
+		t.Error("FollowSymlinks = false, want true")
+	}
+	if len(cfg.SymlinkRoots) != 1 || cfg.SymlinkRoots[0] != "../shared" {
+		t.Errorf("SymlinkRoots = %v", cfg.SymlinkRoots)
+	}
+}
+
 func TestGetFontPreset(t *testing.T) {
 	p := GetFontPreset("rounded")
 	if p == nil {
@@ -197,3 +291,77 @@ func TestIsValidTheme(t *testing.T) {
 		t.Error("nonexistent should be invalid")
 	}
 }
+
+func TestIsRepoConfigFile(t *testing.T) {
+	if !IsRepoConfigFile(".markdown-kb.yml") || !IsRepoConfigFile(".markdown-kb.yaml") {
+		t.Error("expected both config file names to match")
+	}
+	if IsRepoConfigFile("markdown-kb.yml") {
+		t.Error("markdown-kb.yml should not match")
+	}
+}
+
+func TestLoadGlobalConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	content := []byte("repos:\n  - name: handbook\n    path: /srv/handbook\n  - path: notes\n  - path: other/notes\n  - path: ~/wiki\n")
+	os.WriteFile(path, content, 0o644)
+
+	cfg, err := LoadGlobalConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []RepoSource{
+		{Name: "handbook", Path: "/srv/handbook"},
+		{Name: "notes", Path: filepath.Join(dir, "notes")},
+		{Name: "notes-2", Path: filepath.Join(dir, "other", "notes")},
+		{Name: "wiki", Path: filepath.Join(home, "wiki")},
+	}
+	if len(cfg.Repos) != len(want) {
+		t.Fatalf("Repos = %v, want %v", cfg.Repos, want)
+	}
+	for i := range want {
+		if cfg.Repos[i] != want[i] {
+			t.Errorf("Repos[%d] = %+v, want %+v", i, cfg.Repos[i], want[i])
+		}
+	}
+}
+
+func TestLoadGlobalConfig_Missing(t *testing.T) {
+	cfg, err := LoadGlobalConfig(filepath.Join(t.TempDir(), "config.yml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Repos) != 0 {
+		t.Errorf("Repos = %v, want none", cfg.Repos)
+	}
+}
+
+func TestLoadGlobalConfig_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"missing path":   "repos:\n  - name: a\n",
+		"invalid name":   "repos:\n  - name: a/b\n    path: x\n",
+		"duplicate name": "repos:\n  - name: a\n    path: x\n  - name: a\n    path: y\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yml")
+			os.WriteFile(path, []byte(content), 0o644)
+			if _, err := LoadGlobalConfig(path); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestRepoSources(t *testing.T) {
+	got := RepoSources([]string{"/a/docs", "/b/docs", "/c/My Notes"})
+	want := []string{"docs", "docs-2", "My-Notes"}
+	for i, w := range want {
+		if got[i].Name != w {
+			t.Errorf("RepoSources()[%d].Name = %q, want %q", i, got[i].Name, w)
+		}
+	}
+}
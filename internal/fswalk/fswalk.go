@@ -0,0 +1,181 @@
+// Package fswalk walks a document tree, optionally following symbolic
+// links. Followed links must resolve inside the walk root or one of a set of
+// allowed roots, and directory cycles are detected by device and inode.
+package fswalk
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Options controls how symbolic links are treated.
+type Options struct {
+	// FollowSymlinks makes Walk descend into linked directories and report
+	// linked files. When false, symlinks are skipped.
+	FollowSymlinks bool
+	// AllowedRoots lists absolute directories, besides the walk root, that
+	// link targets may resolve into. Links pointing elsewhere are skipped.
+	AllowedRoots []string
+}
+
+// Entry is a file or directory visited by Walk.
+type Entry struct {
+	RelPath    string // path relative to the walk root, as reached (through links)
+	AbsPath    string // root joined with RelPath; may contain symlinks
+	IsDir      bool
+	ViaSymlink bool   // reached through at least one symbolic link
+	ID         FileID // identity of the underlying file
+}
+
+// FileID identifies a file independently of the path used to reach it.
+type FileID struct {
+	dev, ino uint64
+	path     string // resolved path, where inodes are unavailable
+}
+
+// Walk calls fn for every file and directory below root, in lexical order
+// within each directory. Returning fs.SkipDir for a directory skips its
+// contents; any other error stops the walk. Unreadable entries and broken or
+// disallowed links are skipped silently. A directory that is its own
+// ancestor (a link cycle) is not entered again.
+func Walk(root string, opts Options, fn func(Entry) error) error {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	w := &walker{opts: opts, fn: fn}
+	w.allowed = append(w.allowed, resolve(absRoot))
+	for _, r := range opts.AllowedRoots {
+		w.allowed = append(w.allowed, resolve(r))
+	}
+
+	info, err := os.Lstat(absRoot)
+	if err != nil {
+		return err
+	}
+	viaLink := false
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if !opts.FollowSymlinks {
+			return nil
+		}
+		if info, err = os.Stat(absRoot); err != nil {
+			return nil
+		}
+		viaLink = true
+	}
+	if !info.IsDir() {
+		return nil
+	}
+
+	err = w.walkDir(absRoot, ".", viaLink, []FileID{fileID(absRoot, info)})
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+type walker struct {
+	opts    Options
+	allowed []string
+	fn      func(Entry) error
+}
+
+// walkDir visits the contents of absDir. ancestors holds the IDs of the
+// directories on the current path, for cycle detection.
+func (w *walker) walkDir(absDir, relDir string, viaLink bool, ancestors []FileID) error {
+	entries, err := os.ReadDir(absDir)
+	if err != nil {
+		return nil // skip unreadable directories
+	}
+
+	for _, e := range entries {
+		abs := filepath.Join(absDir, e.Name())
+		rel := e.Name()
+		if relDir != "." {
+			rel = filepath.Join(relDir, e.Name())
+		}
+
+		isLink := e.Type()&fs.ModeSymlink != 0
+		var info fs.FileInfo
+		if isLink {
+			if !w.opts.FollowSymlinks {
+				continue
+			}
+			target, err := filepath.EvalSymlinks(abs)
+			if err != nil || !w.isAllowed(target) {
+				continue // broken link or outside the allowed roots
+			}
+			if info, err = os.Stat(abs); err != nil {
+				continue
+			}
+		} else if info, err = e.Info(); err != nil {
+			continue
+		}
+
+		entry := Entry{
+			RelPath:    rel,
+			AbsPath:    abs,
+			IsDir:      info.IsDir(),
+			ViaSymlink: viaLink || isLink,
+			ID:         fileID(abs, info),
+		}
+
+		if !entry.IsDir {
+			if !info.Mode().IsRegular() {
+				continue
+			}
+			if err := w.fn(entry); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if containsID(ancestors, entry.ID) {
+			continue // link cycle
+		}
+		if err := w.fn(entry); err != nil {
+			if err == fs.SkipDir {
+				continue
+			}
+			return err
+		}
+		if err := w.walkDir(abs, rel, entry.ViaSymlink, append(ancestors, entry.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *walker) isAllowed(target string) bool {
+	for _, root := range w.allowed {
+		if target == root || strings.HasPrefix(target, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsID(ids []FileID, id FileID) bool {
+	for _, x := range ids {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the absolute, symlink-free form of path, or its cleaned
+// absolute form if it can't be resolved.
+func resolve(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	return abs
+}
@@ -0,0 +1,118 @@
+package fswalk
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func collect(t *testing.T, root string, opts Options) map[string]Entry {
+	t.Helper()
+	got := make(map[string]Entry)
+	err := Walk(root, opts, func(e Entry) error {
+		got[filepath.ToSlash(e.RelPath)] = e
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	return got
+}
+
+func keys(m map[string]Entry) []string {
+	var ks []string
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+}
+
+func TestWalk_SkipsSymlinksByDefault(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "real"), 0o755)
+	os.WriteFile(filepath.Join(root, "real", "a.md"), []byte("a"), 0o644)
+	symlink(t, "real", filepath.Join(root, "link"))
+	symlink(t, "real/a.md", filepath.Join(root, "b.md"))
+
+	got := collect(t, root, Options{})
+	want := []string{"real", "real/a.md"}
+	if ks := keys(got); len(ks) != len(want) || ks[0] != want[0] || ks[1] != want[1] {
+		t.Errorf("entries = %v, want %v", ks, want)
+	}
+}
+
+func TestWalk_FollowsSymlinks(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "real"), 0o755)
+	os.WriteFile(filepath.Join(root, "real", "a.md"), []byte("a"), 0o644)
+	symlink(t, "real", filepath.Join(root, "link"))
+	symlink(t, "real/a.md", filepath.Join(root, "b.md"))
+
+	got := collect(t, root, Options{FollowSymlinks: true})
+	for _, p := range []string{"real/a.md", "link/a.md", "b.md"} {
+		if _, ok := got[p]; !ok {
+			t.Errorf("missing %s in %v", p, keys(got))
+		}
+	}
+	if got["real/a.md"].ViaSymlink {
+		t.Error("real/a.md should not be marked ViaSymlink")
+	}
+	if !got["link/a.md"].ViaSymlink || !got["b.md"].ViaSymlink {
+		t.Error("linked entries should be marked ViaSymlink")
+	}
+	if got["real/a.md"].ID != got["link/a.md"].ID || got["real/a.md"].ID != got["b.md"].ID {
+		t.Error("paths to the same file should share an ID")
+	}
+}
+
+func TestWalk_DetectsCycles(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "a", "b"), 0o755)
+	os.WriteFile(filepath.Join(root, "a", "b", "doc.md"), []byte("x"), 0o644)
+	symlink(t, "../..", filepath.Join(root, "a", "b", "up"))
+	symlink(t, ".", filepath.Join(root, "self"))
+
+	got := collect(t, root, Options{FollowSymlinks: true})
+	if _, ok := got["a/b/doc.md"]; !ok {
+		t.Errorf("missing a/b/doc.md in %v", keys(got))
+	}
+	if _, ok := got["a/b/up"]; ok {
+		t.Error("link to an ancestor should not be entered")
+	}
+	if _, ok := got["self"]; ok {
+		t.Error("link to the root should not be entered")
+	}
+}
+
+func TestWalk_AllowedRoots(t *testing.T) {
+	root := t.TempDir()
+	shared := t.TempDir()
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(shared, "s.md"), []byte("s"), 0o644)
+	os.WriteFile(filepath.Join(outside, "o.md"), []byte("o"), 0o644)
+	symlink(t, shared, filepath.Join(root, "shared"))
+	symlink(t, outside, filepath.Join(root, "outside"))
+	symlink(t, filepath.Join(root, "missing"), filepath.Join(root, "broken"))
+
+	got := collect(t, root, Options{FollowSymlinks: true})
+	if len(got) != 0 {
+		t.Errorf("links outside root should be skipped, got %v", keys(got))
+	}
+
+	got = collect(t, root, Options{FollowSymlinks: true, AllowedRoots: []string{shared}})
+	if _, ok := got["shared/s.md"]; !ok {
+		t.Errorf("missing shared/s.md in %v", keys(got))
+	}
+	if _, ok := got["outside/o.md"]; ok {
+		t.Error("link outside the allowed roots should be skipped")
+	}
+}
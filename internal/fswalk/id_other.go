@@ -0,0 +1,11 @@
+//go:build !unix
+
+package fswalk
+
+import "io/fs"
+
+// fileID falls back to the resolved path where inode numbers are not
+// available.
+func fileID(path string, info fs.FileInfo) FileID {
+	return FileID{path: resolve(path)}
+}
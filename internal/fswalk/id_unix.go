@@ -0,0 +1,15 @@
+//go:build unix
+
+package fswalk
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func fileID(path string, info fs.FileInfo) FileID {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return FileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+	}
+	return FileID{path: resolve(path)}
+}
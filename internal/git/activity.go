@@ -0,0 +1,111 @@
+package git
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ActivityOptions selects the commits returned by Activity.
+type ActivityOptions struct {
+	Rev          string   // commit to start from; HEAD when empty
+	Since, Until string   // any date git log accepts, e.g. "2024-05-01" or "1 week ago"
+	Author       string   // pattern matched against the author name and email
+	PathPrefix   string   // only files whose path starts with this
+	Extensions   []string // only files with these extensions (".md"), compared case-insensitively
+	Skip, Limit  int      // pagination; Limit 0 means no limit
+}
+
+// ActivityCommit is a commit with the matching files it changed.
+type ActivityCommit struct {
+	Commit
+	Files []ActivityFile `json:"files"`
+}
+
+// ActivityFile is a file changed by a commit.
+type ActivityFile struct {
+	Status string `json:"status"` // "added", "modified", "deleted" or "renamed"
+	Path   string `json:"path"`
+	From   string `json:"from,omitempty"` // previous path, for "renamed"
+}
+
+// activityStatus names git's status letters.
+var activityStatus = map[byte]string{
+	'A': "added",
+	'M': "modified",
+	'T': "modified",
+	'D': "deleted",
+	'R': "renamed",
+}
+
+// Activity returns the commits reachable from opts.Rev that changed files
+// matching opts below repoDir, most recent first. Merge commits are left
+// out. Paths are relative to repoDir.
+func Activity(repoDir string, opts ActivityOptions) ([]ActivityCommit, error) {
+	rev := opts.Rev
+	if rev == "" {
+		rev = "HEAD"
+	}
+	rev, err := ResolveRevision(repoDir, rev)
+	if errors.Is(err, ErrUnknownRevision) && opts.Rev == "" {
+		// Unborn branch: nothing committed yet.
+		return []ActivityCommit{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"--no-merges"}
+	if opts.Since != "" {
+		args = append(args, "--since="+opts.Since)
+	}
+	if opts.Until != "" {
+		args = append(args, "--until="+opts.Until)
+	}
+	if opts.Author != "" {
+		args = append(args, "--author="+opts.Author, "--regexp-ignore-case")
+	}
+	if opts.Skip > 0 {
+		args = append(args, "--skip="+strconv.Itoa(opts.Skip))
+	}
+	if opts.Limit > 0 {
+		args = append(args, "--max-count="+strconv.Itoa(opts.Limit))
+	}
+	args = append(args, rev, "--")
+	args = append(args, documentPathspecs(opts.PathPrefix, opts.Extensions)...)
+
+	commits, err := readLog(repoDir, args...)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]ActivityCommit, 0, len(commits))
+	for _, c := range commits {
+		ac := ActivityCommit{
+			Commit: Commit{Hash: c.hash, Author: c.author, Date: c.date, Message: c.subject},
+			Files:  []ActivityFile{},
+		}
+		for _, ch := range c.changes {
+			status, ok := activityStatus[ch.status]
+			if !ok {
+				status = "modified"
+			}
+			ac.Files = append(ac.Files, ActivityFile{Status: status, Path: ch.to, From: ch.from})
+		}
+		result = append(result, ac)
+	}
+	return result, nil
+}
+
+// documentPathspecs returns pathspecs matching the files below prefix with
+// one of extensions, or every file below it when there are none.
+func documentPathspecs(prefix string, extensions []string) []string {
+	var specs []string
+	for _, ext := range extensions {
+		// "*" in a pathspec also matches "/", so this is a plain prefix
+		// match at any depth.
+		specs = append(specs, ":(icase)"+prefix+"*"+ext)
+	}
+	if len(extensions) == 0 && prefix != "" {
+		specs = append(specs, prefix+"*")
+	}
+	return specs
+}
@@ -0,0 +1,76 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// CommitDetail is a commit with the files it changed.
+type CommitDetail struct {
+	Commit
+	Body           string       `json:"body"` // message after the subject line
+	AuthorEmail    string       `json:"author_email"`
+	Committer      string       `json:"committer"`
+	CommitterEmail string       `json:"committer_email"`
+	CommitDate     time.Time    `json:"commit_date"`
+	Parents        []string     `json:"parents"`
+	Files          []CommitFile `json:"files"`
+}
+
+// CommitFile is a file changed by a commit, with its diff.
+type CommitFile struct {
+	FileDiff
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+}
+
+// ShowCommit returns the commit rev resolves to and the files with one of
+// extensions below repoDir it changed, or every file when extensions is
+// empty. Merge commits are compared with their first parent. The error
+// wraps ErrUnknownRevision when rev names no commit.
+func ShowCommit(repoDir, rev string, extensions []string) (*CommitDetail, error) {
+	hash, err := ResolveRevision(repoDir, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("git", "show", "-s", "--format=%H%x1f%P%x1f%an%x1f%ae%x1f%aI%x1f%cn%x1f%ce%x1f%cI%x1f%s%x1f%b", hash, "--")
+	cmd.Dir = repoDir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git show: %w", err)
+	}
+	parts := strings.SplitN(string(out), "\x1f", 10)
+	if len(parts) != 10 {
+		return nil, fmt.Errorf("git show: malformed output %q", out)
+	}
+	authorDate, _ := time.Parse(time.RFC3339, parts[4])
+	commitDate, _ := time.Parse(time.RFC3339, parts[7])
+	detail := &CommitDetail{
+		Commit:         Commit{Hash: parts[0], Author: parts[2], Date: authorDate, Message: parts[8]},
+		Body:           strings.TrimSpace(parts[9]),
+		AuthorEmail:    parts[3],
+		Committer:      parts[5],
+		CommitterEmail: parts[6],
+		CommitDate:     commitDate,
+		Parents:        strings.Fields(parts[1]),
+		Files:          []CommitFile{},
+	}
+
+	base := EmptyTree
+	if len(detail.Parents) > 0 {
+		base = detail.Parents[0]
+	}
+	args := append([]string{"--relative", "--find-renames", base, hash, "--"}, documentPathspecs("", extensions)...)
+	raw, err := runDiff(repoDir, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range parseUnifiedDiff(raw) {
+		additions, deletions := f.Stats()
+		detail.Files = append(detail.Files, CommitFile{FileDiff: f, Additions: additions, Deletions: deletions})
+	}
+	return detail, nil
+}
@@ -0,0 +1,274 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// DiffOptions controls StructuredDiff.
+type DiffOptions struct {
+	Words bool // also diff changed lines word by word
+}
+
+// FileDiff is the diff of one file.
+type FileDiff struct {
+	Path   string `json:"path"`
+	From   string `json:"from,omitempty"` // previous path, for "renamed"
+	Status string `json:"status"`         // "added", "modified", "deleted" or "renamed"
+	Binary bool   `json:"binary,omitempty"`
+	Hunks  []Hunk `json:"hunks"`
+}
+
+// Hunk is a run of changed lines with their context.
+type Hunk struct {
+	OldStart int           `json:"old_start"`
+	OldLines int           `json:"old_lines"`
+	NewStart int           `json:"new_start"`
+	NewLines int           `json:"new_lines"`
+	Section  string        `json:"section,omitempty"` // text after the @@ range, e.g. the enclosing heading
+	Lines    []DiffLine    `json:"lines"`
+	Words    []WordSegment `json:"words,omitempty"` // the hunk as words, with DiffOptions.Words
+}
+
+// DiffLine is one line of a hunk. OldLine and NewLine are 1-based and zero
+// on the side the line is missing from.
+type DiffLine struct {
+	Type    string `json:"type"` // "context", "add" or "delete"
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+	Content string `json:"content"`
+}
+
+// WordSegment is a run of text in a word diff. Line breaks are "\n"
+// context segments, placed where git's word diff shows them.
+type WordSegment struct {
+	Type string `json:"type"` // "context", "add" or "delete"
+	Text string `json:"text"`
+}
+
+// Stats returns the number of lines the diff adds and deletes.
+func (d FileDiff) Stats() (additions, deletions int) {
+	for _, h := range d.Hunks {
+		for _, l := range h.Lines {
+			switch l.Type {
+			case "add":
+				additions++
+			case "delete":
+				deletions++
+			}
+		}
+	}
+	return additions, deletions
+}
+
+// StructuredDiff returns the diff between two commits for the files at
+// filePath, parsed into hunks and lines. Paths are relative to repoDir.
+// toHash can also be WorktreeRev or IndexRev, as in Diff.
+func StructuredDiff(repoDir, filePath, fromHash, toHash string, opts DiffOptions) ([]FileDiff, error) {
+	raw, err := revisionDiff(repoDir, filePath, fromHash, toHash, "--relative")
+	if err != nil {
+		return nil, err
+	}
+	files := parseUnifiedDiff(raw)
+	if !opts.Words {
+		return files, nil
+	}
+
+	raw, err = revisionDiff(repoDir, filePath, fromHash, toHash, "--relative", "--word-diff=porcelain")
+	if err != nil {
+		return nil, err
+	}
+	words := parseWordDiff(raw)
+	if len(words) != len(files) {
+		return files, nil
+	}
+	for i := range files {
+		// Word diffs split the same line diff, so the hunks match up.
+		if len(words[i]) != len(files[i].Hunks) {
+			continue
+		}
+		for j := range files[i].Hunks {
+			files[i].Hunks[j].Words = words[i][j]
+		}
+	}
+	return files, nil
+}
+
+// runDiff runs git diff with args in repoDir and returns its output.
+func runDiff(repoDir string, args ...string) (string, error) {
+	// Paths with non-ASCII characters are printed as is rather than quoted.
+	cmd := exec.Command("git", append([]string{"-c", "core.quotePath=false", "diff", "--no-color", "--no-ext-diff"}, args...)...)
+	cmd.Dir = repoDir
+
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			// git diff exits 1 when there are differences.
+			return string(out), nil
+		}
+		return "", fmt.Errorf("git diff: %w", err)
+	}
+	return string(out), nil
+}
+
+// parseUnifiedDiff parses the output of git diff.
+func parseUnifiedDiff(raw string) []FileDiff {
+	files := []FileDiff{}
+	var file *FileDiff
+	var hunk *Hunk
+	var oldLine, newLine int
+	for _, line := range strings.Split(raw, "\n") {
+		if rest, ok := strings.CutPrefix(line, "diff --git "); ok {
+			files = append(files, FileDiff{Path: diffGitPath(rest), Status: "modified", Hunks: []Hunk{}})
+			file, hunk = &files[len(files)-1], nil
+			continue
+		}
+		if file == nil {
+			continue
+		}
+		if hunk == nil {
+			// Extended header, up to the first hunk.
+			switch {
+			case strings.HasPrefix(line, "new file mode"):
+				file.Status = "added"
+			case strings.HasPrefix(line, "deleted file mode"):
+				file.Status = "deleted"
+			case strings.HasPrefix(line, "Binary files "):
+				file.Binary = true
+			case strings.HasPrefix(line, "rename from "):
+				file.Status, file.From = "renamed", strings.TrimPrefix(line, "rename from ")
+			case strings.HasPrefix(line, "rename to "):
+				file.Path = strings.TrimPrefix(line, "rename to ")
+			case strings.HasPrefix(line, "+++ b/"):
+				file.Path = strings.TrimPrefix(line, "+++ b/")
+			}
+		}
+		if strings.HasPrefix(line, "@@ ") {
+			h, ok := parseHunkHeader(line)
+			if !ok {
+				continue
+			}
+			file.Hunks = append(file.Hunks, h)
+			hunk = &file.Hunks[len(file.Hunks)-1]
+			oldLine, newLine = h.OldStart, h.NewStart
+			continue
+		}
+		if hunk == nil || line == "" {
+			continue
+		}
+		switch line[0] {
+		case ' ':
+			hunk.Lines = append(hunk.Lines, DiffLine{Type: "context", OldLine: oldLine, NewLine: newLine, Content: line[1:]})
+			oldLine++
+			newLine++
+		case '-':
+			hunk.Lines = append(hunk.Lines, DiffLine{Type: "delete", OldLine: oldLine, Content: line[1:]})
+			oldLine++
+		case '+':
+			hunk.Lines = append(hunk.Lines, DiffLine{Type: "add", NewLine: newLine, Content: line[1:]})
+			newLine++
+		}
+		// "\ No newline at end of file" is left out.
+	}
+	return files
+}
+
+// diffGitPath returns the path in the rest of a "diff --git a/<path>
+// b/<path>" line. Without renames both halves are the same path, which
+// makes the split unambiguous even when the path contains spaces; renames
+// name both paths in later header lines.
+func diffGitPath(rest string) string {
+	n := (len(rest) - len("a/ b/")) / 2
+	if n <= 0 || len(rest) < 2+n {
+		return rest
+	}
+	return rest[2 : 2+n]
+}
+
+// parseHunkHeader parses "@@ -a,b +c,d @@ section".
+func parseHunkHeader(line string) (Hunk, bool) {
+	ranges, section, ok := strings.Cut(strings.TrimPrefix(line, "@@ "), " @@")
+	if !ok {
+		return Hunk{}, false
+	}
+	oldRange, newRange, ok := strings.Cut(ranges, " ")
+	if !ok {
+		return Hunk{}, false
+	}
+	h := Hunk{Section: strings.TrimSpace(section), Lines: []DiffLine{}}
+	if h.OldStart, h.OldLines, ok = parseRange(oldRange, "-"); !ok {
+		return Hunk{}, false
+	}
+	if h.NewStart, h.NewLines, ok = parseRange(newRange, "+"); !ok {
+		return Hunk{}, false
+	}
+	return h, true
+}
+
+// parseRange parses "-start,count" or "-start", where count defaults to 1.
+func parseRange(s, sign string) (start, count int, ok bool) {
+	s, ok = strings.CutPrefix(s, sign)
+	if !ok {
+		return 0, 0, false
+	}
+	startStr, countStr, hasCount := strings.Cut(s, ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, false
+	}
+	count = 1
+	if hasCount {
+		if count, err = strconv.Atoi(countStr); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, count, true
+}
+
+// parseWordDiff parses the output of git diff --word-diff=porcelain into
+// the segments of each hunk of each file.
+func parseWordDiff(raw string) [][][]WordSegment {
+	var files [][][]WordSegment
+	inHunk := false
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			files = append(files, nil)
+			inHunk = false
+			continue
+		}
+		if len(files) == 0 {
+			continue
+		}
+		hunks := &files[len(files)-1]
+		if strings.HasPrefix(line, "@@ ") {
+			*hunks = append(*hunks, []WordSegment{})
+			inHunk = true
+			continue
+		}
+		if !inHunk || line == "" {
+			continue
+		}
+		var seg WordSegment
+		switch line[0] {
+		case ' ':
+			seg = WordSegment{Type: "context", Text: line[1:]}
+		case '-':
+			seg = WordSegment{Type: "delete", Text: line[1:]}
+		case '+':
+			seg = WordSegment{Type: "add", Text: line[1:]}
+		case '~':
+			seg = WordSegment{Type: "context", Text: "\n"}
+		default:
+			continue
+		}
+		segs := &(*hunks)[len(*hunks)-1]
+		if n := len(*segs); n > 0 && (*segs)[n-1].Type == seg.Type {
+			(*segs)[n-1].Text += seg.Text
+			continue
+		}
+		*segs = append(*segs, seg)
+	}
+	return files
+}
@@ -65,23 +65,10 @@ func FileHistory(repoDir, filePath string) ([]Commit, error) {
 	return commits, nil
 }
 
-// Diff returns the unified diff between two commits for a file.
+// Diff returns the unified diff between two commits for a file. toHash
+// can also be WorktreeRev or IndexRev; see revisionDiff.
 func Diff(repoDir, filePath, fromHash, toHash string) (string, error) {
-	cmd := exec.Command("git", "diff", fromHash+".."+toHash, "--", filePath)
-	cmd.Dir = repoDir
-
-	out, err := cmd.Output()
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			// git diff exits 1 when there are differences (normal behavior)
-			if exitErr.ExitCode() == 1 {
-				return string(out), nil
-			}
-		}
-		return "", fmt.Errorf("git diff: %w", err)
-	}
-
-	return string(out), nil
+	return revisionDiff(repoDir, filePath, fromHash, toHash)
 }
 
 // Blame returns line-by-line attribution for the entire file.
@@ -1,11 +1,16 @@
 package git
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 // newTestRepo creates a temporary git repository with 2 commits
@@ -138,6 +143,157 @@ func TestDiff(t *testing.T) {
 	}
 }
 
+func TestStructuredDiff(t *testing.T) {
+	dir := newTestRepo(t)
+
+	files, err := StructuredDiff(dir, "doc.md", "HEAD~2", "HEAD~1", DiffOptions{Words: true})
+	if err != nil {
+		t.Fatalf("StructuredDiff() error = %v", err)
+	}
+	if len(files) != 1 || files[0].Path != "doc.md" || files[0].Status != "modified" || len(files[0].Hunks) != 1 {
+		t.Fatalf("files = %+v, want one modified doc.md with one hunk", files)
+	}
+	hunk := files[0].Hunks[0]
+	if hunk.OldStart != 1 || hunk.OldLines != 3 || hunk.NewStart != 1 || hunk.NewLines != 4 {
+		t.Errorf("hunk range = -%d,%d +%d,%d, want -1,3 +1,4", hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines)
+	}
+	want := []DiffLine{
+		{Type: "context", OldLine: 1, NewLine: 1, Content: "# Hello"},
+		{Type: "context", OldLine: 2, NewLine: 2, Content: ""},
+		{Type: "delete", OldLine: 3, Content: "First version."},
+		{Type: "add", NewLine: 3, Content: "Updated version."},
+		{Type: "add", NewLine: 4, Content: "New line added."},
+	}
+	if !reflect.DeepEqual(hunk.Lines, want) {
+		t.Errorf("lines = %+v, want %+v", hunk.Lines, want)
+	}
+	var deleted, added []string
+	for _, seg := range hunk.Words {
+		switch seg.Type {
+		case "delete":
+			deleted = append(deleted, seg.Text)
+		case "add":
+			added = append(added, seg.Text)
+		}
+	}
+	if !slices.Equal(deleted, []string{"First"}) || len(added) == 0 || added[0] != "Updated" {
+		t.Errorf("words = %+v, want First replaced by Updated", hunk.Words)
+	}
+
+	files, err = StructuredDiff(dir, "sub/nested.md", "HEAD~1", "HEAD", DiffOptions{})
+	if err != nil {
+		t.Fatalf("StructuredDiff() error = %v", err)
+	}
+	if len(files) != 1 || files[0].Status != "added" || files[0].Hunks[0].Words != nil {
+		t.Errorf("files = %+v, want one added file without words", files)
+	}
+
+	files, err = StructuredDiff(dir, "doc.md", "HEAD~1", "HEAD", DiffOptions{})
+	if err != nil || len(files) != 0 {
+		t.Errorf("StructuredDiff() for unchanged file = %+v, %v; want none", files, err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	dir := newTestRepo(t)
+	os.WriteFile(filepath.Join(dir, "doc.md"), []byte("# Hello\n\nUnsaved edit.\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "sub", "nested.md"), []byte("# Nested\n\nStaged edit.\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "sub", "new.md"), []byte("# New\n"), 0o644)
+	cmd := exec.Command("git", "add", "sub/nested.md")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git add failed: %v\n%s", err, out)
+	}
+
+	status, err := Status(dir)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want := map[string]string{"doc.md": StatusModified, "sub/nested.md": StatusStaged, "sub/new.md": StatusUntracked}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("Status() = %v, want %v", status, want)
+	}
+
+	// Paths are relative to a subdirectory too.
+	status, err = Status(filepath.Join(dir, "sub"), "new.md")
+	if err != nil || !reflect.DeepEqual(status, map[string]string{"new.md": StatusUntracked}) {
+		t.Errorf("Status(sub, new.md) = %v, %v", status, err)
+	}
+
+	diff, err := Diff(dir, "doc.md", "HEAD", WorktreeRev)
+	if err != nil || !strings.Contains(diff, "+Unsaved edit.") {
+		t.Errorf("Diff(HEAD, worktree) = %q, %v", diff, err)
+	}
+	// The staged edit is in the index, so nothing is left to stage.
+	if diff, err := Diff(dir, "sub/nested.md", IndexRev, WorktreeRev); err != nil || diff != "" {
+		t.Errorf("Diff(index, worktree) = %q, %v; want none", diff, err)
+	}
+	if diff, err := Diff(dir, "sub/nested.md", "HEAD", IndexRev); err != nil || !strings.Contains(diff, "+Staged edit.") {
+		t.Errorf("Diff(HEAD, index) = %q, %v", diff, err)
+	}
+
+	if _, err := Diff(dir, "doc.md", "--output="+filepath.Join(dir, "out"), WorktreeRev); !errors.Is(err, ErrUnknownRevision) {
+		t.Errorf("Diff() with an option as revision error = %v, want ErrUnknownRevision", err)
+	}
+
+	files, err := StructuredDiff(dir, "sub/new.md", "HEAD", WorktreeRev, DiffOptions{})
+	if err != nil || len(files) != 1 || files[0].Path != "sub/new.md" || files[0].Status != "added" {
+		t.Errorf("StructuredDiff() for untracked file = %+v, %v", files, err)
+	}
+}
+
+func TestShowCommit(t *testing.T) {
+	dir := newTestRepo(t)
+
+	c, err := ShowCommit(dir, "HEAD~1", []string{".md"})
+	if err != nil {
+		t.Fatalf("ShowCommit() error = %v", err)
+	}
+	if c.Message != "update: modify doc.md" || c.Author != "Test" || c.AuthorEmail != "test@test.com" || c.Committer != "Test" || len(c.Parents) != 1 {
+		t.Errorf("commit = %+v", c)
+	}
+	if len(c.Files) != 1 || c.Files[0].Path != "doc.md" || c.Files[0].Additions != 2 || c.Files[0].Deletions != 1 || len(c.Files[0].Hunks) != 1 {
+		t.Errorf("files = %+v, want doc.md +2 -1", c.Files)
+	}
+
+	// The root commit adds its files.
+	c, err = ShowCommit(dir, "HEAD~2", nil)
+	if err != nil {
+		t.Fatalf("ShowCommit() error = %v", err)
+	}
+	if len(c.Parents) != 0 || len(c.Files) != 1 || c.Files[0].Status != "added" {
+		t.Errorf("root commit = %+v", c)
+	}
+
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@test.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes\n"), 0o644)
+	run("mv", "doc.md", "guide.md")
+	run("add", ".")
+	run("commit", "-m", "refactor: rename doc\n\nMoves doc.md to guide.md.")
+
+	c, err = ShowCommit(dir, "HEAD", []string{".md"})
+	if err != nil {
+		t.Fatalf("ShowCommit() error = %v", err)
+	}
+	if c.Message != "refactor: rename doc" || c.Body != "Moves doc.md to guide.md." {
+		t.Errorf("message = %q, body = %q", c.Message, c.Body)
+	}
+	if len(c.Files) != 1 || c.Files[0].Status != "renamed" || c.Files[0].From != "doc.md" || c.Files[0].Path != "guide.md" {
+		t.Errorf("files = %+v, want doc.md renamed to guide.md only", c.Files)
+	}
+
+	if _, err := ShowCommit(dir, "no-such-commit", nil); !errors.Is(err, ErrUnknownRevision) {
+		t.Errorf("ShowCommit(unknown) error = %v, want ErrUnknownRevision", err)
+	}
+}
+
 func TestBlame(t *testing.T) {
 	dir := newTestRepo(t)
 
@@ -222,3 +378,271 @@ func TestFileHistory_NotGitRepo(t *testing.T) {
 		t.Error("expected error for non-git directory")
 	}
 }
+
+func TestResolveRevision(t *testing.T) {
+	dir := newTestRepo(t)
+
+	head, err := ResolveRevision(dir, "main")
+	if err != nil {
+		t.Fatalf("ResolveRevision() error = %v", err)
+	}
+	if len(head) != 40 {
+		t.Errorf("hash = %q, want 40 hex characters", head)
+	}
+	parent, err := ResolveRevision(dir, "main~1")
+	if err != nil || parent == head {
+		t.Errorf("ResolveRevision(main~1) = %q, %v", parent, err)
+	}
+
+	for _, rev := range []string{"no-such-branch", "--all", ""} {
+		if _, err := ResolveRevision(dir, rev); !errors.Is(err, ErrUnknownRevision) {
+			t.Errorf("ResolveRevision(%q) error = %v, want ErrUnknownRevision", rev, err)
+		}
+	}
+}
+
+func TestListTreeAndReadBlobs(t *testing.T) {
+	dir := newTestRepo(t)
+
+	entries, err := ListTree(dir, "main~1")
+	if err != nil {
+		t.Fatalf("ListTree() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Path != "doc.md" {
+		t.Fatalf("entries at main~1 = %+v", entries)
+	}
+	entries, _ = ListTree(dir, "main")
+	if len(entries) != 2 || entries[1].Path != "sub/nested.md" {
+		t.Fatalf("entries at main = %+v", entries)
+	}
+
+	blobs, err := ReadBlobs(dir, []string{entries[0].Hash, entries[1].Hash})
+	if err != nil {
+		t.Fatalf("ReadBlobs() error = %v", err)
+	}
+	if got := string(blobs[entries[0].Hash]); got != "# Hello\n\nUpdated version.\nNew line added.\n" {
+		t.Errorf("doc.md = %q", got)
+	}
+	if got := string(blobs[entries[1].Hash]); got != "# Nested\n" {
+		t.Errorf("sub/nested.md = %q", got)
+	}
+	if int64(len(blobs[entries[0].Hash])) != entries[0].Size {
+		t.Errorf("size = %d, want %d", entries[0].Size, len(blobs[entries[0].Hash]))
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	dir := newTestRepo(t)
+
+	data, err := ReadFile(dir, "main~2", "doc.md")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(data) != "# Hello\n\nFirst version.\n" {
+		t.Errorf("doc.md at main~2 = %q", data)
+	}
+	if _, err := ReadFile(dir, "main~2", "sub/nested.md"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing file error = %v, want fs.ErrNotExist", err)
+	}
+
+	// Paths are relative to repoDir, also below the top level.
+	data, err = ReadFile(filepath.Join(dir, "sub"), "main", "nested.md")
+	if err != nil || string(data) != "# Nested\n" {
+		t.Errorf("ReadFile(sub, nested.md) = %q, %v", data, err)
+	}
+	os.WriteFile(filepath.Join(dir, "sub", "nested.md"), []byte("# Staged\n"), 0o644)
+	cmd := exec.Command("git", "add", "nested.md")
+	cmd.Dir = filepath.Join(dir, "sub")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git add failed: %v\n%s", err, out)
+	}
+	data, err = ReadFile(filepath.Join(dir, "sub"), "", "nested.md")
+	if err != nil || string(data) != "# Staged\n" {
+		t.Errorf("ReadFile(sub, index, nested.md) = %q, %v", data, err)
+	}
+}
+
+func TestCommitTime(t *testing.T) {
+	dir := newTestRepo(t)
+
+	ts, err := CommitTime(dir, "main")
+	if err != nil {
+		t.Fatalf("CommitTime() error = %v", err)
+	}
+	if time.Since(ts) > time.Hour || ts.IsZero() {
+		t.Errorf("CommitTime() = %v, want recent", ts)
+	}
+}
+
+func TestDirs(t *testing.T) {
+	dir := newTestRepo(t)
+
+	gitDir, commonDir, err := Dirs(filepath.Join(dir, "sub"))
+	if err != nil {
+		t.Fatalf("Dirs() error = %v", err)
+	}
+	want, _ := filepath.EvalSymlinks(filepath.Join(dir, ".git"))
+	if got, _ := filepath.EvalSymlinks(gitDir); got != want {
+		t.Errorf("gitDir = %q, want %q", gitDir, want)
+	}
+	if got, _ := filepath.EvalSymlinks(commonDir); got != want {
+		t.Errorf("commonDir = %q, want %q", commonDir, want)
+	}
+	if _, _, err := Dirs(t.TempDir()); err == nil {
+		t.Error("expected an error outside a repository")
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	dir := newTestRepo(t)
+
+	changes, err := ChangedFiles(dir, "main~2", "main")
+	if err != nil {
+		t.Fatalf("ChangedFiles() error = %v", err)
+	}
+	want := []FileChange{{Status: 'M', Path: "doc.md"}, {Status: 'A', Path: "sub/nested.md"}}
+	if !slices.Equal(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+
+	// Paths are relative to, and limited to, the directory diffed in.
+	changes, _ = ChangedFiles(filepath.Join(dir, "sub"), "main", EmptyTree)
+	if len(changes) != 1 || changes[0] != (FileChange{Status: 'D', Path: "nested.md"}) {
+		t.Errorf("changes in sub = %+v", changes)
+	}
+}
+
+func TestMetaCache(t *testing.T) {
+	dir := newTestRepo(t)
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Other", "-c", "user.email=o@test.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+
+	cache := NewMetaCache(dir)
+	if err := cache.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	m, ok := cache.Get("doc.md")
+	if !ok || m.Commits != 2 || m.Author != "Test" || m.Created.After(m.Updated) {
+		t.Errorf("doc.md = %+v, %v", m, ok)
+	}
+	if m, ok := cache.Get("sub/nested.md"); !ok || m.Commits != 1 {
+		t.Errorf("sub/nested.md = %+v, %v", m, ok)
+	}
+
+	// New commits are applied incrementally and renames are followed.
+	run("mv", "doc.md", "renamed.md")
+	run("commit", "-m", "rename")
+	run("rm", "-q", "sub/nested.md")
+	run("commit", "-m", "remove")
+	if err := cache.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	m, ok = cache.Get("renamed.md")
+	if !ok || m.Commits != 3 || m.Author != "Other" || len(m.Renames) != 1 || m.Renames[0].From != "doc.md" {
+		t.Errorf("renamed.md = %+v, %v", m, ok)
+	}
+	if _, ok := cache.Get("doc.md"); ok {
+		t.Error("expected doc.md to be gone")
+	}
+	if _, ok := cache.Get("sub/nested.md"); ok {
+		t.Error("expected sub/nested.md to be gone")
+	}
+
+	// A full read agrees with the incremental one.
+	full := NewMetaCache(dir)
+	if err := full.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if got, _ := full.Get("renamed.md"); got.Commits != m.Commits || !got.Created.Equal(m.Created) || len(got.Renames) != 1 {
+		t.Errorf("full renamed.md = %+v, incremental %+v", got, m)
+	}
+	if _, ok := full.Get("sub/nested.md"); ok {
+		t.Error("expected sub/nested.md to be gone from full read")
+	}
+}
+
+func TestActivity(t *testing.T) {
+	dir := newTestRepo(t)
+	cmd := exec.Command("git", "-c", "user.name=Other", "-c", "user.email=o@test.com", "commit", "-q", "--allow-empty", "-m", "empty")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git commit failed: %v\n%s", err, out)
+	}
+	md := []string{".md"}
+
+	commits, err := Activity(dir, ActivityOptions{Extensions: md})
+	if err != nil {
+		t.Fatalf("Activity() error = %v", err)
+	}
+	// The empty commit touches no documents.
+	if len(commits) != 3 {
+		t.Fatalf("got %d commits, want 3", len(commits))
+	}
+	if c := commits[0]; c.Message != "add: sub/nested.md" || len(c.Files) != 1 || c.Files[0] != (ActivityFile{Status: "added", Path: "sub/nested.md"}) {
+		t.Errorf("latest commit = %+v", c)
+	}
+	if c := commits[1]; len(c.Files) != 1 || c.Files[0].Status != "modified" || c.Files[0].Path != "doc.md" {
+		t.Errorf("second commit = %+v", c)
+	}
+
+	commits, _ = Activity(dir, ActivityOptions{Extensions: md, PathPrefix: "sub/"})
+	if len(commits) != 1 {
+		t.Errorf("commits under sub/ = %d, want 1", len(commits))
+	}
+	commits, _ = Activity(dir, ActivityOptions{Extensions: md, Skip: 1, Limit: 1})
+	if len(commits) != 1 || commits[0].Message != "update: modify doc.md" {
+		t.Errorf("second page = %+v", commits)
+	}
+	commits, _ = Activity(dir, ActivityOptions{Extensions: md, Author: "nobody"})
+	if len(commits) != 0 {
+		t.Errorf("commits by nobody = %d, want 0", len(commits))
+	}
+	commits, _ = Activity(dir, ActivityOptions{Extensions: md, Since: "2000-01-01", Until: "2001-01-01"})
+	if len(commits) != 0 {
+		t.Errorf("commits in 2000 = %d, want 0", len(commits))
+	}
+}
+
+func TestPathAt(t *testing.T) {
+	dir := newTestRepo(t)
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@test.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	before := run("rev-parse", "HEAD")
+	run("mv", "doc.md", "guide.md")
+	run("commit", "-q", "-m", "rename")
+	run("mv", "guide.md", "docs.md")
+	run("commit", "-q", "-m", "rename again")
+	after := run("rev-parse", "HEAD")
+
+	tests := []struct {
+		path, commit, want string
+	}{
+		{"docs.md", before, "doc.md"},  // current name, old commit
+		{"doc.md", after, "docs.md"},   // old name, new commit
+		{"guide.md", before, "doc.md"}, // intermediate name, old commit
+		{"sub/nested.md", before, "sub/nested.md"},
+	}
+	for _, tt := range tests {
+		got, err := PathAt(dir, tt.path, tt.commit)
+		if err != nil {
+			t.Fatalf("PathAt(%q) error = %v", tt.path, err)
+		}
+		if got != tt.want {
+			t.Errorf("PathAt(%q, %.7s) = %q, want %q", tt.path, tt.commit, got, tt.want)
+		}
+	}
+}
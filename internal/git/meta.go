@@ -0,0 +1,303 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileMeta is the history of one file, following renames.
+type FileMeta struct {
+	Created time.Time `json:"created"` // author date of the first commit
+	Updated time.Time `json:"updated"` // author date of the last commit
+	Author  string    `json:"author"`  // author of the last commit
+	Commits int       `json:"commits"`
+	Renames []Rename  `json:"renames,omitempty"` // most recent first
+}
+
+// Rename is a commit that moved a file.
+type Rename struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	Hash string    `json:"hash"`
+	Date time.Time `json:"date"`
+}
+
+// logCommit is a commit with the files it changed, as read by readLog.
+type logCommit struct {
+	hash    string
+	author  string
+	date    time.Time
+	subject string
+	changes []logChange
+}
+
+// logChange is one file changed by a commit; from is set for renames.
+type logChange struct {
+	status   byte
+	from, to string
+}
+
+// readLog reads the commits selected by args, which end with the revision
+// range, "--" and any pathspecs, most recent first, with the files they
+// changed below repoDir, relative to it.
+func readLog(repoDir string, args ...string) ([]logCommit, error) {
+	args = append([]string{"log", "-z", "--format=%x1e%H%x1f%an%x1f%aI%x1f%s", "--name-status", "--find-renames", "--relative"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = repoDir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+
+	var commits []logCommit
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i < len(fields); i++ {
+		field := strings.TrimPrefix(fields[i], "\n")
+		if header, ok := strings.CutPrefix(field, "\x1e"); ok {
+			parts := strings.SplitN(header, "\x1f", 4)
+			if len(parts) != 4 {
+				return nil, fmt.Errorf("git log: malformed header %q", header)
+			}
+			date, _ := time.Parse(time.RFC3339, parts[2])
+			commits = append(commits, logCommit{hash: parts[0], author: parts[1], date: date, subject: parts[3]})
+			continue
+		}
+		if field == "" || len(commits) == 0 {
+			continue
+		}
+		c := logChange{status: field[0]}
+		switch c.status {
+		case 'R', 'C':
+			if i+2 >= len(fields) {
+				return nil, errors.New("git log: truncated rename")
+			}
+			c.from, c.to = fields[i+1], fields[i+2]
+			i += 2
+		default:
+			if i+1 >= len(fields) {
+				return nil, errors.New("git log: truncated change")
+			}
+			c.to = fields[i+1]
+			i++
+		}
+		if c.status == 'C' {
+			// A copy leaves the source in place; the copy is a new file.
+			c.status, c.from = 'A', ""
+		}
+		last := &commits[len(commits)-1]
+		last.changes = append(last.changes, c)
+	}
+	return commits, nil
+}
+
+// MetaCache holds the history of every file in a repository, built with a
+// single git log pass and updated incrementally as HEAD moves. Its methods
+// are safe for concurrent use.
+type MetaCache struct {
+	repoDir   string
+	refreshMu sync.Mutex // serializes Refresh
+
+	mu    sync.RWMutex
+	head  string
+	files map[string]*FileMeta // by path relative to repoDir, slash-separated
+}
+
+// NewMetaCache creates an empty cache for repoDir; call Refresh to fill it.
+func NewMetaCache(repoDir string) *MetaCache {
+	return &MetaCache{repoDir: repoDir, files: make(map[string]*FileMeta)}
+}
+
+// Get returns the history of the file at path.
+func (c *MetaCache) Get(path string) (FileMeta, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	m, ok := c.files[path]
+	if !ok {
+		return FileMeta{}, false
+	}
+	return *m, true
+}
+
+// Head returns the commit the cache reflects, or "" before the first
+// Refresh.
+func (c *MetaCache) Head() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.head
+}
+
+// Refresh brings the cache up to date with HEAD. When HEAD descends from
+// the commit the cache reflects, only the new commits are read; otherwise
+// (first use, reset, rebase) the whole history is read again.
+func (c *MetaCache) Refresh() error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	head, err := ResolveRevision(c.repoDir, "HEAD")
+	if errors.Is(err, ErrUnknownRevision) {
+		// Unborn branch: nothing committed yet.
+		c.mu.Lock()
+		c.head, c.files = "", make(map[string]*FileMeta)
+		c.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	old := c.Head()
+	if head == old {
+		return nil
+	}
+	if old != "" && isAncestor(c.repoDir, old, head) {
+		commits, err := readLog(c.repoDir, old+".."+head, "--")
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		// Oldest first, so each commit applies on top of the previous.
+		for i := len(commits) - 1; i >= 0; i-- {
+			applyCommit(c.files, commits[i])
+		}
+		c.head = head
+		return nil
+	}
+
+	commits, err := readLog(c.repoDir, head, "--")
+	if err != nil {
+		return err
+	}
+	files := buildMeta(commits)
+	c.mu.Lock()
+	c.head, c.files = head, files
+	c.mu.Unlock()
+	return nil
+}
+
+// isAncestor reports whether commit a is an ancestor of commit b.
+func isAncestor(repoDir, a, b string) bool {
+	cmd := exec.Command("git", "merge-base", "--is-ancestor", a, b)
+	cmd.Dir = repoDir
+	return cmd.Run() == nil
+}
+
+// touch records commit c as a change to m. Commits are ordered by
+// history, not by date: newer is true when c follows every commit already
+// recorded, and false when it precedes them.
+func (m *FileMeta) touch(c logCommit, newer bool) {
+	m.Commits++
+	if newer || m.Commits == 1 {
+		m.Updated, m.Author = c.date, c.author
+	}
+	if !newer || m.Commits == 1 {
+		m.Created = c.date
+	}
+}
+
+// buildMeta walks commits from the most recent back, following each file
+// present at the first commit through its renames.
+func buildMeta(commits []logCommit) map[string]*FileMeta {
+	files := make(map[string]*FileMeta)
+	// lineage maps a path as of the commit being read to the history it
+	// belongs to. Paths deleted later, or re-created after a deletion, get
+	// histories that are not kept.
+	lineage := make(map[string]*FileMeta)
+	for _, c := range commits {
+		for _, ch := range c.changes {
+			m := lineage[ch.to]
+			if m == nil {
+				m = &FileMeta{}
+				if _, seen := files[ch.to]; !seen && ch.status != 'D' {
+					files[ch.to] = m
+				}
+				lineage[ch.to] = m
+			}
+			m.touch(c, false)
+			switch ch.status {
+			case 'A':
+				// Older changes at this path belong to a different file.
+				delete(lineage, ch.to)
+			case 'R':
+				delete(lineage, ch.to)
+				lineage[ch.from] = m
+				m.Renames = append(m.Renames, Rename{From: ch.from, To: ch.to, Hash: c.hash, Date: c.date})
+			}
+		}
+	}
+	return files
+}
+
+// applyCommit records a commit newer than everything in files.
+func applyCommit(files map[string]*FileMeta, c logCommit) {
+	for _, ch := range c.changes {
+		switch ch.status {
+		case 'D':
+			delete(files, ch.to)
+			continue
+		case 'A':
+			files[ch.to] = &FileMeta{}
+		case 'R':
+			m := files[ch.from]
+			if m == nil {
+				m = &FileMeta{}
+			}
+			delete(files, ch.from)
+			m.Renames = append([]Rename{{From: ch.from, To: ch.to, Hash: c.hash, Date: c.date}}, m.Renames...)
+			files[ch.to] = m
+		}
+		m := files[ch.to]
+		if m == nil {
+			m = &FileMeta{}
+			files[ch.to] = m
+		}
+		m.touch(c, true)
+	}
+}
+
+// PathAt returns the path that the file at filePath had in commit,
+// following renames in either direction: back from HEAD for a file that
+// was renamed since commit, and forward for an old path renamed before
+// commit. It returns filePath itself when no rename applies.
+func PathAt(repoDir, filePath, commit string) (string, error) {
+	// Renamed since: walk the file's history back from HEAD until a
+	// rename that commit already contains.
+	commits, err := readLog(repoDir, "--follow", "HEAD", "--", filePath)
+	if err != nil {
+		return "", err
+	}
+	name := filePath
+walk:
+	for _, c := range commits {
+		for _, ch := range c.changes {
+			if ch.status != 'R' || ch.to != name {
+				continue
+			}
+			if c.hash == commit || isAncestor(repoDir, c.hash, commit) {
+				break walk
+			}
+			name = ch.from
+		}
+	}
+	if name != filePath {
+		return name, nil
+	}
+
+	// Renamed before: replay the renames leading up to commit.
+	commits, err = readLog(repoDir, "--diff-filter=R", commit, "--")
+	if err != nil {
+		return "", err
+	}
+	for i := len(commits) - 1; i >= 0; i-- {
+		for _, ch := range commits[i].changes {
+			if ch.status == 'R' && ch.from == name {
+				name = ch.to
+			}
+		}
+	}
+	return name, nil
+}
@@ -0,0 +1,216 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnknownRevision is returned when a revision can't be resolved to a
+// commit.
+var ErrUnknownRevision = errors.New("unknown revision")
+
+// EmptyTree is the hash of the empty tree, for diffing against an unborn
+// branch.
+const EmptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// TreeEntry is a file in a commit's tree.
+type TreeEntry struct {
+	Path string // slash-separated path from the repository root
+	Hash string // blob hash
+	Size int64
+}
+
+// ResolveRevision resolves rev (a branch, tag, or commit hash) to a full
+// commit hash.
+func ResolveRevision(repoDir, rev string) (string, error) {
+	if rev == "" || strings.HasPrefix(rev, "-") {
+		return "", fmt.Errorf("%w: %q", ErrUnknownRevision, rev)
+	}
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	cmd.Dir = repoDir
+
+	out, err := cmd.Output()
+	if err != nil {
+		// Exit status 1 means no such commit, 128 no repository.
+		if _, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("%w: %q", ErrUnknownRevision, rev)
+		}
+		return "", fmt.Errorf("git rev-parse: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Dirs returns the absolute git directory of the repository containing
+// repoDir, which holds HEAD and the index, and its common directory, which
+// holds refs and differs from it in linked worktrees.
+func Dirs(repoDir string) (gitDir, commonDir string, err error) {
+	cmd := exec.Command("git", "rev-parse", "--absolute-git-dir", "--git-common-dir")
+	cmd.Dir = repoDir
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("git rev-parse: %w", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 {
+		return "", "", fmt.Errorf("git rev-parse: unexpected output %q", out)
+	}
+	gitDir, commonDir = lines[0], lines[1]
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(repoDir, commonDir)
+	}
+	return gitDir, filepath.Clean(commonDir), nil
+}
+
+// FileChange is a file that differs between two commits.
+type FileChange struct {
+	Status byte   // 'A' added, 'M' modified, 'D' deleted, 'T' type changed
+	Path   string // slash-separated, relative to the directory diffed in
+}
+
+// ChangedFiles lists the files under repoDir that differ between the
+// commits from and to. Renames are reported as a deletion and an addition.
+func ChangedFiles(repoDir, from, to string) ([]FileChange, error) {
+	cmd := exec.Command("git", "diff", "--name-status", "-z", "--no-renames", "--relative", from, to, "--")
+	cmd.Dir = repoDir
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff: %w", err)
+	}
+
+	// <status> NUL <path> NUL ...
+	fields := strings.Split(string(out), "\x00")
+	var changes []FileChange
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == "" {
+			break
+		}
+		changes = append(changes, FileChange{Status: fields[i][0], Path: fields[i+1]})
+	}
+	return changes, nil
+}
+
+// CommitTime returns the committer date of commit.
+func CommitTime(repoDir, commit string) (time.Time, error) {
+	cmd := exec.Command("git", "show", "-s", "--format=%cI", commit)
+	cmd.Dir = repoDir
+
+	out, err := cmd.Output()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("git show: %w", err)
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(out)))
+}
+
+// ListTree returns the regular files in commit's tree, recursively.
+// Symlinks and submodules are skipped.
+func ListTree(repoDir, commit string) ([]TreeEntry, error) {
+	cmd := exec.Command("git", "ls-tree", "-r", "-z", "--long", commit)
+	cmd.Dir = repoDir
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-tree: %w", err)
+	}
+
+	var entries []TreeEntry
+	for _, rec := range strings.Split(string(out), "\x00") {
+		// <mode> SP <type> SP <object> SP+ <size> TAB <path>
+		meta, path, ok := strings.Cut(rec, "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 4 || fields[1] != "blob" || fields[0] == "120000" {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[3], 10, 64)
+		entries = append(entries, TreeEntry{Path: path, Hash: fields[2], Size: size})
+	}
+	return entries, nil
+}
+
+// ReadBlobs returns the contents of the given blobs, keyed by hash, using a
+// single git cat-file process.
+func ReadBlobs(repoDir string, hashes []string) (map[string][]byte, error) {
+	blobs := make(map[string][]byte, len(hashes))
+	if len(hashes) == 0 {
+		return blobs, nil
+	}
+
+	cmd := exec.Command("git", "cat-file", "--batch")
+	cmd.Dir = repoDir
+	cmd.Stdin = strings.NewReader(strings.Join(hashes, "\n") + "\n")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+
+	r := bufio.NewReader(stdout)
+	readErr := func() error {
+		for range hashes {
+			// <object> SP <type> SP <size> LF <contents> LF, or
+			// <object> SP missing LF
+			header, err := r.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			fields := strings.Fields(header)
+			if len(fields) != 3 {
+				continue
+			}
+			size, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return fmt.Errorf("bad cat-file header %q", header)
+			}
+			data := make([]byte, size+1)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return err
+			}
+			blobs[fields[0]] = data[:size]
+		}
+		return nil
+	}()
+	if readErr != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, fmt.Errorf("git cat-file: %w", readErr)
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	return blobs, nil
+}
+
+// ReadFile returns the contents of filePath, relative to repoDir, as of
+// commit, or as staged in the index when commit is empty. The error wraps
+// fs.ErrNotExist when the file isn't in that commit.
+func ReadFile(repoDir, commit, filePath string) ([]byte, error) {
+	var stderr bytes.Buffer
+	// "./" resolves the path from repoDir rather than the top level.
+	cmd := exec.Command("git", "cat-file", "blob", commit+":./"+filePath)
+	cmd.Dir = repoDir
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("%w: %s", fs.ErrNotExist, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,127 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Working-tree states reported by Status.
+const (
+	StatusClean     = "clean"
+	StatusModified  = "modified"  // changes not staged for commit
+	StatusStaged    = "staged"    // every change staged for commit
+	StatusUntracked = "untracked" // new file git doesn't know about
+)
+
+// WorktreeRev and IndexRev stand for the working tree and the index in
+// place of a commit in Diff and StructuredDiff. The working tree can only
+// be the "to" side, and the index either side of a working tree diff or
+// the "to" side of a diff from a commit.
+const (
+	WorktreeRev = ":worktree"
+	IndexRev    = ":index"
+)
+
+// Status returns the state of files below repoDir that differ from HEAD,
+// keyed by path relative to repoDir. Files missing from the result are
+// clean. paths, if given, limit the files looked at.
+func Status(repoDir string, paths ...string) (map[string]string, error) {
+	prefix, err := showPrefix(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	args := append([]string{"status", "--porcelain=v1", "-z", "--untracked-files=all", "--"}, paths...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = repoDir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git status: %w", err)
+	}
+
+	status := make(map[string]string)
+	entries := strings.Split(string(out), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		x, y, path := entry[0], entry[1], entry[3:]
+		if x == 'R' || x == 'C' {
+			// The source path follows.
+			i++
+		}
+		// Porcelain paths are relative to the top level.
+		path, ok := strings.CutPrefix(path, prefix)
+		if !ok {
+			continue
+		}
+		switch {
+		case x == '?':
+			status[path] = StatusUntracked
+		case y == 'D':
+			// Deleted from the working tree: nothing left to show.
+		case y != ' ':
+			status[path] = StatusModified
+		case x != 'D':
+			status[path] = StatusStaged
+		}
+	}
+	return status, nil
+}
+
+// showPrefix returns the path of repoDir below the top level of its
+// repository, with a trailing slash, or "" at the top level.
+func showPrefix(repoDir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-prefix")
+	cmd.Dir = repoDir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse: %w", err)
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
+
+// revisionDiff runs git diff between from and to, either of which can be
+// WorktreeRev or IndexRev, limited to filePath. flags come before the
+// revisions. Untracked files, which git diff leaves out, show as added
+// when comparing the working tree.
+func revisionDiff(repoDir, filePath, from, to string, flags ...string) (string, error) {
+	for _, rev := range []string{from, to} {
+		// Anything else starting with "-" would be read as an option.
+		if strings.HasPrefix(rev, "-") {
+			return "", fmt.Errorf("%w: %q", ErrUnknownRevision, rev)
+		}
+	}
+	args := flags
+	switch {
+	case to == WorktreeRev && from == IndexRev:
+	case to == WorktreeRev:
+		args = append(args, from)
+	case to == IndexRev:
+		args = append(args, "--cached", from)
+	default:
+		args = append(args, from+".."+to)
+	}
+	out, err := runDiff(repoDir, append(args, "--", filePath)...)
+	if err != nil || out != "" || to != WorktreeRev {
+		return out, err
+	}
+
+	cmd := exec.Command("git", "ls-files", "--others", "--exclude-standard", "--", filePath)
+	cmd.Dir = repoDir
+	untracked, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git ls-files: %w", err)
+	}
+	if len(bytes.TrimSpace(untracked)) == 0 {
+		return "", nil
+	}
+	noIndex := append(append([]string{}, flags...), "--no-index", "--", os.DevNull, filePath)
+	return runDiff(repoDir, noIndex...)
+}
@@ -0,0 +1,274 @@
+// Package ignore decides which files and directories are excluded from
+// scanning and watching. It combines built-in skip rules, .gitignore files
+// (including nested ones), .kbignore files, and include/exclude globs from
+// the repository config.
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// skipDirs contains directory names that are always skipped.
+var skipDirs = map[string]bool{
+	".git":         true,
+	".svn":         true,
+	".hg":          true,
+	"node_modules": true,
+}
+
+// ignoreFiles are read in every directory, in increasing precedence.
+var ignoreFiles = []string{".gitignore", ".kbignore"}
+
+// IsIgnoreFile reports whether name is a file that defines ignore rules.
+func IsIgnoreFile(name string) bool {
+	for _, f := range ignoreFiles {
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
+
+// rule is a single gitignore-style pattern.
+type rule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool // pattern contains a slash: match against the full relative path
+}
+
+// Matcher evaluates ignore rules for paths relative to a root directory.
+// It is safe for concurrent use.
+type Matcher struct {
+	root    string
+	include []string
+	exclude []string
+
+	mu    sync.Mutex
+	rules map[string][]rule // keyed by slash-separated directory, "." for root
+}
+
+// New creates a Matcher for rootDir. include and exclude are glob patterns
+// (with ** support) matched against slash-separated relative paths. When
+// include is non-empty, only files matching one of its patterns are kept.
+func New(rootDir string, include, exclude []string) *Matcher {
+	return &Matcher{
+		root:    rootDir,
+		include: include,
+		exclude: exclude,
+		rules:   make(map[string][]rule),
+	}
+}
+
+// Ignored reports whether relPath should be skipped, assuming its parent
+// directories are not ignored (as during a directory walk that prunes
+// ignored directories).
+func (m *Matcher) Ignored(relPath string, isDir bool) bool {
+	rel := filepath.ToSlash(filepath.Clean(relPath))
+	if rel == "." || rel == "" {
+		return false
+	}
+
+	name := path.Base(rel)
+	if isDir && (skipDirs[name] || strings.HasPrefix(name, ".")) {
+		return true
+	}
+
+	for _, pattern := range m.exclude {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+		if isDir && strings.HasSuffix(pattern, "/**") && matchGlob(strings.TrimSuffix(pattern, "/**"), rel) {
+			return true
+		}
+	}
+
+	if m.gitIgnored(rel, isDir) {
+		return true
+	}
+
+	if !isDir && len(m.include) > 0 {
+		for _, pattern := range m.include {
+			if matchGlob(pattern, rel) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// IgnoredPath reports whether relPath or any of its parent directories is
+// ignored. Use it for paths that don't come from a pruned walk, such as
+// file system events.
+func (m *Matcher) IgnoredPath(relPath string, isDir bool) bool {
+	rel := filepath.ToSlash(filepath.Clean(relPath))
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.Ignored(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return m.Ignored(rel, isDir)
+}
+
+// Invalidate drops cached rules for the directory containing an ignore
+// file, so that edits to .gitignore or .kbignore take effect.
+func (m *Matcher) Invalidate(relDir string) {
+	dir := filepath.ToSlash(filepath.Clean(relDir))
+	m.mu.Lock()
+	delete(m.rules, dir)
+	m.mu.Unlock()
+}
+
+// gitIgnored applies .gitignore/.kbignore rules from the root down to the
+// path's parent directory. The last matching rule wins, so rules in deeper
+// directories and negations override earlier ones.
+func (m *Matcher) gitIgnored(rel string, isDir bool) bool {
+	ignored := false
+	dirs := append([]string{"."}, ancestors(rel)...)
+
+	for _, dir := range dirs {
+		sub := rel
+		if dir != "." {
+			sub = strings.TrimPrefix(rel, dir+"/")
+		}
+		for _, r := range m.rulesFor(dir) {
+			if r.match(sub, isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// ancestors returns the parent directories of rel, outermost first,
+// excluding the root.
+func ancestors(rel string) []string {
+	parts := strings.Split(rel, "/")
+	dirs := make([]string, 0, len(parts)-1)
+	for i := 1; i < len(parts); i++ {
+		dirs = append(dirs, strings.Join(parts[:i], "/"))
+	}
+	return dirs
+}
+
+func (m *Matcher) rulesFor(dir string) []rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rules, ok := m.rules[dir]; ok {
+		return rules
+	}
+
+	absDir := filepath.Join(m.root, filepath.FromSlash(dir))
+	var rules []rule
+	if dir == "." {
+		rules = append(rules, readRules(filepath.Join(absDir, ".git", "info", "exclude"))...)
+	}
+	for _, name := range ignoreFiles {
+		rules = append(rules, readRules(filepath.Join(absDir, name))...)
+	}
+	m.rules[dir] = rules
+	return rules
+}
+
+// readRules parses a gitignore-format file. Missing files yield no rules.
+func readRules(file string) []rule {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []rule
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if r, ok := parseRule(sc.Text()); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// parseRule parses one line of a gitignore file.
+func parseRule(line string) (rule, bool) {
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " \t")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	var r rule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+
+	r.pattern = line
+	return r, true
+}
+
+func (r rule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.anchored {
+		return matchGlob(r.pattern, rel)
+	}
+	return matchGlob(r.pattern, path.Base(rel))
+}
+
+// matchGlob matches a slash-separated path against a glob pattern where
+// "**" matches any number of path segments and other segments use
+// path.Match syntax.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			rest := pat[1:]
+			if len(rest) == 0 {
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,158 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.md", "a.md", true},
+		{"*.md", "dir/a.md", false},
+		{"docs/*.md", "docs/a.md", true},
+		{"**/CHANGELOG.md", "CHANGELOG.md", true},
+		{"**/CHANGELOG.md", "a/b/CHANGELOG.md", true},
+		{"build/**", "build/out/a.md", true},
+		{"build/**", "build", false},
+		{"a/**/z.md", "a/z.md", true},
+		{"a/**/z.md", "a/b/c/z.md", true},
+		{"a/**/z.md", "b/z.md", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		line string
+		want rule
+		ok   bool
+	}{
+		{"# comment", rule{}, false},
+		{"", rule{}, false},
+		{"*.log", rule{pattern: "*.log"}, true},
+		{"!keep.md", rule{pattern: "keep.md", negate: true}, true},
+		{"build/", rule{pattern: "build", dirOnly: true}, true},
+		{"/root.md", rule{pattern: "root.md", anchored: true}, true},
+		{"docs/gen", rule{pattern: "docs/gen", anchored: true}, true},
+		{`\#hash.md`, rule{pattern: "#hash.md"}, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseRule(tt.line)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseRule(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMatcher_BuiltinDirs(t *testing.T) {
+	m := New(t.TempDir(), nil, nil)
+	for _, dir := range []string{".git", "node_modules", ".obsidian", "a/node_modules"} {
+		if !m.Ignored(dir, true) {
+			t.Errorf("expected %q to be ignored", dir)
+		}
+	}
+	if m.Ignored("docs", true) {
+		t.Error("docs should not be ignored")
+	}
+}
+
+func TestMatcher_GitignoreAndKbignore(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".gitignore"), "build/\n*.gen.md\n!keep.gen.md\n/TODO.md\n")
+	writeFile(t, filepath.Join(root, "docs", ".gitignore"), "drafts\n")
+	writeFile(t, filepath.Join(root, ".kbignore"), "CHANGELOG.md\n")
+
+	m := New(root, nil, nil)
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"build", true, true},
+		{"build", false, false}, // dir-only rule
+		{"a/b.gen.md", false, true},
+		{"keep.gen.md", false, false},
+		{"TODO.md", false, true},
+		{"sub/TODO.md", false, false}, // anchored to root
+		{"docs/drafts", true, true},
+		{"drafts", true, false}, // nested .gitignore only applies below docs/
+		{"CHANGELOG.md", false, true},
+		{"pkg/CHANGELOG.md", false, true},
+		{"guide.md", false, false},
+	}
+	for _, tt := range tests {
+		if got := m.Ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestMatcher_IgnoredPath(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".gitignore"), "vendor/\n")
+
+	m := New(root, nil, nil)
+	if !m.IgnoredPath("vendor/lib/readme.md", false) {
+		t.Error("file under ignored directory should be ignored")
+	}
+	if !m.IgnoredPath("node_modules/pkg/readme.md", false) {
+		t.Error("file under node_modules should be ignored")
+	}
+	if m.IgnoredPath("docs/readme.md", false) {
+		t.Error("docs/readme.md should not be ignored")
+	}
+}
+
+func TestMatcher_IncludeExclude(t *testing.T) {
+	m := New(t.TempDir(), []string{"docs/**"}, []string{"docs/generated/**", "**/CHANGELOG.md"})
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"docs/guide.md", false, false},
+		{"README.md", false, true}, // not included
+		{"src", true, false},       // include does not prune directories
+		{"docs/generated", true, true},
+		{"docs/generated/api.md", false, true},
+		{"docs/CHANGELOG.md", false, true},
+	}
+	for _, tt := range tests {
+		if got := m.Ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestMatcher_Invalidate(t *testing.T) {
+	root := t.TempDir()
+	m := New(root, nil, nil)
+
+	if m.Ignored("notes.md", false) {
+		t.Fatal("notes.md should not be ignored before .kbignore exists")
+	}
+	writeFile(t, filepath.Join(root, ".kbignore"), "notes.md\n")
+	m.Invalidate(".")
+	if !m.Ignored("notes.md", false) {
+		t.Error("notes.md should be ignored after Invalidate")
+	}
+}
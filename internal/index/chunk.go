@@ -0,0 +1,316 @@
+package index
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/esakat/markdown-kb/internal/parser"
+)
+
+// DefaultChunkTokens is the default upper bound on estimated tokens per chunk.
+const DefaultChunkTokens = 512
+
+// maxChunkCandidates limits how many ranked chunks are considered when
+// filling a token budget.
+const maxChunkCandidates = 200
+
+// Chunk is a section of a document sized for LLM context building.
+type Chunk struct {
+	ID        string  `json:"id"`
+	Path      string  `json:"path"`
+	Title     string  `json:"title,omitempty"`
+	Heading   string  `json:"heading,omitempty"`
+	Text      string  `json:"text"`
+	Tokens    int     `json:"tokens"`
+	StartLine int     `json:"start_line"` // 1-based line in the source file
+	EndLine   int     `json:"end_line"`   // inclusive
+	Score     float64 `json:"score,omitempty"`
+}
+
+// EstimateTokens returns a rough token count for s. ASCII text is counted
+// at about four characters per token and every other rune (CJK etc.) as
+// one token, which is close enough for budgeting without a tokenizer.
+func EstimateTokens(s string) int {
+	ascii, other := 0, 0
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// chunkUnit is a run of body lines that should not be split further unless
+// it alone exceeds the token limit: a heading, a paragraph or a fenced block.
+type chunkUnit struct {
+	start, end int // 0-based line indexes, inclusive
+	heading    string
+	isHeading  bool
+}
+
+// SplitChunks splits a document body into chunks at headings and paragraph
+// boundaries so that each chunk stays under maxTokens where possible.
+// bodyOffset is the number of file lines preceding the body, used to report
+// file-relative line ranges. Chunk IDs are derived from the path and chunk
+// text, so unchanged chunks keep their IDs across re-indexing.
+func SplitChunks(path, body string, bodyOffset, maxTokens int) []Chunk {
+	if maxTokens <= 0 {
+		maxTokens = DefaultChunkTokens
+	}
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	units := splitUnits(lines)
+
+	var chunks []Chunk
+	seen := make(map[string]int)
+	emit := func(start, end int, heading string) {
+		text := strings.Join(lines[start:end+1], "\n")
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+		chunks = append(chunks, Chunk{
+			ID:        chunkID(path, text, seen),
+			Path:      path,
+			Heading:   heading,
+			Text:      text,
+			Tokens:    EstimateTokens(text),
+			StartLine: bodyOffset + start + 1,
+			EndLine:   bodyOffset + end + 1,
+		})
+	}
+
+	curStart, curEnd, curTokens := -1, -1, 0
+	curHeading := ""
+	flush := func() {
+		if curStart >= 0 {
+			emit(curStart, curEnd, curHeading)
+		}
+		curStart, curEnd, curTokens = -1, -1, 0
+	}
+
+	for _, u := range units {
+		tokens := EstimateTokens(strings.Join(lines[u.start:u.end+1], "\n"))
+		if u.isHeading {
+			flush()
+			curHeading = u.heading
+		}
+		if curStart >= 0 && curTokens+tokens > maxTokens {
+			flush()
+		}
+		if tokens > maxTokens {
+			// Oversized paragraph or code block: fall back to line packing.
+			flush()
+			for i := u.start; i <= u.end; i++ {
+				lt := EstimateTokens(lines[i])
+				if curStart >= 0 && curTokens+lt > maxTokens {
+					flush()
+				}
+				if curStart < 0 {
+					curStart = i
+				}
+				curEnd = i
+				curTokens += lt
+			}
+			flush()
+			continue
+		}
+		if curStart < 0 {
+			curStart = u.start
+		}
+		curEnd = u.end
+		curTokens += tokens
+	}
+	flush()
+
+	return chunks
+}
+
+// splitUnits groups body lines into headings, paragraphs and fenced blocks.
+// Blank lines outside fences separate units and are not part of any unit.
+func splitUnits(lines []string) []chunkUnit {
+	var units []chunkUnit
+	heading := ""
+	start := -1
+	fence := ""
+
+	closeUnit := func(end int) {
+		if start >= 0 {
+			units = append(units, chunkUnit{start: start, end: end, heading: heading})
+			start = -1
+		}
+	}
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+				closeUnit(i)
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			closeUnit(i - 1)
+			start = i
+			fence = trimmed[:3]
+			continue
+		}
+		if _, h, ok := parser.ParseHeading(line); ok {
+			closeUnit(i - 1)
+			heading = h
+			units = append(units, chunkUnit{start: i, end: i, heading: h, isHeading: true})
+			continue
+		}
+		if trimmed == "" {
+			closeUnit(i - 1)
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	closeUnit(len(lines) - 1)
+
+	return units
+}
+
+// SetChunkTokens sets the maximum estimated tokens per chunk for documents
+// indexed afterwards. Values <= 0 restore DefaultChunkTokens.
+func (s *Store) SetChunkTokens(n int) {
+	if n <= 0 {
+		n = DefaultChunkTokens
+	}
+	s.chunkTokens = n
+}
+
+// chunkID derives a stable chunk ID from the document path and chunk text.
+// seen counts earlier IDs within the same document; repeated text gets an
+// occurrence suffix.
+func chunkID(path, text string, seen map[string]int) string {
+	sum := sha1.Sum([]byte(path + "\x00" + text))
+	base := hex.EncodeToString(sum[:8])
+	id := base
+	if n := seen[base]; n > 0 {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	seen[base]++
+	return id
+}
+
+// indexChunks replaces the chunks of a document within tx.
+func (s *Store) indexChunks(tx *sql.Tx, path, body string, bodyOffset int) error {
+	if _, err := tx.Exec("DELETE FROM chunks WHERE path = ?", path); err != nil {
+		return fmt.Errorf("deleting chunks: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM chunks_fts WHERE path = ?", path); err != nil {
+		return fmt.Errorf("deleting chunk FTS entries: %w", err)
+	}
+
+	for i, c := range SplitChunks(path, body, bodyOffset, s.chunkTokens) {
+		_, err := tx.Exec(`
+			INSERT INTO chunks (id, path, ordinal, heading, text, tokens, start_line, end_line)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, c.ID, c.Path, i, c.Heading, c.Text, c.Tokens, c.StartLine, c.EndLine)
+		if err != nil {
+			return fmt.Errorf("inserting chunk: %w", err)
+		}
+		_, err = tx.Exec(`
+			INSERT INTO chunks_fts (id, path, heading, text)
+			VALUES (?, ?, ?, ?)
+		`, c.ID, c.Path, c.Heading, c.Text)
+		if err != nil {
+			return fmt.Errorf("inserting chunk FTS entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListChunks returns the chunks of a document in document order.
+func (s *Store) ListChunks(path string) ([]Chunk, error) {
+	rows, err := s.db.Query(`
+		SELECT c.id, c.path, d.title, c.heading, c.text, c.tokens, c.start_line, c.end_line
+		FROM chunks c
+		JOIN documents d ON d.path = c.path
+		WHERE c.path = ?
+		ORDER BY c.ordinal
+	`, path)
+	if err != nil {
+		return nil, fmt.Errorf("listing chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []Chunk
+	for rows.Next() {
+		var c Chunk
+		if err := rows.Scan(&c.ID, &c.Path, &c.Title, &c.Heading, &c.Text, &c.Tokens, &c.StartLine, &c.EndLine); err != nil {
+			return nil, fmt.Errorf("scanning chunk: %w", err)
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks, rows.Err()
+}
+
+// SearchChunks runs a full-text search over chunks and returns the
+// best-ranked chunks whose combined token estimate fits within budget,
+// along with the tokens used. Chunks that would overflow the budget are
+// skipped in favour of smaller, lower-ranked ones.
+func (s *Store) SearchChunks(query string, filters map[string]string, budget int) ([]Chunk, int, error) {
+	if query == "" || budget <= 0 {
+		return nil, 0, nil
+	}
+
+	var filterClauses []string
+	var filterArgs []any
+
+	for key, val := range filters {
+		filterClauses = append(filterClauses, "json_extract(d.meta, ?) LIKE ?")
+		filterArgs = append(filterArgs, "$."+key, "%"+val+"%")
+	}
+
+	filterSQL := ""
+	if len(filterClauses) > 0 {
+		filterSQL = " AND " + strings.Join(filterClauses, " AND ")
+	}
+
+	searchQuery := fmt.Sprintf(`
+		SELECT c.id, c.path, d.title, c.heading, c.text, c.tokens, c.start_line, c.end_line,
+			   bm25(chunks_fts) as score
+		FROM chunks_fts f
+		JOIN chunks c ON c.id = f.id
+		JOIN documents d ON d.path = c.path
+		WHERE chunks_fts MATCH ?%s
+		ORDER BY bm25(chunks_fts)
+		LIMIT ?
+	`, filterSQL)
+
+	args := append([]any{query}, filterArgs...)
+	args = append(args, maxChunkCandidates)
+
+	rows, err := s.db.Query(searchQuery, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("searching chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var results []Chunk
+	used := 0
+	for rows.Next() {
+		var c Chunk
+		if err := rows.Scan(&c.ID, &c.Path, &c.Title, &c.Heading, &c.Text, &c.Tokens, &c.StartLine, &c.EndLine, &c.Score); err != nil {
+			return nil, 0, fmt.Errorf("scanning chunk: %w", err)
+		}
+		if used+c.Tokens > budget {
+			continue
+		}
+		used += c.Tokens
+		results = append(results, c)
+	}
+
+	return results, used, rows.Err()
+}
@@ -0,0 +1,169 @@
+package index
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/esakat/markdown-kb/internal/scanner"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	if got := EstimateTokens(""); got != 0 {
+		t.Errorf("EstimateTokens(\"\") = %d, want 0", got)
+	}
+	if got := EstimateTokens("abcdefgh"); got != 2 {
+		t.Errorf("EstimateTokens(ascii) = %d, want 2", got)
+	}
+	if got := EstimateTokens("日本語"); got != 3 {
+		t.Errorf("EstimateTokens(japanese) = %d, want 3", got)
+	}
+}
+
+func TestSplitChunks_SplitsAtHeadings(t *testing.T) {
+	body := "# Intro\n\nFirst paragraph.\n\n## Setup\n\nInstall it.\n\nRun it.\n"
+	chunks := SplitChunks("guide.md", body, 0, 512)
+
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d: %+v", len(chunks), chunks)
+	}
+	if chunks[0].Heading != "Intro" || chunks[1].Heading != "Setup" {
+		t.Errorf("headings = %q, %q", chunks[0].Heading, chunks[1].Heading)
+	}
+	if chunks[0].StartLine != 1 || chunks[0].EndLine != 3 {
+		t.Errorf("chunk[0] lines = %d-%d, want 1-3", chunks[0].StartLine, chunks[0].EndLine)
+	}
+	if chunks[1].StartLine != 5 || chunks[1].EndLine != 9 {
+		t.Errorf("chunk[1] lines = %d-%d, want 5-9", chunks[1].StartLine, chunks[1].EndLine)
+	}
+	if !strings.HasPrefix(chunks[1].Text, "## Setup") {
+		t.Errorf("chunk[1] text = %q, want heading first", chunks[1].Text)
+	}
+}
+
+func TestSplitChunks_BodyOffset(t *testing.T) {
+	chunks := SplitChunks("a.md", "Hello.\n", 4, 512)
+	if len(chunks) != 1 {
+		t.Fatalf("expected 1 chunk, got %d", len(chunks))
+	}
+	if chunks[0].StartLine != 5 || chunks[0].EndLine != 5 {
+		t.Errorf("lines = %d-%d, want 5-5", chunks[0].StartLine, chunks[0].EndLine)
+	}
+}
+
+func TestSplitChunks_RespectsMaxTokens(t *testing.T) {
+	para := strings.Repeat("word ", 40) // ~50 tokens
+	body := "# Big\n\n" + para + "\n\n" + para + "\n\n" + para + "\n"
+	chunks := SplitChunks("big.md", body, 0, 120)
+
+	if len(chunks) < 2 {
+		t.Fatalf("expected section to be split, got %d chunks", len(chunks))
+	}
+	for _, c := range chunks {
+		if c.Tokens > 120 {
+			t.Errorf("chunk %s has %d tokens, want <= 120", c.ID, c.Tokens)
+		}
+		if c.Heading != "Big" {
+			t.Errorf("chunk heading = %q, want %q", c.Heading, "Big")
+		}
+	}
+}
+
+func TestSplitChunks_KeepsCodeFenceTogether(t *testing.T) {
+	body := "Intro.\n\n```go\nfunc a() {}\n\n# not a heading\n```\n"
+	chunks := SplitChunks("code.md", body, 0, 512)
+	if len(chunks) != 1 {
+		t.Fatalf("expected 1 chunk, got %d: %+v", len(chunks), chunks)
+	}
+	if chunks[0].Heading != "" {
+		t.Errorf("heading = %q, want empty (fenced # is not a heading)", chunks[0].Heading)
+	}
+}
+
+func TestSplitChunks_StableIDs(t *testing.T) {
+	body := "# A\n\nAlpha.\n\n# B\n\nBeta.\n"
+	first := SplitChunks("doc.md", body, 0, 512)
+	second := SplitChunks("doc.md", "# New\n\nPrepended.\n\n"+body, 0, 512)
+
+	ids := make(map[string]bool)
+	for _, c := range second {
+		ids[c.ID] = true
+	}
+	for _, c := range first {
+		if !ids[c.ID] {
+			t.Errorf("chunk %q (%s) changed ID after unrelated edit", c.ID, c.Heading)
+		}
+	}
+}
+
+func TestSplitChunks_DuplicateTextUniqueIDs(t *testing.T) {
+	chunks := SplitChunks("dup.md", "# Same\n\n# Same\n", 0, 512)
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(chunks))
+	}
+	if chunks[0].ID == chunks[1].ID {
+		t.Errorf("duplicate chunk IDs: %q", chunks[0].ID)
+	}
+}
+
+func TestSearchChunks_Budget(t *testing.T) {
+	store := newTestStore(t)
+	store.SetChunkTokens(20)
+
+	para := "Kubernetes deployment notes for the cluster."
+	doc := scanner.Document{
+		RelPath:     "ops.md",
+		Frontmatter: map[string]any{"title": "Ops"},
+		Body:        "# One\n\n" + para + "\n\n# Two\n\n" + para + "\n\n# Three\n\n" + para + "\n",
+		BodyOffset:  3,
+		ModTime:     time.Now(),
+	}
+	if err := store.IndexDocument(doc); err != nil {
+		t.Fatalf("IndexDocument() error = %v", err)
+	}
+
+	all, used, err := store.SearchChunks("Kubernetes", nil, 1000)
+	if err != nil {
+		t.Fatalf("SearchChunks() error = %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(all))
+	}
+	if all[0].Title != "Ops" || all[0].Path != "ops.md" {
+		t.Errorf("chunk source = %q/%q", all[0].Path, all[0].Title)
+	}
+	if all[0].StartLine <= 3 {
+		t.Errorf("StartLine = %d, want file-relative line after frontmatter", all[0].StartLine)
+	}
+
+	limited, limitedUsed, err := store.SearchChunks("Kubernetes", nil, all[0].Tokens+1)
+	if err != nil {
+		t.Fatalf("SearchChunks() error = %v", err)
+	}
+	if len(limited) != 1 {
+		t.Errorf("expected 1 chunk within budget, got %d", len(limited))
+	}
+	if limitedUsed > all[0].Tokens+1 || limitedUsed >= used {
+		t.Errorf("used tokens = %d, want within budget", limitedUsed)
+	}
+}
+
+func TestRemoveDocument_RemovesChunks(t *testing.T) {
+	store := newTestStore(t)
+	indexSampleDocs(t, store)
+
+	if err := store.RemoveDocument("guide.md"); err != nil {
+		t.Fatalf("RemoveDocument() error = %v", err)
+	}
+	chunks, err := store.ListChunks("guide.md")
+	if err != nil {
+		t.Fatalf("ListChunks() error = %v", err)
+	}
+	if len(chunks) != 0 {
+		t.Errorf("expected no chunks after removal, got %d", len(chunks))
+	}
+	results, _, _ := store.SearchChunks("programming", nil, 1000)
+	if len(results) != 0 {
+		t.Errorf("expected no chunk hits after removal, got %d", len(results))
+	}
+}
@@ -2,6 +2,7 @@ package index
 
 import (
 	"encoding/json"
+	pathpkg "path"
 
 	"github.com/esakat/markdown-kb/internal/parser"
 )
@@ -68,7 +69,7 @@ func (s *Store) BuildGraph() (*GraphData, error) {
 			}
 		}
 
-		links := parser.ExtractLinks(body)
+		links := parser.ExtractLinksWithExtensions(body, s.extensions)
 
 		docs = append(docs, docInfo{
 			path:  path,
@@ -112,6 +113,7 @@ func (s *Store) BuildGraph() (*GraphData, error) {
 	// Link edges: doc A links to doc B
 	for _, d := range docs {
 		for _, link := range d.links {
+			link = s.resolveLink(link, pathSet)
 			if pathSet[link] && link != d.path {
 				addEdge(GraphEdge{
 					Source: d.path,
@@ -158,3 +160,74 @@ func (s *Store) BuildGraph() (*GraphData, error) {
 		Edges: edges,
 	}, nil
 }
+
+// Backlinks returns the documents whose body links to target. Links are
+// matched both as repository-relative paths (as in [[wiki-links]]) and
+// relative to the linking document's directory.
+func (s *Store) Backlinks(target string) ([]GraphNode, error) {
+	rows, err := s.db.Query("SELECT path, title, meta, body FROM documents ORDER BY path")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type docInfo struct {
+		path, title, metaJSON, body string
+	}
+	var docs []docInfo
+	pathSet := make(map[string]bool)
+	for rows.Next() {
+		var d docInfo
+		if err := rows.Scan(&d.path, &d.title, &d.metaJSON, &d.body); err != nil {
+			continue
+		}
+		docs = append(docs, d)
+		pathSet[d.path] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var nodes []GraphNode
+	for _, d := range docs {
+		if d.path == target {
+			continue
+		}
+
+		for _, link := range parser.ExtractLinksWithExtensions(d.body, s.extensions) {
+			if s.resolveLink(link, pathSet) == target ||
+				s.resolveLink(pathpkg.Join(pathpkg.Dir(d.path), link), pathSet) == target {
+				tags := extractTags(d.metaJSON)
+				if tags == nil {
+					tags = []string{}
+				}
+				nodes = append(nodes, GraphNode{Path: d.path, Title: d.title, Tags: tags})
+				break
+			}
+		}
+	}
+
+	return nodes, nil
+}
+
+// SetExtensions sets the accepted document extensions used to extract and
+// resolve links. An empty list restores parser.DefaultExtensions.
+func (s *Store) SetExtensions(exts []string) {
+	s.extensions = parser.NormalizeExtensions(exts)
+}
+
+// resolveLink maps a link to an indexed path. Links that don't match a
+// document exactly are retried with each accepted extension, so that
+// [[page]] finds page.mdx as well as page.md.
+func (s *Store) resolveLink(link string, pathSet map[string]bool) string {
+	if pathSet[link] {
+		return link
+	}
+	base := parser.TrimExtension(link, s.extensions)
+	for _, ext := range s.extensions {
+		if pathSet[base+ext] {
+			return base + ext
+		}
+	}
+	return link
+}
@@ -150,3 +150,82 @@ func TestBuildGraph_LinkToNonexistentDoc(t *testing.T) {
 		t.Errorf("expected 0 edges (link target doesn't exist), got %d", len(graph.Edges))
 	}
 }
+
+func TestBacklinks(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Now()
+
+	store.IndexDocument(scanner.Document{
+		RelPath:     "guides/setup.md",
+		Frontmatter: map[string]any{"title": "Setup"},
+		Body:        "# Setup",
+		ModTime:     now,
+	})
+	store.IndexDocument(scanner.Document{
+		RelPath:     "index.md",
+		Frontmatter: map[string]any{"title": "Index", "tags": []any{"top"}},
+		Body:        "See [[guides/setup]].",
+		ModTime:     now,
+	})
+	store.IndexDocument(scanner.Document{
+		RelPath:     "guides/intro.md",
+		Frontmatter: map[string]any{"title": "Intro"},
+		Body:        "Next: [setup](setup.md).",
+		ModTime:     now,
+	})
+	store.IndexDocument(scanner.Document{
+		RelPath: "other.md",
+		Body:    "No links.",
+		ModTime: now,
+	})
+
+	nodes, err := store.Backlinks("guides/setup.md")
+	if err != nil {
+		t.Fatalf("Backlinks: %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("expected 2 backlinks, got %d: %+v", len(nodes), nodes)
+	}
+	if nodes[0].Path != "guides/intro.md" || nodes[1].Path != "index.md" {
+		t.Errorf("backlinks = %s, %s", nodes[0].Path, nodes[1].Path)
+	}
+	if len(nodes[1].Tags) != 1 || nodes[1].Tags[0] != "top" {
+		t.Errorf("tags = %v, want [top]", nodes[1].Tags)
+	}
+}
+
+func TestBuildGraph_Extensions(t *testing.T) {
+	store := newTestStore(t)
+	store.SetExtensions([]string{".md", ".mdx", ".markdown"})
+	now := time.Now()
+
+	store.IndexDocument(scanner.Document{RelPath: "page.mdx", Body: "# Page", ModTime: now})
+	store.IndexDocument(scanner.Document{RelPath: "notes.markdown", Body: "# Notes", ModTime: now})
+	store.IndexDocument(scanner.Document{
+		RelPath: "index.md",
+		Body:    "See [[page]] and [notes](notes.markdown).",
+		ModTime: now,
+	})
+
+	graph, err := store.BuildGraph()
+	if err != nil {
+		t.Fatalf("BuildGraph: %v", err)
+	}
+	targets := make(map[string]bool)
+	for _, e := range graph.Edges {
+		if e.Type == "link" && e.Source == "index.md" {
+			targets[e.Target] = true
+		}
+	}
+	if !targets["page.mdx"] || !targets["notes.markdown"] {
+		t.Errorf("link targets = %v, want page.mdx and notes.markdown", targets)
+	}
+
+	nodes, err := store.Backlinks("page.mdx")
+	if err != nil {
+		t.Fatalf("Backlinks: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].Path != "index.md" {
+		t.Errorf("backlinks = %+v, want index.md", nodes)
+	}
+}
@@ -3,10 +3,12 @@ package index
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/esakat/markdown-kb/internal/parser"
 	"github.com/esakat/markdown-kb/internal/scanner"
 
 	_ "modernc.org/sqlite"
@@ -14,7 +16,9 @@ import (
 
 // Store provides full-text search and metadata indexing using SQLite FTS5.
 type Store struct {
-	db *sql.DB
+	db          *sql.DB
+	chunkTokens int
+	extensions  []string
 }
 
 // SearchResult represents a single search hit.
@@ -38,7 +42,8 @@ type DocumentSummary struct {
 // DocumentDetail represents a full document including body.
 type DocumentDetail struct {
 	DocumentSummary
-	Body string `json:"body"`
+	Body     string `json:"body"`
+	Encoding string `json:"encoding,omitempty"` // original encoding when transcoded to UTF-8
 }
 
 // TagCount represents a tag with its occurrence count.
@@ -61,7 +66,13 @@ CREATE TABLE IF NOT EXISTS documents (
     meta     TEXT,
     body     TEXT,
     mod_time TEXT,
-    size     INTEGER
+    size     INTEGER,
+    encoding TEXT
+);
+
+CREATE TABLE IF NOT EXISTS diagnostics (
+    path    TEXT PRIMARY KEY,
+    message TEXT
 );
 
 CREATE VIRTUAL TABLE IF NOT EXISTS documents_fts USING fts5(
@@ -71,6 +82,27 @@ CREATE VIRTUAL TABLE IF NOT EXISTS documents_fts USING fts5(
     meta,
     tokenize='trigram'
 );
+
+CREATE TABLE IF NOT EXISTS chunks (
+    id         TEXT PRIMARY KEY,
+    path       TEXT,
+    ordinal    INTEGER,
+    heading    TEXT,
+    text       TEXT,
+    tokens     INTEGER,
+    start_line INTEGER,
+    end_line   INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS chunks_path ON chunks(path);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS chunks_fts USING fts5(
+    id UNINDEXED,
+    path UNINDEXED,
+    heading,
+    text,
+    tokenize='trigram'
+);
 `
 
 func openDB(dsn string) (*Store, error) {
@@ -90,7 +122,7 @@ func openDB(dsn string) (*Store, error) {
 		return nil, fmt.Errorf("initializing schema: %w", err)
 	}
 
-	return &Store{db: db}, nil
+	return &Store{db: db, chunkTokens: DefaultChunkTokens, extensions: parser.DefaultExtensions}, nil
 }
 
 // New creates a new index store with an in-memory SQLite database.
@@ -105,37 +137,138 @@ func NewWithPath(path string) (*Store, error) {
 
 // IndexDocument adds or updates a document in the index (UPSERT).
 func (s *Store) IndexDocument(doc scanner.Document) error {
-	title, _ := doc.Frontmatter["title"].(string)
-
-	metaJSON, err := json.Marshal(doc.Frontmatter)
+	tx, err := s.db.Begin()
 	if err != nil {
-		metaJSON = []byte("{}")
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.indexDocument(tx, doc); err != nil {
+		return err
 	}
 
+	return tx.Commit()
+}
+
+// indexBatchSize is the number of documents written per transaction by
+// IndexBatch.
+const indexBatchSize = 1000
+
+// IndexBatch adds or updates many documents using a few large transactions
+// instead of one per document. A document that fails to index is rolled
+// back on its own and reported in the returned error; the rest of the batch
+// is still written. progress, if non-nil, is called after each document.
+func (s *Store) IndexBatch(docs []scanner.Document, progress func(done, total int)) error {
+	var errs []error
+
+	for start := 0; start < len(docs); start += indexBatchSize {
+		end := min(start+indexBatchSize, len(docs))
+
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("beginning transaction: %w", err)
+		}
+
+		for i, doc := range docs[start:end] {
+			if _, err := tx.Exec("SAVEPOINT doc"); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("creating savepoint: %w", err)
+			}
+			if err := s.indexDocument(tx, doc); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", doc.RelPath, err))
+				if _, err := tx.Exec("ROLLBACK TO doc"); err != nil {
+					tx.Rollback()
+					return fmt.Errorf("rolling back %q: %w", doc.RelPath, err)
+				}
+			}
+			if _, err := tx.Exec("RELEASE doc"); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("releasing savepoint: %w", err)
+			}
+			if progress != nil {
+				progress(start+i+1, len(docs))
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("committing batch: %w", err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ApplyChanges indexes docs and removes the documents at removed in a
+// single transaction, so that readers see all of a batch of changes or none
+// of it. A document that fails to index is rolled back on its own and
+// reported in the returned error.
+func (s *Store) ApplyChanges(docs []scanner.Document, removed []string) error {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return fmt.Errorf("beginning transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	for _, path := range removed {
+		if err := removeDocument(tx, path); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	var errs []error
+	for _, doc := range docs {
+		if _, err := tx.Exec("SAVEPOINT doc"); err != nil {
+			return fmt.Errorf("creating savepoint: %w", err)
+		}
+		if err := s.indexDocument(tx, doc); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", doc.RelPath, err))
+			if _, err := tx.Exec("ROLLBACK TO doc"); err != nil {
+				return fmt.Errorf("rolling back %q: %w", doc.RelPath, err)
+			}
+		}
+		if _, err := tx.Exec("RELEASE doc"); err != nil {
+			return fmt.Errorf("releasing savepoint: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing changes: %w", err)
+	}
+	return errors.Join(errs...)
+}
+
+// indexDocument writes doc within tx.
+func (s *Store) indexDocument(tx *sql.Tx, doc scanner.Document) error {
+	title, _ := doc.Frontmatter["title"].(string)
+
+	metaJSON, err := json.Marshal(doc.Frontmatter)
+	if err != nil {
+		metaJSON = []byte("{}")
+	}
+
 	// Delete existing FTS entry
 	tx.Exec("DELETE FROM documents_fts WHERE path = ?", doc.RelPath)
 
 	// UPSERT into documents table
 	_, err = tx.Exec(`
-		INSERT INTO documents (path, title, meta, body, mod_time, size)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO documents (path, title, meta, body, mod_time, size, encoding)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(path) DO UPDATE SET
 			title = excluded.title,
 			meta = excluded.meta,
 			body = excluded.body,
 			mod_time = excluded.mod_time,
-			size = excluded.size
-	`, doc.RelPath, title, string(metaJSON), doc.Body, doc.ModTime.Format(time.RFC3339), doc.Size)
+			size = excluded.size,
+			encoding = excluded.encoding
+	`, doc.RelPath, title, string(metaJSON), doc.Body, doc.ModTime.Format(time.RFC3339), doc.Size, doc.Encoding)
 	if err != nil {
 		return fmt.Errorf("upserting document: %w", err)
 	}
 
+	if _, err := tx.Exec("DELETE FROM diagnostics WHERE path = ?", doc.RelPath); err != nil {
+		return fmt.Errorf("clearing diagnostic: %w", err)
+	}
+
 	// Insert into FTS index
 	_, err = tx.Exec(`
 		INSERT INTO documents_fts (path, title, body, meta)
@@ -145,7 +278,7 @@ func (s *Store) IndexDocument(doc scanner.Document) error {
 		return fmt.Errorf("inserting FTS entry: %w", err)
 	}
 
-	return tx.Commit()
+	return s.indexChunks(tx, doc.RelPath, doc.Body, doc.BodyOffset)
 }
 
 // RemoveDocument deletes a document from the index.
@@ -156,14 +289,162 @@ func (s *Store) RemoveDocument(path string) error {
 	}
 	defer tx.Rollback()
 
+	if err := removeDocument(tx, path); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func removeDocument(tx *sql.Tx, path string) error {
 	if _, err := tx.Exec("DELETE FROM documents WHERE path = ?", path); err != nil {
 		return fmt.Errorf("deleting document: %w", err)
 	}
 	if _, err := tx.Exec("DELETE FROM documents_fts WHERE path = ?", path); err != nil {
 		return fmt.Errorf("deleting FTS entry: %w", err)
 	}
+	if _, err := tx.Exec("DELETE FROM chunks WHERE path = ?", path); err != nil {
+		return fmt.Errorf("deleting chunks: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM chunks_fts WHERE path = ?", path); err != nil {
+		return fmt.Errorf("deleting chunk FTS entries: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM diagnostics WHERE path = ?", path); err != nil {
+		return fmt.Errorf("deleting diagnostic: %w", err)
+	}
+	return nil
+}
 
-	return tx.Commit()
+// RemoveDir deletes every document and diagnostic under the directory dir
+// and returns the removed document paths.
+func (s *Store) RemoveDir(dir string) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	docs, err := pathsUnder(tx, "documents", dir)
+	if err != nil {
+		return nil, err
+	}
+	diags, err := pathsUnder(tx, "diagnostics", dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range append(docs, diags...) {
+		if err := removeDocument(tx, p); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// RenameDir moves every document and diagnostic under the directory from
+// to the same relative location under to, replacing documents already
+// there, and returns the new document paths. Content isn't re-read.
+func (s *Store) RenameDir(from, to string) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	docs, err := pathsUnder(tx, "documents", from)
+	if err != nil {
+		return nil, err
+	}
+	diags, err := pathsUnder(tx, "diagnostics", from)
+	if err != nil {
+		return nil, err
+	}
+
+	moved := make([]string, 0, len(docs))
+	for _, oldPath := range docs {
+		newPath := to + strings.TrimPrefix(oldPath, from)
+		if err := removeDocument(tx, newPath); err != nil {
+			return nil, err
+		}
+		if err := renameDocument(tx, oldPath, newPath); err != nil {
+			return nil, err
+		}
+		moved = append(moved, newPath)
+	}
+	for _, oldPath := range diags {
+		newPath := to + strings.TrimPrefix(oldPath, from)
+		if _, err := tx.Exec("UPDATE diagnostics SET path = ? WHERE path = ?", newPath, oldPath); err != nil {
+			return nil, fmt.Errorf("renaming diagnostic: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+// renameDocument re-keys one document within tx. Chunk IDs include the
+// path, so they are derived again.
+func renameDocument(tx *sql.Tx, oldPath, newPath string) error {
+	if _, err := tx.Exec("UPDATE documents SET path = ? WHERE path = ?", newPath, oldPath); err != nil {
+		return fmt.Errorf("renaming document: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE documents_fts SET path = ? WHERE path = ?", newPath, oldPath); err != nil {
+		return fmt.Errorf("renaming FTS entry: %w", err)
+	}
+
+	rows, err := tx.Query("SELECT id, text FROM chunks WHERE path = ? ORDER BY ordinal", oldPath)
+	if err != nil {
+		return fmt.Errorf("querying chunks: %w", err)
+	}
+	var ids, texts []string
+	for rows.Next() {
+		var id, text string
+		if err := rows.Scan(&id, &text); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+		texts = append(texts, text)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	seen := make(map[string]int)
+	for i, oldID := range ids {
+		newID := chunkID(newPath, texts[i], seen)
+		if _, err := tx.Exec("UPDATE chunks SET id = ?, path = ? WHERE id = ?", newID, newPath, oldID); err != nil {
+			return fmt.Errorf("renaming chunk: %w", err)
+		}
+		if _, err := tx.Exec("UPDATE chunks_fts SET id = ?, path = ? WHERE id = ?", newID, newPath, oldID); err != nil {
+			return fmt.Errorf("renaming chunk FTS entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// pathsUnder returns the paths in table that lie under the directory dir.
+func pathsUnder(tx *sql.Tx, table, dir string) ([]string, error) {
+	// Paths under "dir/" sort between "dir/" and "dir0" ('0' follows '/').
+	rows, err := tx.Query("SELECT path FROM "+table+" WHERE path > ? AND path < ? ORDER BY path", dir+"/", dir+"0")
+	if err != nil {
+		return nil, fmt.Errorf("querying %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, rows.Err()
 }
 
 // Search performs a full-text search and returns matching documents ordered by BM25 score.
@@ -324,16 +605,43 @@ func (s *Store) ListDocuments(limit, offset int) ([]DocumentSummary, int, error)
 	return docs, total, rows.Err()
 }
 
+// ListDocumentDetails returns every document with its body, ordered by
+// path, in a single query.
+func (s *Store) ListDocumentDetails() ([]DocumentDetail, error) {
+	rows, err := s.db.Query(`
+		SELECT path, title, meta, body, mod_time, size, COALESCE(encoding, '')
+		FROM documents
+		ORDER BY path
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("listing documents: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []DocumentDetail
+	for rows.Next() {
+		var d DocumentDetail
+		var metaJSON, modTimeStr string
+		if err := rows.Scan(&d.Path, &d.Title, &metaJSON, &d.Body, &modTimeStr, &d.Size, &d.Encoding); err != nil {
+			return nil, fmt.Errorf("scanning document: %w", err)
+		}
+		json.Unmarshal([]byte(metaJSON), &d.Meta)
+		d.ModTime, _ = time.Parse(time.RFC3339, modTimeStr)
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
 // GetDocument retrieves a single document by path.
 func (s *Store) GetDocument(path string) (*DocumentDetail, error) {
 	var d DocumentDetail
 	var metaJSON, modTimeStr string
 
 	err := s.db.QueryRow(`
-		SELECT path, title, meta, body, mod_time, size
+		SELECT path, title, meta, body, mod_time, size, COALESCE(encoding, '')
 		FROM documents
 		WHERE path = ?
-	`, path).Scan(&d.Path, &d.Title, &metaJSON, &d.Body, &modTimeStr, &d.Size)
+	`, path).Scan(&d.Path, &d.Title, &metaJSON, &d.Body, &modTimeStr, &d.Size, &d.Encoding)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -470,3 +778,39 @@ func (s *Store) Close() error {
 	}
 	return nil
 }
+
+// SetDiagnostic records that the file at d.Path could not be loaded,
+// replacing any earlier diagnostic for it. The path is removed from the
+// document index so stale content isn't served.
+func (s *Store) SetDiagnostic(d scanner.Diagnostic) error {
+	if err := s.RemoveDocument(d.Path); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO diagnostics (path, message) VALUES (?, ?)
+		ON CONFLICT(path) DO UPDATE SET message = excluded.message
+	`, d.Path, d.Message)
+	if err != nil {
+		return fmt.Errorf("recording diagnostic: %w", err)
+	}
+	return nil
+}
+
+// ListDiagnostics returns files that could not be loaded, ordered by path.
+func (s *Store) ListDiagnostics() ([]scanner.Diagnostic, error) {
+	rows, err := s.db.Query("SELECT path, message FROM diagnostics ORDER BY path")
+	if err != nil {
+		return nil, fmt.Errorf("querying diagnostics: %w", err)
+	}
+	defer rows.Close()
+
+	diags := []scanner.Diagnostic{}
+	for rows.Next() {
+		var d scanner.Diagnostic
+		if err := rows.Scan(&d.Path, &d.Message); err != nil {
+			return nil, err
+		}
+		diags = append(diags, d)
+	}
+	return diags, rows.Err()
+}
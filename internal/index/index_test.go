@@ -1,6 +1,8 @@
 package index
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -85,6 +87,75 @@ func TestIndexDocument_AndGetDocument(t *testing.T) {
 	}
 }
 
+func TestIndexBatch(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Now()
+
+	// More than one transaction's worth of documents.
+	n := indexBatchSize + 5
+	docs := make([]scanner.Document, n)
+	for i := range docs {
+		docs[i] = scanner.Document{
+			RelPath:     fmt.Sprintf("docs/%04d.md", i),
+			Frontmatter: map[string]any{"title": fmt.Sprintf("Doc %d", i)},
+			Body:        fmt.Sprintf("# Doc %d\n\nbatch content %d", i, i),
+			ModTime:     now,
+		}
+	}
+
+	var calls, last int
+	err := store.IndexBatch(docs, func(done, total int) {
+		calls++
+		last = done
+		if total != n {
+			t.Errorf("total = %d, want %d", total, n)
+		}
+	})
+	if err != nil {
+		t.Fatalf("IndexBatch() error = %v", err)
+	}
+	if calls != n || last != n {
+		t.Errorf("progress calls = %d, last = %d, want %d", calls, last, n)
+	}
+
+	_, total, err := store.ListDocuments(0, 0)
+	if err != nil {
+		t.Fatalf("ListDocuments() error = %v", err)
+	}
+	if total != n {
+		t.Errorf("indexed %d documents, want %d", total, n)
+	}
+
+	doc, err := store.GetDocument("docs/1003.md")
+	if err != nil || doc == nil {
+		t.Fatalf("GetDocument() = %v, %v", doc, err)
+	}
+	chunks, err := store.ListChunks("docs/1003.md")
+	if err != nil || len(chunks) == 0 {
+		t.Errorf("expected chunks for batch-indexed document, got %v, %v", chunks, err)
+	}
+}
+
+func TestIndexBatch_Upsert(t *testing.T) {
+	store := newTestStore(t)
+	indexSampleDocs(t, store)
+
+	docs := sampleDocs()
+	docs[0].Frontmatter = map[string]any{"title": "Updated"}
+	if err := store.IndexBatch(docs[:1], nil); err != nil {
+		t.Fatalf("IndexBatch() error = %v", err)
+	}
+
+	doc, _ := store.GetDocument(docs[0].RelPath)
+	if doc == nil || doc.Title != "Updated" {
+		t.Errorf("expected updated title, got %+v", doc)
+	}
+	results, _, _ := store.Search("Learn", 10, 0)
+	if len(results) != 1 {
+		t.Errorf("expected a single FTS hit after re-indexing, got %d", len(results))
+	}
+}
+
 func TestGetDocument_NotFound(t *testing.T) {
 	store := newTestStore(t)
 
@@ -259,6 +330,122 @@ func TestRemoveDocument(t *testing.T) {
 	}
 }
 
+func indexDirDocs(t *testing.T, store *Store) {
+	t.Helper()
+	for _, p := range []string{"notes/a.md", "notes/sub/b.md", "notes-old.md", "other/c.md"} {
+		doc := scanner.Document{RelPath: p, Body: "# " + p + "\n\nshared words about " + p + "\n"}
+		if err := store.IndexDocument(doc); err != nil {
+			t.Fatalf("IndexDocument(%q) error = %v", p, err)
+		}
+	}
+	store.SetDiagnostic(scanner.Diagnostic{Path: "notes/bad.md", Message: "unknown encoding"})
+}
+
+func TestApplyChanges(t *testing.T) {
+	store := newTestStore(t)
+	indexDirDocs(t, store)
+
+	err := store.ApplyChanges([]scanner.Document{
+		{RelPath: "notes/a.md", Body: "# A\n\nrewritten\n"},
+		{RelPath: "new.md", Body: "# New\n"},
+	}, []string{"other/c.md", "missing.md"})
+	if err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+
+	_, total, _ := store.ListDocuments(0, 0)
+	if total != 4 {
+		t.Errorf("documents = %d, want 4", total)
+	}
+	if doc, _ := store.GetDocument("other/c.md"); doc != nil {
+		t.Error("other/c.md was not removed")
+	}
+	if results, _, _ := store.Search("rewritten", 10, 0); len(results) != 1 || results[0].Path != "notes/a.md" {
+		t.Errorf("search after update = %+v", results)
+	}
+}
+
+func TestRemoveDir(t *testing.T) {
+	store := newTestStore(t)
+	indexDirDocs(t, store)
+
+	removed, err := store.RemoveDir("notes")
+	if err != nil {
+		t.Fatalf("RemoveDir() error = %v", err)
+	}
+	if strings.Join(removed, ",") != "notes/a.md,notes/sub/b.md" {
+		t.Errorf("removed = %v", removed)
+	}
+
+	_, total, _ := store.ListDocuments(0, 0)
+	if total != 2 {
+		t.Errorf("remaining documents = %d, want 2 (notes-old.md must survive)", total)
+	}
+	if diags, _ := store.ListDiagnostics(); len(diags) != 0 {
+		t.Errorf("diagnostics = %v, want none", diags)
+	}
+	if chunks, _ := store.ListChunks("notes/a.md"); len(chunks) != 0 {
+		t.Errorf("chunks of removed document = %v", chunks)
+	}
+}
+
+func TestRenameDir(t *testing.T) {
+	store := newTestStore(t)
+	indexDirDocs(t, store)
+	before, _ := store.ListChunks("notes/sub/b.md")
+
+	moved, err := store.RenameDir("notes", "archive/notes")
+	if err != nil {
+		t.Fatalf("RenameDir() error = %v", err)
+	}
+	if strings.Join(moved, ",") != "archive/notes/a.md,archive/notes/sub/b.md" {
+		t.Errorf("moved = %v", moved)
+	}
+
+	if doc, _ := store.GetDocument("notes/a.md"); doc != nil {
+		t.Error("old path still indexed")
+	}
+	doc, _ := store.GetDocument("archive/notes/sub/b.md")
+	if doc == nil || !strings.Contains(doc.Body, "notes/sub/b.md") {
+		t.Fatalf("renamed document = %+v", doc)
+	}
+	if doc, _ := store.GetDocument("notes-old.md"); doc == nil {
+		t.Error("sibling with a shared prefix was renamed")
+	}
+
+	results, _, _ := store.Search("shared words", 10, 0)
+	found := false
+	for _, r := range results {
+		if strings.HasPrefix(r.Path, "notes/") {
+			t.Errorf("search returned old path %q", r.Path)
+		}
+		found = found || r.Path == "archive/notes/a.md"
+	}
+	if !found {
+		t.Errorf("search after rename = %+v, want archive/notes/a.md", results)
+	}
+
+	after, _ := store.ListChunks("archive/notes/sub/b.md")
+	if len(after) != len(before) || len(after) == 0 {
+		t.Fatalf("chunks after rename = %d, before = %d", len(after), len(before))
+	}
+	want := SplitChunks("archive/notes/sub/b.md", "# notes/sub/b.md\n\nshared words about notes/sub/b.md\n", 0, DefaultChunkTokens)
+	if after[0].ID != want[0].ID || after[0].ID == before[0].ID {
+		t.Errorf("chunk ID = %q, want %q derived from the new path", after[0].ID, want[0].ID)
+	}
+	chunks, _, _ := store.SearchChunks("shared words", nil, 10000)
+	for _, c := range chunks {
+		if strings.HasPrefix(c.Path, "notes/") {
+			t.Errorf("chunk search returned old path %q", c.Path)
+		}
+	}
+
+	diags, _ := store.ListDiagnostics()
+	if len(diags) != 1 || diags[0].Path != "archive/notes/bad.md" {
+		t.Errorf("diagnostics = %v", diags)
+	}
+}
+
 func TestListDocuments(t *testing.T) {
 	store := newTestStore(t)
 	indexSampleDocs(t, store)
@@ -275,6 +462,27 @@ func TestListDocuments(t *testing.T) {
 	}
 }
 
+func TestListDocumentDetails(t *testing.T) {
+	store := newTestStore(t)
+	indexSampleDocs(t, store)
+
+	docs, err := store.ListDocumentDetails()
+	if err != nil {
+		t.Fatalf("ListDocumentDetails() error = %v", err)
+	}
+	if len(docs) != 3 {
+		t.Fatalf("got %d docs, want 3", len(docs))
+	}
+	for i, d := range docs {
+		if i > 0 && docs[i-1].Path >= d.Path {
+			t.Errorf("docs not ordered by path: %q before %q", docs[i-1].Path, d.Path)
+		}
+		if d.Body == "" {
+			t.Errorf("%s: empty body", d.Path)
+		}
+	}
+}
+
 func TestListDocuments_Pagination(t *testing.T) {
 	store := newTestStore(t)
 	indexSampleDocs(t, store)
@@ -541,3 +749,50 @@ func TestNewWithPath(t *testing.T) {
 		t.Errorf("expected document with title 'Test', got %v", got)
 	}
 }
+
+func TestIndexDocument_Encoding(t *testing.T) {
+	store := newTestStore(t)
+	store.IndexDocument(scanner.Document{
+		RelPath:  "legacy.md",
+		Body:     "# 旧文書",
+		ModTime:  time.Now(),
+		Encoding: "shift_jis",
+	})
+
+	doc, err := store.GetDocument("legacy.md")
+	if err != nil || doc == nil {
+		t.Fatalf("GetDocument() = %v, %v", doc, err)
+	}
+	if doc.Encoding != "shift_jis" {
+		t.Errorf("Encoding = %q, want %q", doc.Encoding, "shift_jis")
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	store := newTestStore(t)
+	indexSampleDocs(t, store)
+
+	if err := store.SetDiagnostic(scanner.Diagnostic{Path: "guide.md", Message: "decoding file: bad"}); err != nil {
+		t.Fatalf("SetDiagnostic() error = %v", err)
+	}
+	store.SetDiagnostic(scanner.Diagnostic{Path: "broken.md", Message: "decoding file: bad"})
+
+	diags, err := store.ListDiagnostics()
+	if err != nil {
+		t.Fatalf("ListDiagnostics() error = %v", err)
+	}
+	if len(diags) != 2 || diags[0].Path != "broken.md" || diags[1].Path != "guide.md" {
+		t.Errorf("diagnostics = %+v", diags)
+	}
+	if doc, _ := store.GetDocument("guide.md"); doc != nil {
+		t.Error("document with a diagnostic should be removed from the index")
+	}
+
+	// Re-indexing a fixed file clears its diagnostic; deleting clears the rest.
+	store.IndexDocument(sampleDocs()[0])
+	store.RemoveDocument("broken.md")
+	diags, _ = store.ListDiagnostics()
+	if len(diags) != 0 {
+		t.Errorf("expected diagnostics to be cleared, got %+v", diags)
+	}
+}
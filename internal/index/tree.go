@@ -5,16 +5,20 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+
+	gitpkg "github.com/esakat/markdown-kb/internal/git"
 )
 
 // TreeNode represents a node in the directory tree.
 type TreeNode struct {
-	Name     string      `json:"name"`
-	Type     string      `json:"type"` // "dir" or "file"
-	Path     string      `json:"path,omitempty"`
-	Title    string      `json:"title,omitempty"`
-	Tags     []string    `json:"tags,omitempty"`
-	Children []*TreeNode `json:"children,omitempty"`
+	Name      string           `json:"name"`
+	Type      string           `json:"type"` // "dir" or "file"
+	Path      string           `json:"path,omitempty"`
+	Title     string           `json:"title,omitempty"`
+	Tags      []string         `json:"tags,omitempty"`
+	GitStatus string           `json:"git_status,omitempty"` // working-tree state, filled in by the server
+	Git       *gitpkg.FileMeta `json:"git,omitempty"`        // history, filled in by the server
+	Children  []*TreeNode      `json:"children,omitempty"`
 }
 
 // PathEntry is a lightweight path+title pair for tree building.
@@ -22,6 +26,7 @@ type PathEntry struct {
 	Path  string
 	Title string
 	Tags  []string
+	Meta  map[string]any
 }
 
 // ListPaths returns all document paths, titles, and tags, ordered by path.
@@ -41,6 +46,7 @@ func (s *Store) ListPaths() ([]PathEntry, error) {
 		}
 		if metaJSON != nil {
 			e.Tags = extractTags(*metaJSON)
+			json.Unmarshal([]byte(*metaJSON), &e.Meta)
 		}
 		entries = append(entries, e)
 	}
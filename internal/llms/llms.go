@@ -0,0 +1,213 @@
+// Package llms renders the knowledge base in the llms.txt format
+// (https://llmstxt.org): a Markdown index of documents for LLM tooling,
+// plus a full variant that concatenates every document body.
+package llms
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/esakat/markdown-kb/internal/index"
+)
+
+// rootSection is the section heading used for documents at the repository root.
+const rootSection = "General"
+
+// maxDescriptionRunes truncates descriptions taken from document bodies.
+const maxDescriptionRunes = 200
+
+// linkItem matches a list item that is nothing but a Markdown link, as in
+// a table of contents or a "see also" list.
+var linkItem = regexp.MustCompile(`^[-*+]\s+\[[^\]]*\]\([^)]*\)$`)
+
+// Entry is a single document in the llms.txt index.
+type Entry struct {
+	Path        string
+	Title       string
+	Description string
+	Section     string
+	Body        string
+}
+
+// Collect loads every indexed document and returns entries ordered by
+// section (root first, then top-level directory name) and path.
+func Collect(store *index.Store) ([]Entry, error) {
+	docs, err := store.ListDocumentDetails()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(docs))
+	for _, d := range docs {
+		title := d.Title
+		if title == "" {
+			title = d.Path
+		}
+		entries = append(entries, Entry{
+			Path:        d.Path,
+			Title:       title,
+			Description: Describe(d.Meta, d.Body),
+			Section:     sectionOf(d.Path),
+			Body:        d.Body,
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Section != b.Section {
+			if a.Section == rootSection {
+				return true
+			}
+			if b.Section == rootSection {
+				return false
+			}
+			return a.Section < b.Section
+		}
+		return a.Path < b.Path
+	})
+
+	return entries, nil
+}
+
+// sectionOf returns the top-level directory of path, or rootSection.
+func sectionOf(path string) string {
+	if i := strings.Index(path, "/"); i > 0 {
+		return path[:i]
+	}
+	return rootSection
+}
+
+// Describe returns a one-line description for a document: the frontmatter
+// description or summary if present, otherwise its first prose paragraph.
+func Describe(meta map[string]any, body string) string {
+	for _, key := range []string{"description", "summary"} {
+		if s, ok := meta[key].(string); ok && strings.TrimSpace(s) != "" {
+			return oneLine(s)
+		}
+	}
+	return oneLine(firstParagraph(body))
+}
+
+// firstParagraph returns the first paragraph of body that isn't a heading,
+// code block, HTML comment or list of links.
+func firstParagraph(body string) string {
+	var para []string
+	inFence, inComment := false, false
+
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case inFence:
+			if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+				inFence = false
+			}
+			continue
+		case inComment:
+			if strings.Contains(trimmed, "-->") {
+				inComment = false
+			}
+			continue
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			if len(para) > 0 {
+				return strings.Join(para, " ")
+			}
+			inFence = true
+			continue
+		case strings.HasPrefix(trimmed, "<!--"):
+			if !strings.Contains(trimmed, "-->") {
+				inComment = true
+			}
+			continue
+		}
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "|") || linkItem.MatchString(trimmed) {
+			if len(para) > 0 {
+				return strings.Join(para, " ")
+			}
+			continue
+		}
+		para = append(para, strings.TrimPrefix(trimmed, "> "))
+	}
+
+	return strings.Join(para, " ")
+}
+
+// oneLine collapses whitespace and truncates s to maxDescriptionRunes.
+func oneLine(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= maxDescriptionRunes {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:maxDescriptionRunes])) + "…"
+}
+
+// WriteIndex writes llms.txt. Links point to linkBase + path; an empty
+// linkBase yields repository-relative links.
+func WriteIndex(w io.Writer, title, summary, linkBase string, entries []Entry) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", title)
+	if summary != "" {
+		fmt.Fprintf(&b, "\n> %s\n", oneLine(summary))
+	}
+
+	section := ""
+	for _, e := range entries {
+		if e.Section != section {
+			section = e.Section
+			fmt.Fprintf(&b, "\n## %s\n\n", section)
+		}
+		fmt.Fprintf(&b, "- [%s](%s%s)", linkText(e.Title), linkBase, linkPath(e.Path))
+		if e.Description != "" {
+			fmt.Fprintf(&b, ": %s", e.Description)
+		}
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// linkText escapes s for use as Markdown link text.
+func linkText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(s)
+}
+
+// linkPath escapes each segment of a slash-separated path for use as a
+// link target, so spaces, parentheses and non-ASCII names stay intact.
+func linkPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		// PathEscape keeps parentheses, which can end a Markdown link.
+		segments[i] = strings.NewReplacer("(", "%28", ")", "%29").Replace(url.PathEscape(seg))
+	}
+	return strings.Join(segments, "/")
+}
+
+// WriteFull writes llms-full.txt: the index header followed by every
+// document body in the same order as WriteIndex.
+func WriteFull(w io.Writer, title, summary string, entries []Entry) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", title)
+	if summary != "" {
+		fmt.Fprintf(&b, "\n> %s\n", oneLine(summary))
+	}
+
+	for _, e := range entries {
+		fmt.Fprintf(&b, "\n---\n\n## %s\n\nSource: %s\n\n", linkText(e.Title), linkPath(e.Path))
+		body := strings.TrimSpace(e.Body)
+		if body != "" {
+			b.WriteString(body)
+			b.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
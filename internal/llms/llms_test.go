@@ -0,0 +1,191 @@
+package llms
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/esakat/markdown-kb/internal/index"
+	"github.com/esakat/markdown-kb/internal/scanner"
+)
+
+func newTestStore(t *testing.T) *index.Store {
+	t.Helper()
+	store, err := index.New()
+	if err != nil {
+		t.Fatalf("index.New() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	now := time.Now()
+	docs := []scanner.Document{
+		{
+			RelPath:     "guides/setup.md",
+			Frontmatter: map[string]any{"title": "Setup", "description": "How to install."},
+			Body:        "# Setup\n\nIgnored paragraph.\n",
+			ModTime:     now,
+		},
+		{
+			RelPath:     "api/rest.md",
+			Frontmatter: map[string]any{"title": "REST"},
+			Body:        "# REST\n\n```sh\ncurl x\n```\n\nThe REST API\nreturns JSON.\n\nMore text.\n",
+			ModTime:     now,
+		},
+		{
+			RelPath: "README.md",
+			Body:    "Welcome.\n",
+			ModTime: now,
+		},
+	}
+	for _, doc := range docs {
+		if err := store.IndexDocument(doc); err != nil {
+			t.Fatalf("IndexDocument(%q) error = %v", doc.RelPath, err)
+		}
+	}
+	return store
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		name string
+		meta map[string]any
+		body string
+		want string
+	}{
+		{"frontmatter description", map[string]any{"description": "From meta."}, "Body.", "From meta."},
+		{"summary fallback", map[string]any{"summary": "Summary."}, "Body.", "Summary."},
+		{"first paragraph", nil, "# Title\n\nFirst line\nsecond line.\n\nNext.", "First line second line."},
+		{"skips code and comments", nil, "<!-- note -->\n```\ncode\n```\nProse.", "Prose."},
+		{"skips link lists", nil, "- [Setup](setup.md)\n* [API](api.md)\n\nProse.", "Prose."},
+		{"link list ends paragraph", nil, "Prose.\n- [Setup](setup.md)", "Prose."},
+		{"keeps described links", nil, "- [Setup](setup.md): install first.", "- [Setup](setup.md): install first."},
+		{"empty", nil, "# Only heading\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Describe(tt.meta, tt.body); got != tt.want {
+				t.Errorf("Describe() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribe_Truncates(t *testing.T) {
+	got := Describe(nil, strings.Repeat("あ", 300))
+	if n := len([]rune(got)); n != maxDescriptionRunes+1 {
+		t.Errorf("description length = %d runes, want %d", n, maxDescriptionRunes+1)
+	}
+	if !strings.HasSuffix(got, "…") {
+		t.Errorf("expected ellipsis, got %q", got)
+	}
+}
+
+func TestCollect_Order(t *testing.T) {
+	entries, err := Collect(newTestStore(t))
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	want := []string{"README.md", "api/rest.md", "guides/setup.md"}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), len(entries))
+	}
+	for i, e := range entries {
+		if e.Path != want[i] {
+			t.Errorf("entries[%d] = %q, want %q", i, e.Path, want[i])
+		}
+	}
+	if entries[0].Section != rootSection || entries[0].Title != "README.md" {
+		t.Errorf("root entry = %+v", entries[0])
+	}
+	if entries[1].Description != "The REST API returns JSON." {
+		t.Errorf("description = %q", entries[1].Description)
+	}
+}
+
+func TestWriteIndex(t *testing.T) {
+	entries, _ := Collect(newTestStore(t))
+
+	var b strings.Builder
+	if err := WriteIndex(&b, "My KB", "Team docs.", "https://kb.example.com/", entries); err != nil {
+		t.Fatalf("WriteIndex() error = %v", err)
+	}
+	out := b.String()
+
+	for _, want := range []string{
+		"# My KB\n\n> Team docs.\n",
+		"## General\n\n- [README.md](https://kb.example.com/README.md): Welcome.\n",
+		"## api\n\n- [REST](https://kb.example.com/api/rest.md): The REST API returns JSON.\n",
+		"## guides\n\n- [Setup](https://kb.example.com/guides/setup.md): How to install.\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteIndex_Escaping(t *testing.T) {
+	entries := []Entry{
+		{Path: "notes/meeting notes (2024).md", Title: "Notes [draft]", Section: "notes"},
+		{Path: "設計/概要.md", Title: "概要", Section: "設計"},
+	}
+
+	var b strings.Builder
+	if err := WriteIndex(&b, "KB", "", "", entries); err != nil {
+		t.Fatalf("WriteIndex() error = %v", err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		`- [Notes \[draft\]](notes/meeting%20notes%20%282024%29.md)`,
+		"- [概要](%E8%A8%AD%E8%A8%88/%E6%A6%82%E8%A6%81.md)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteFull_Escaping(t *testing.T) {
+	entries := []Entry{
+		{Path: "notes/meeting notes (2024).md", Title: "Notes [draft]", Section: "notes", Body: "Body."},
+	}
+
+	var b strings.Builder
+	if err := WriteFull(&b, "KB", "", entries); err != nil {
+		t.Fatalf("WriteFull() error = %v", err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		`## Notes \[draft\]`,
+		"Source: notes/meeting%20notes%20%282024%29.md",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteFull_Deterministic(t *testing.T) {
+	store := newTestStore(t)
+
+	render := func() string {
+		entries, _ := Collect(store)
+		var b strings.Builder
+		WriteFull(&b, "My KB", "", entries)
+		return b.String()
+	}
+
+	first := render()
+	if first != render() {
+		t.Error("WriteFull output is not deterministic")
+	}
+	readme := strings.Index(first, "Source: README.md")
+	rest := strings.Index(first, "Source: api/rest.md")
+	setup := strings.Index(first, "Source: guides/setup.md")
+	if readme < 0 || rest < readme || setup < rest {
+		t.Errorf("unexpected document order:\n%s", first)
+	}
+	if !strings.Contains(first, "curl x") {
+		t.Error("expected full bodies in output")
+	}
+}
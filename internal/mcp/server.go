@@ -0,0 +1,306 @@
+// Package mcp implements a Model Context Protocol server over stdio,
+// exposing the knowledge base index as MCP tools and resources.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/esakat/markdown-kb/internal/charset"
+	"github.com/esakat/markdown-kb/internal/index"
+)
+
+// latestProtocolVersion is returned when the client requests a protocol
+// version this server does not know.
+const latestProtocolVersion = "2025-06-18"
+
+var supportedProtocolVersions = []string{"2024-11-05", "2025-03-26", "2025-06-18"}
+
+// resourceScheme prefixes document paths to form MCP resource URIs.
+const resourceScheme = "kb:///"
+
+// resourcePageSize is the number of resources returned per resources/list page.
+const resourcePageSize = 100
+
+// JSON-RPC 2.0 error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// Server answers MCP requests using an index.Store.
+type Server struct {
+	store   *index.Store
+	rootDir string
+	name    string
+	version string
+
+	mu  sync.Mutex // serializes writes to out
+	out io.Writer
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// New creates an MCP server. name is reported as the server title
+// (typically the repo config title) and rootDir is used for git history
+// and raw file access.
+func New(store *index.Store, rootDir, name, version string) *Server {
+	return &Server{
+		store:   store,
+		rootDir: rootDir,
+		name:    name,
+		version: version,
+	}
+}
+
+// Serve reads newline-delimited JSON-RPC messages from r and writes
+// responses to w until r is exhausted or ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.out = w
+
+	type readResult struct {
+		line []byte
+		err  error
+	}
+	lines := make(chan readResult)
+	go func() {
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadBytes('\n')
+			select {
+			case lines <- readResult{line, err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case res := <-lines:
+			if len(strings.TrimSpace(string(res.line))) > 0 {
+				s.handleMessage(res.line)
+			}
+			if res.err == io.EOF {
+				return nil
+			}
+			if res.err != nil {
+				return fmt.Errorf("reading request: %w", res.err)
+			}
+		}
+	}
+}
+
+func (s *Server) handleMessage(line []byte) {
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		s.write(response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: "parse error"}})
+		return
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		if req.ID != nil {
+			s.write(response{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: codeInvalidRequest, Message: "invalid request"}})
+		}
+		return
+	}
+
+	result, err := s.dispatch(req)
+
+	// Notifications (no id) never get a response.
+	if req.ID == nil {
+		return
+	}
+
+	resp := response{JSONRPC: "2.0", ID: req.ID}
+	if err != nil {
+		var rerr *rpcError
+		if !errors.As(err, &rerr) {
+			rerr = &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		resp.Error = rerr
+	} else {
+		resp.Result = result
+	}
+	s.write(resp)
+}
+
+func (s *Server) write(resp response) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.out.Write(append(data, '\n'))
+}
+
+func (s *Server) dispatch(req request) (any, error) {
+	switch req.Method {
+	case "initialize":
+		return s.handleInitialize(req.Params)
+	case "ping":
+		return map[string]any{}, nil
+	case "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+	case "tools/list":
+		return map[string]any{"tools": toolDefinitions()}, nil
+	case "tools/call":
+		return s.handleToolCall(req.Params)
+	case "resources/list":
+		return s.handleResourcesList(req.Params)
+	case "resources/read":
+		return s.handleResourcesRead(req.Params)
+	case "resources/templates/list":
+		return map[string]any{"resourceTemplates": []any{}}, nil
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+}
+
+func (s *Server) handleInitialize(params json.RawMessage) (any, error) {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: "invalid initialize params"}
+		}
+	}
+
+	version := latestProtocolVersion
+	for _, v := range supportedProtocolVersions {
+		if v == p.ProtocolVersion {
+			version = v
+			break
+		}
+	}
+
+	return map[string]any{
+		"protocolVersion": version,
+		"capabilities": map[string]any{
+			"tools":     map[string]any{},
+			"resources": map[string]any{},
+		},
+		"serverInfo": map[string]any{
+			"name":    "markdown-kb",
+			"title":   s.name,
+			"version": s.version,
+		},
+		"instructions": "Markdown knowledge base. Use search to find documents, then get_document to read them.",
+	}, nil
+}
+
+func (s *Server) handleResourcesList(params json.RawMessage) (any, error) {
+	var p struct {
+		Cursor string `json:"cursor"`
+	}
+	if len(params) > 0 {
+		json.Unmarshal(params, &p)
+	}
+
+	offset := 0
+	if p.Cursor != "" {
+		n, err := strconv.Atoi(p.Cursor)
+		if err != nil || n < 0 {
+			return nil, &rpcError{Code: codeInvalidParams, Message: "invalid cursor"}
+		}
+		offset = n
+	}
+
+	docs, total, err := s.store.ListDocuments(resourcePageSize, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]map[string]any, 0, len(docs))
+	for _, d := range docs {
+		res := map[string]any{
+			"uri":      resourceScheme + d.Path,
+			"name":     d.Path,
+			"mimeType": "text/markdown",
+			"size":     d.Size,
+		}
+		if d.Title != "" {
+			res["title"] = d.Title
+		}
+		resources = append(resources, res)
+	}
+
+	result := map[string]any{"resources": resources}
+	if next := offset + len(docs); next < total {
+		result["nextCursor"] = strconv.Itoa(next)
+	}
+	return result, nil
+}
+
+func (s *Server) handleResourcesRead(params json.RawMessage) (any, error) {
+	var p struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || !strings.HasPrefix(p.URI, resourceScheme) {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "invalid resource uri"}
+	}
+	docPath := strings.TrimPrefix(p.URI, resourceScheme)
+
+	doc, err := s.store.GetDocument(docPath)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "resource not found: " + p.URI}
+	}
+
+	// Prefer the file on disk so frontmatter is included verbatim.
+	text := doc.Body
+	if s.rootDir != "" && !strings.Contains(docPath, "..") {
+		if content, err := os.ReadFile(filepath.Join(s.rootDir, filepath.FromSlash(docPath))); err == nil {
+			if decoded, _, err := charset.Decode(content); err == nil {
+				text = decoded
+			}
+		}
+	}
+
+	return map[string]any{
+		"contents": []map[string]any{{
+			"uri":      p.URI,
+			"mimeType": "text/markdown",
+			"text":     text,
+		}},
+	}, nil
+}
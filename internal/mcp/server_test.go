@@ -0,0 +1,249 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/esakat/markdown-kb/internal/index"
+	"github.com/esakat/markdown-kb/internal/scanner"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	store, err := index.New()
+	if err != nil {
+		t.Fatalf("index.New() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "guide.md"), []byte("---\ntitle: Go Guide\n---\n# Go Guide\n\nLearn Go programming language.\n"), 0o644)
+
+	now := time.Now()
+	docs := []scanner.Document{
+		{
+			RelPath:     "guide.md",
+			Frontmatter: map[string]any{"title": "Go Guide", "status": "published", "tags": []any{"go", "tutorial"}},
+			Body:        "# Go Guide\n\nLearn Go programming language.\n",
+			ModTime:     now,
+			Size:        100,
+		},
+		{
+			RelPath:     "api.md",
+			Frontmatter: map[string]any{"title": "API Reference", "status": "draft", "tags": []any{"go", "api"}},
+			Body:        "# API Reference\n\nSee the [[guide]] first.\n",
+			ModTime:     now,
+			Size:        200,
+		},
+	}
+	for _, doc := range docs {
+		if err := store.IndexDocument(doc); err != nil {
+			t.Fatalf("IndexDocument(%q) error = %v", doc.RelPath, err)
+		}
+	}
+
+	return New(store, dir, "Test KB", "test")
+}
+
+// roundTrip sends the given requests and returns responses keyed by id.
+func roundTrip(t *testing.T, s *Server, reqs ...string) map[float64]map[string]any {
+	t.Helper()
+
+	var out bytes.Buffer
+	in := strings.NewReader(strings.Join(reqs, "\n") + "\n")
+	if err := s.Serve(context.Background(), in, &out); err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+
+	resps := make(map[float64]map[string]any)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var resp map[string]any
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("invalid response %q: %v", line, err)
+		}
+		id, _ := resp["id"].(float64)
+		resps[id] = resp
+	}
+	return resps
+}
+
+func toolText(t *testing.T, resp map[string]any) (string, bool) {
+	t.Helper()
+	result, ok := resp["result"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected result, got %v", resp)
+	}
+	content := result["content"].([]any)
+	isError, _ := result["isError"].(bool)
+	return content[0].(map[string]any)["text"].(string), isError
+}
+
+func TestInitialize(t *testing.T) {
+	s := newTestServer(t)
+	resps := roundTrip(t, s,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"ping"}`,
+	)
+
+	if len(resps) != 2 {
+		t.Fatalf("expected 2 responses (notification gets none), got %d", len(resps))
+	}
+	result := resps[1]["result"].(map[string]any)
+	if result["protocolVersion"] != "2025-03-26" {
+		t.Errorf("protocolVersion = %v, want %q", result["protocolVersion"], "2025-03-26")
+	}
+	caps := result["capabilities"].(map[string]any)
+	if _, ok := caps["tools"]; !ok {
+		t.Error("expected tools capability")
+	}
+	if _, ok := caps["resources"]; !ok {
+		t.Error("expected resources capability")
+	}
+	if _, ok := resps[2]["result"]; !ok {
+		t.Error("expected ping result")
+	}
+}
+
+func TestInitialize_UnknownVersion(t *testing.T) {
+	s := newTestServer(t)
+	resps := roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"1999-01-01"}}`)
+
+	result := resps[1]["result"].(map[string]any)
+	if result["protocolVersion"] != latestProtocolVersion {
+		t.Errorf("protocolVersion = %v, want %q", result["protocolVersion"], latestProtocolVersion)
+	}
+}
+
+func TestToolsList(t *testing.T) {
+	s := newTestServer(t)
+	resps := roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+
+	tools := resps[1]["result"].(map[string]any)["tools"].([]any)
+	names := make(map[string]bool)
+	for _, tl := range tools {
+		names[tl.(map[string]any)["name"].(string)] = true
+	}
+	for _, want := range []string{"search", "get_document", "list_documents", "list_tags", "get_backlinks", "get_history"} {
+		if !names[want] {
+			t.Errorf("missing tool %q", want)
+		}
+	}
+}
+
+func TestToolCall_Search(t *testing.T) {
+	s := newTestServer(t)
+	resps := roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search","arguments":{"query":"programming"}}}`)
+
+	text, isError := toolText(t, resps[1])
+	if isError {
+		t.Fatalf("unexpected tool error: %s", text)
+	}
+	if !strings.Contains(text, "guide.md") {
+		t.Errorf("expected guide.md in search result, got %s", text)
+	}
+}
+
+func TestToolCall_ListDocumentsWithFilter(t *testing.T) {
+	s := newTestServer(t)
+	resps := roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"list_documents","arguments":{"status":"draft"}}}`)
+
+	text, _ := toolText(t, resps[1])
+	var data struct {
+		Documents []index.DocumentSummary `json:"documents"`
+		Total     int                     `json:"total"`
+	}
+	if err := json.Unmarshal([]byte(text), &data); err != nil {
+		t.Fatalf("invalid tool output: %v", err)
+	}
+	if data.Total != 1 || data.Documents[0].Path != "api.md" {
+		t.Errorf("expected only api.md, got %+v", data)
+	}
+}
+
+func TestToolCall_GetDocument(t *testing.T) {
+	s := newTestServer(t)
+	resps := roundTrip(t, s,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"get_document","arguments":{"path":"guide.md"}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"get_document","arguments":{"path":"missing.md"}}}`,
+	)
+
+	text, isError := toolText(t, resps[1])
+	if isError || !strings.Contains(text, "Learn Go") {
+		t.Errorf("expected document body, got %s", text)
+	}
+	if _, isError := toolText(t, resps[2]); !isError {
+		t.Error("expected isError for missing document")
+	}
+}
+
+func TestToolCall_Backlinks(t *testing.T) {
+	s := newTestServer(t)
+	resps := roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"get_backlinks","arguments":{"path":"guide.md"}}}`)
+
+	text, _ := toolText(t, resps[1])
+	if !strings.Contains(text, "api.md") {
+		t.Errorf("expected api.md as backlink, got %s", text)
+	}
+}
+
+func TestToolCall_UnknownTool(t *testing.T) {
+	s := newTestServer(t)
+	resps := roundTrip(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"nope"}}`)
+
+	rerr, ok := resps[1]["error"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected error, got %v", resps[1])
+	}
+	if code, _ := rerr["code"].(float64); int(code) != codeInvalidParams {
+		t.Errorf("code = %v, want %d", rerr["code"], codeInvalidParams)
+	}
+}
+
+func TestResources(t *testing.T) {
+	s := newTestServer(t)
+	resps := roundTrip(t, s,
+		`{"jsonrpc":"2.0","id":1,"method":"resources/list"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"resources/read","params":{"uri":"kb:///guide.md"}}`,
+	)
+
+	resources := resps[1]["result"].(map[string]any)["resources"].([]any)
+	if len(resources) != 2 {
+		t.Fatalf("expected 2 resources, got %d", len(resources))
+	}
+	if uri := resources[0].(map[string]any)["uri"]; uri != "kb:///api.md" {
+		t.Errorf("uri = %v, want %q", uri, "kb:///api.md")
+	}
+
+	contents := resps[2]["result"].(map[string]any)["contents"].([]any)
+	text := contents[0].(map[string]any)["text"].(string)
+	if !strings.HasPrefix(text, "---\ntitle: Go Guide") {
+		t.Errorf("expected raw file with frontmatter, got %q", text)
+	}
+}
+
+func TestMethodNotFoundAndParseError(t *testing.T) {
+	s := newTestServer(t)
+	resps := roundTrip(t, s,
+		`{"jsonrpc":"2.0","id":1,"method":"prompts/unknown"}`,
+		`not json`,
+	)
+
+	if code, _ := resps[1]["error"].(map[string]any)["code"].(float64); int(code) != codeMethodNotFound {
+		t.Errorf("code = %v, want %d", code, codeMethodNotFound)
+	}
+	// Parse errors are answered with a null id.
+	if code, _ := resps[0]["error"].(map[string]any)["code"].(float64); int(code) != codeParseError {
+		t.Errorf("code = %v, want %d", code, codeParseError)
+	}
+}
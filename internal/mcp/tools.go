@@ -0,0 +1,256 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+
+	gitpkg "github.com/esakat/markdown-kb/internal/git"
+	"github.com/esakat/markdown-kb/internal/index"
+)
+
+// tool describes an MCP tool as returned by tools/list.
+type tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// toolArgs is the union of arguments accepted by the built-in tools.
+type toolArgs struct {
+	Query  string `json:"query"`
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Tag    string `json:"tag"`
+	Limit  int    `json:"limit"`
+	Page   int    `json:"page"`
+}
+
+func objectSchema(props map[string]any, required ...string) map[string]any {
+	schema := map[string]any{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func stringProp(desc string) map[string]any {
+	return map[string]any{"type": "string", "description": desc}
+}
+
+func intProp(desc string) map[string]any {
+	return map[string]any{"type": "integer", "description": desc, "minimum": 1}
+}
+
+func toolDefinitions() []tool {
+	return []tool{
+		{
+			Name:        "search",
+			Description: "Full-text search across all documents, ranked by BM25. Returns paths, titles, snippets and frontmatter.",
+			InputSchema: objectSchema(map[string]any{
+				"query":  stringProp("Search keywords (at least 3 characters)"),
+				"status": stringProp("Filter by frontmatter status"),
+				"tag":    stringProp("Filter by frontmatter tag"),
+				"limit":  intProp("Maximum results (default 10, max 100)"),
+			}, "query"),
+		},
+		{
+			Name:        "get_document",
+			Description: "Get a document's frontmatter and Markdown body by its path.",
+			InputSchema: objectSchema(map[string]any{
+				"path": stringProp("Document path relative to the repository root"),
+			}, "path"),
+		},
+		{
+			Name:        "list_documents",
+			Description: "List documents with their frontmatter, optionally filtered by status and tag.",
+			InputSchema: objectSchema(map[string]any{
+				"status": stringProp("Filter by frontmatter status"),
+				"tag":    stringProp("Filter by frontmatter tag"),
+				"page":   intProp("Page number (default 1)"),
+				"limit":  intProp("Page size (default 20, max 100)"),
+			}),
+		},
+		{
+			Name:        "list_tags",
+			Description: "List all frontmatter tags with document counts.",
+			InputSchema: objectSchema(map[string]any{}),
+		},
+		{
+			Name:        "get_backlinks",
+			Description: "List documents that link to the given document.",
+			InputSchema: objectSchema(map[string]any{
+				"path": stringProp("Document path relative to the repository root"),
+			}, "path"),
+		},
+		{
+			Name:        "get_history",
+			Description: "Get the Git commit history of a document, most recent first.",
+			InputSchema: objectSchema(map[string]any{
+				"path": stringProp("Document path relative to the repository root"),
+			}, "path"),
+		},
+	}
+}
+
+func (s *Server) handleToolCall(params json.RawMessage) (any, error) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "invalid tools/call params"}
+	}
+
+	var args toolArgs
+	if len(p.Arguments) > 0 {
+		if err := json.Unmarshal(p.Arguments, &args); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: "invalid tool arguments"}
+		}
+	}
+
+	var data any
+	var err error
+	switch p.Name {
+	case "search":
+		data, err = s.toolSearch(args)
+	case "get_document":
+		data, err = s.toolGetDocument(args)
+	case "list_documents":
+		data, err = s.toolListDocuments(args)
+	case "list_tags":
+		data, err = s.toolListTags()
+	case "get_backlinks":
+		data, err = s.toolGetBacklinks(args)
+	case "get_history":
+		data, err = s.toolGetHistory(args)
+	default:
+		return nil, &rpcError{Code: codeInvalidParams, Message: "unknown tool: " + p.Name}
+	}
+
+	// Tool failures are reported in the result so the model can see them.
+	if err != nil {
+		return toolResult(err.Error(), true), nil
+	}
+	text, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return toolResult(string(text), false), nil
+}
+
+func toolResult(text string, isError bool) map[string]any {
+	return map[string]any{
+		"content": []map[string]any{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+func filtersFromArgs(args toolArgs) map[string]string {
+	filters := make(map[string]string)
+	if args.Status != "" {
+		filters["status"] = args.Status
+	}
+	if args.Tag != "" {
+		filters["tags"] = args.Tag
+	}
+	return filters
+}
+
+func clampLimit(limit, defaultVal int) int {
+	if limit < 1 {
+		return defaultVal
+	}
+	if limit > 100 {
+		return 100
+	}
+	return limit
+}
+
+func (s *Server) toolSearch(args toolArgs) (any, error) {
+	if args.Query == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	results, total, err := s.store.SearchWithFilter(args.Query, filtersFromArgs(args), clampLimit(args.Limit, 10), 0)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	if results == nil {
+		results = []index.SearchResult{}
+	}
+	return map[string]any{"results": results, "total": total}, nil
+}
+
+func (s *Server) toolGetDocument(args toolArgs) (any, error) {
+	if args.Path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	doc, err := s.store.GetDocument(args.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("document not found: %s", args.Path)
+	}
+	return doc, nil
+}
+
+func (s *Server) toolListDocuments(args toolArgs) (any, error) {
+	limit := clampLimit(args.Limit, 20)
+	page := args.Page
+	if page < 1 {
+		page = 1
+	}
+	docs, total, err := s.store.ListDocumentsWithFilter(filtersFromArgs(args), limit, (page-1)*limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	if docs == nil {
+		docs = []index.DocumentSummary{}
+	}
+	return map[string]any{"documents": docs, "total": total, "page": page, "limit": limit}, nil
+}
+
+func (s *Server) toolListTags() (any, error) {
+	tags, err := s.store.ListTags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	if tags == nil {
+		tags = []index.TagCount{}
+	}
+	return tags, nil
+}
+
+func (s *Server) toolGetBacklinks(args toolArgs) (any, error) {
+	if args.Path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	nodes, err := s.store.Backlinks(args.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backlinks: %w", err)
+	}
+	if nodes == nil {
+		nodes = []index.GraphNode{}
+	}
+	return nodes, nil
+}
+
+func (s *Server) toolGetHistory(args toolArgs) (any, error) {
+	if args.Path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	if s.rootDir == "" {
+		return nil, fmt.Errorf("git integration requires root directory")
+	}
+	commits, err := gitpkg.FileHistory(s.rootDir, args.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file history: %w", err)
+	}
+	if commits == nil {
+		commits = []gitpkg.Commit{}
+	}
+	return commits, nil
+}
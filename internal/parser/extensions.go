@@ -0,0 +1,60 @@
+package parser
+
+import "strings"
+
+// DefaultExtensions are the document extensions used when none are configured.
+var DefaultExtensions = []string{".md"}
+
+// NormalizeExtensions lowercases exts, adds a leading dot where missing and
+// drops duplicates, keeping the configured order. An empty list yields
+// DefaultExtensions.
+func NormalizeExtensions(exts []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, ext := range exts {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" || ext == "." {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if !seen[ext] {
+			seen[ext] = true
+			result = append(result, ext)
+		}
+	}
+	if len(result) == 0 {
+		return DefaultExtensions
+	}
+	return result
+}
+
+// MatchExtension returns the longest extension in exts that name ends with,
+// compared case-insensitively, or "" if none match. Multi-part extensions
+// such as ".md.txt" are supported.
+func MatchExtension(name string, exts []string) string {
+	lower := strings.ToLower(name)
+	match := ""
+	for _, ext := range exts {
+		if len(ext) > len(match) && len(lower) > len(ext) && strings.HasSuffix(lower, ext) {
+			match = ext
+		}
+	}
+	return match
+}
+
+// HasExtension reports whether name ends with one of exts.
+func HasExtension(name string, exts []string) bool {
+	return MatchExtension(name, exts) != ""
+}
+
+// TrimExtension removes the matching extension in exts from name.
+func TrimExtension(name string, exts []string) string {
+	return name[:len(name)-len(MatchExtension(name, exts))]
+}
+
+// IsMDX reports whether name is an MDX document.
+func IsMDX(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".mdx")
+}
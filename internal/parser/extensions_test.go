@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeExtensions(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want []string
+	}{
+		{nil, []string{".md"}},
+		{[]string{"", "."}, []string{".md"}},
+		{[]string{"md", ".MDX", ".markdown", ".md"}, []string{".md", ".mdx", ".markdown"}},
+		{[]string{".md.txt"}, []string{".md.txt"}},
+	}
+	for _, tt := range tests {
+		if got := NormalizeExtensions(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NormalizeExtensions(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMatchExtension(t *testing.T) {
+	exts := []string{".md", ".mdx", ".txt", ".md.txt"}
+	tests := []struct {
+		name, want string
+	}{
+		{"guide.md", ".md"},
+		{"Guide.MD", ".md"},
+		{"page.mdx", ".mdx"},
+		{"notes.md.txt", ".md.txt"},
+		{"notes.txt", ".txt"},
+		{"image.png", ""},
+		{".md", ""},
+	}
+	for _, tt := range tests {
+		if got := MatchExtension(tt.name, exts); got != tt.want {
+			t.Errorf("MatchExtension(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := TrimExtension("docs/notes.md.txt", exts); got != "docs/notes" {
+		t.Errorf("TrimExtension = %q, want %q", got, "docs/notes")
+	}
+}
+
+func TestExtractLinksWithExtensions(t *testing.T) {
+	exts := []string{".md", ".mdx", ".markdown"}
+	body := "See [[intro]], [[page.mdx]], [a](a.markdown), [b](b.md) and [c](c.txt)."
+	got := ExtractLinksWithExtensions(body, exts)
+	want := []string{"intro.md", "page.mdx", "a.markdown", "b.md"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractLinksWithExtensions() = %v, want %v", got, want)
+	}
+}
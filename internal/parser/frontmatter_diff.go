@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"reflect"
+	"sort"
+)
+
+// FrontmatterDiff lists the frontmatter keys that differ between two
+// versions of a document, each sorted by key.
+type FrontmatterDiff struct {
+	Added   []FieldChange `json:"added"`
+	Removed []FieldChange `json:"removed"`
+	Changed []FieldChange `json:"changed"`
+}
+
+// FieldChange is one frontmatter key with its old and new values; Old is
+// nil for added keys and New for removed ones.
+type FieldChange struct {
+	Key string `json:"key"`
+	Old any    `json:"old,omitempty"`
+	New any    `json:"new,omitempty"`
+}
+
+// DiffFrontmatter compares the frontmatter maps returned by
+// ParseFrontmatter. Values are compared deeply, so reordering a list
+// counts as a change but re-indenting the YAML does not.
+func DiffFrontmatter(oldMeta, newMeta map[string]any) FrontmatterDiff {
+	d := FrontmatterDiff{Added: []FieldChange{}, Removed: []FieldChange{}, Changed: []FieldChange{}}
+	for key, oldValue := range oldMeta {
+		newValue, ok := newMeta[key]
+		switch {
+		case !ok:
+			d.Removed = append(d.Removed, FieldChange{Key: key, Old: oldValue})
+		case !reflect.DeepEqual(oldValue, newValue):
+			d.Changed = append(d.Changed, FieldChange{Key: key, Old: oldValue, New: newValue})
+		}
+	}
+	for key, newValue := range newMeta {
+		if _, ok := oldMeta[key]; !ok {
+			d.Added = append(d.Added, FieldChange{Key: key, New: newValue})
+		}
+	}
+	for _, changes := range [][]FieldChange{d.Added, d.Removed, d.Changed} {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	}
+	return d
+}
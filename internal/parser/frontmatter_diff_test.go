@@ -0,0 +1,36 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffFrontmatter(t *testing.T) {
+	parse := func(s string) map[string]any {
+		t.Helper()
+		meta, _, err := ParseFrontmatter(strings.NewReader(s))
+		if err != nil {
+			t.Fatalf("ParseFrontmatter() error = %v", err)
+		}
+		return meta
+	}
+	oldMeta := parse("---\ntitle: Guide\nstatus: draft\ntags: [go, web]\nowner: alice\n---\n")
+	newMeta := parse("---\ntitle: Guide\nstatus: published\ntags:\n  - go\n  - web\nreviewed: true\n---\n")
+
+	got := DiffFrontmatter(oldMeta, newMeta)
+	want := FrontmatterDiff{
+		Added:   []FieldChange{{Key: "reviewed", New: true}},
+		Removed: []FieldChange{{Key: "owner", Old: "alice"}},
+		Changed: []FieldChange{{Key: "status", Old: "draft", New: "published"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffFrontmatter() = %+v, want %+v", got, want)
+	}
+
+	// A document without frontmatter on one side adds or removes every key.
+	got = DiffFrontmatter(nil, parse("---\ntitle: New\n---\n"))
+	if len(got.Added) != 1 || got.Added[0].Key != "title" || len(got.Removed) != 0 || len(got.Changed) != 0 {
+		t.Errorf("DiffFrontmatter(nil, ...) = %+v", got)
+	}
+}
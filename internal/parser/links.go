@@ -18,6 +18,12 @@ var (
 // External URLs (http/https), anchors (#), and non-.md links are excluded.
 // Wiki-links without .md extension get it appended automatically.
 func ExtractLinks(body string) []string {
+	return ExtractLinksWithExtensions(body, DefaultExtensions)
+}
+
+// ExtractLinksWithExtensions is like ExtractLinks but accepts links to any
+// of exts. Wiki-links without one of them get the first extension appended.
+func ExtractLinksWithExtensions(body string, exts []string) []string {
 	seen := make(map[string]bool)
 	var result []string
 
@@ -35,8 +41,8 @@ func ExtractLinks(body string) []string {
 		if link == "" {
 			continue
 		}
-		if !strings.HasSuffix(link, ".md") {
-			link += ".md"
+		if !HasExtension(link, exts) {
+			link += exts[0]
 		}
 		add(link)
 	}
@@ -55,8 +61,8 @@ func ExtractLinks(body string) []string {
 		if strings.HasPrefix(href, "#") {
 			continue
 		}
-		// Only include .md files
-		if !strings.HasSuffix(href, ".md") {
+		// Only include document files
+		if !HasExtension(href, exts) {
 			continue
 		}
 		add(href)
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// JSX component tags (<Name ...>, </Name>, <Name />) and fragments (<>, </>).
+	jsxTagRe = regexp.MustCompile(`</?[A-Z][\w.]*(\s[^<>]*)?/?>|</?>`)
+	// Start of a JSX component tag whose attributes continue on later lines.
+	jsxOpenRe = regexp.MustCompile(`^</?[A-Z][\w.]*(\s[^<>]*)?$`)
+	// MDX comments: {/* ... */}
+	mdxCommentRe = regexp.MustCompile(`\{/\*.*?\*/\}`)
+)
+
+// StripMDX removes MDX-specific syntax from body so that only the prose is
+// indexed: ESM import/export statements, JSX component tags and MDX
+// comments. Text between component tags is kept. Removed lines are left
+// blank so that line numbers still match the source file. Fenced code blocks
+// are not touched.
+func StripMDX(body string) string {
+	lines := strings.Split(body, "\n")
+	fence := ""
+	esmDepth := 0      // open braces/brackets/parens in an unfinished import/export
+	inOpenTag := false // inside a multi-line JSX opening tag
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+
+		switch {
+		case esmDepth > 0:
+			esmDepth += nesting(line)
+			lines[i] = ""
+			continue
+		case inOpenTag:
+			if end := strings.Index(line, ">"); end >= 0 {
+				inOpenTag = false
+				lines[i] = strings.TrimSpace(stripJSX(line[end+1:]))
+			} else {
+				lines[i] = ""
+			}
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "import ") || strings.HasPrefix(trimmed, "export ") {
+			esmDepth = nesting(line)
+			if esmDepth < 0 {
+				esmDepth = 0
+			}
+			lines[i] = ""
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "<") && jsxOpenRe.MatchString(trimmed) {
+			inOpenTag = true
+			lines[i] = ""
+			continue
+		}
+
+		line = mdxCommentRe.ReplaceAllString(line, "")
+		if strings.HasPrefix(trimmed, "<") {
+			line = strings.TrimSpace(stripJSX(line))
+		}
+		lines[i] = line
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// stripJSX removes JSX component tags from s.
+func stripJSX(s string) string {
+	return jsxTagRe.ReplaceAllString(mdxCommentRe.ReplaceAllString(s, ""), "")
+}
+
+// nesting returns the net number of opening brackets in s.
+func nesting(s string) int {
+	return strings.Count(s, "{") + strings.Count(s, "(") + strings.Count(s, "[") -
+		strings.Count(s, "}") - strings.Count(s, ")") - strings.Count(s, "]")
+}
@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStripMDX(t *testing.T) {
+	body := strings.Join([]string{
+		`import { Tabs, TabItem } from "@theme/Tabs"`,
+		`import Callout from "./Callout"`,
+		`export const meta = {`,
+		`  author: "someone",`,
+		`}`,
+		``,
+		`# Install`,
+		``,
+		`<Callout type="warning">Back up first.</Callout>`,
+		``,
+		`<Tabs>`,
+		`  <TabItem`,
+		`    value="npm"`,
+		`  >`,
+		`Run the installer.`,
+		`  </TabItem>`,
+		`</Tabs>`,
+		`{/* hidden note */}`,
+		`<Diagram src="a.png" />`,
+		`<div>plain html</div>`,
+		"```jsx",
+		`import React from "react"`,
+		`<Button />`,
+		"```",
+	}, "\n")
+
+	got := StripMDX(body)
+	want := strings.Join([]string{
+		``, ``, ``, ``, ``,
+		``,
+		`# Install`,
+		``,
+		`Back up first.`,
+		``,
+		``,
+		``,
+		``,
+		``,
+		`Run the installer.`,
+		``,
+		``,
+		``,
+		``,
+		`<div>plain html</div>`,
+		"```jsx",
+		`import React from "react"`,
+		`<Button />`,
+		"```",
+	}, "\n")
+
+	if got != want {
+		t.Errorf("StripMDX() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestStripMDX_PreservesLineCount(t *testing.T) {
+	body := "import A from 'a'\n\n<A>\ntext\n</A>\n"
+	got := StripMDX(body)
+	if strings.Count(got, "\n") != strings.Count(body, "\n") {
+		t.Errorf("line count changed: %q", got)
+	}
+}
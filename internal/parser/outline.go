@@ -0,0 +1,53 @@
+package parser
+
+import "strings"
+
+// Heading is a single ATX heading in a Markdown body.
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	Line  int    `json:"line"` // 1-based line within the body
+}
+
+// ParseHeading reports whether line is an ATX heading ("# Title") and
+// returns its level and text with closing #s removed.
+func ParseHeading(line string) (int, string, bool) {
+	if !strings.HasPrefix(line, "#") {
+		return 0, "", false
+	}
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	if level > 6 {
+		return 0, "", false
+	}
+	rest := line[level:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return 0, "", false
+	}
+	return level, strings.TrimSpace(strings.TrimRight(strings.TrimSpace(rest), "#")), true
+}
+
+// ExtractOutline returns the headings of a Markdown body in document order.
+// Lines inside fenced code blocks are ignored.
+func ExtractOutline(body string) []Heading {
+	var headings []Heading
+	fence := ""
+
+	for i, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		if level, text, ok := ParseHeading(line); ok {
+			headings = append(headings, Heading{Level: level, Text: text, Line: i + 1})
+		}
+	}
+
+	return headings
+}
@@ -0,0 +1,44 @@
+package parser
+
+import "testing"
+
+func TestParseHeading(t *testing.T) {
+	tests := []struct {
+		line      string
+		wantLevel int
+		wantText  string
+		wantOK    bool
+	}{
+		{"# Title", 1, "Title", true},
+		{"### Deep ###", 3, "Deep", true},
+		{"#", 1, "", true},
+		{"#hashtag", 0, "", false},
+		{"####### Too deep", 0, "", false},
+		{"Plain text", 0, "", false},
+	}
+	for _, tt := range tests {
+		level, text, ok := ParseHeading(tt.line)
+		if ok != tt.wantOK || level != tt.wantLevel || text != tt.wantText {
+			t.Errorf("ParseHeading(%q) = %d, %q, %v; want %d, %q, %v", tt.line, level, text, ok, tt.wantLevel, tt.wantText, tt.wantOK)
+		}
+	}
+}
+
+func TestExtractOutline(t *testing.T) {
+	body := "# Guide\n\nIntro.\n\n## Install\n\n```sh\n# not a heading\n```\n\n## Usage\n"
+	outline := ExtractOutline(body)
+
+	want := []Heading{
+		{Level: 1, Text: "Guide", Line: 1},
+		{Level: 2, Text: "Install", Line: 5},
+		{Level: 2, Text: "Usage", Line: 11},
+	}
+	if len(outline) != len(want) {
+		t.Fatalf("expected %d headings, got %d: %+v", len(want), len(outline), outline)
+	}
+	for i, h := range outline {
+		if h != want[i] {
+			t.Errorf("outline[%d] = %+v, want %+v", i, h, want[i])
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+)
+
+// WordCount returns the number of words in body. Whitespace-separated
+// tokens count as one word each, except that CJK characters, which are
+// written without spaces, count individually.
+func WordCount(body string) int {
+	count := 0
+	for _, field := range strings.Fields(body) {
+		inWord := false
+		for _, r := range field {
+			if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+				count++
+				inWord = false
+				continue
+			}
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				if !inWord {
+					count++
+					inWord = true
+				}
+				continue
+			}
+			inWord = false
+		}
+	}
+	return count
+}
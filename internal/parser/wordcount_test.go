@@ -0,0 +1,22 @@
+package parser
+
+import "testing"
+
+func TestWordCount(t *testing.T) {
+	tests := []struct {
+		body string
+		want int
+	}{
+		{"", 0},
+		{"Hello, world!", 2},
+		{"# Title\n\n- one two", 3},
+		{"日本語", 3},
+		{"Go は速い", 4},
+		{"--- *** ", 0},
+	}
+	for _, tt := range tests {
+		if got := WordCount(tt.body); got != tt.want {
+			t.Errorf("WordCount(%q) = %d, want %d", tt.body, got, tt.want)
+		}
+	}
+}
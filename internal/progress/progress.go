@@ -0,0 +1,97 @@
+// Package progress prints throttled progress lines with throughput and ETA
+// for long-running work such as the initial scan of a large repository.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is the minimum time between progress lines.
+const DefaultInterval = time.Second
+
+// Reporter writes progress for a single phase (e.g. "Scanning") to w.
+// Nothing is printed until the phase has run for at least one interval, so
+// small repositories only see the final summary. It is safe for concurrent
+// use.
+type Reporter struct {
+	w        io.Writer
+	label    string
+	interval time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	start time.Time
+	last  time.Time
+}
+
+// New creates a Reporter that starts timing immediately.
+func New(w io.Writer, label string) *Reporter {
+	return newReporter(w, label, DefaultInterval, time.Now)
+}
+
+func newReporter(w io.Writer, label string, interval time.Duration, now func() time.Time) *Reporter {
+	start := now()
+	return &Reporter{
+		w:        w,
+		label:    label,
+		interval: interval,
+		now:      now,
+		start:    start,
+		last:     start,
+	}
+}
+
+// Update records that done of total files have been processed and prints
+// a progress line if the interval has elapsed since the last one.
+func (r *Reporter) Update(done, total int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if now.Sub(r.last) < r.interval || done >= total {
+		return
+	}
+	r.last = now
+
+	elapsed := now.Sub(r.start)
+	rate := perSecond(done, elapsed)
+	line := fmt.Sprintf("%s: %d/%d files (%.0f files/s", r.label, done, total, rate)
+	if rate > 0 {
+		eta := time.Duration(float64(total-done) / rate * float64(time.Second))
+		line += ", ETA " + formatDuration(eta)
+	}
+	fmt.Fprintln(r.w, line+")")
+}
+
+// Finish prints a summary line for the phase.
+func (r *Reporter) Finish(done int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	elapsed := r.now().Sub(r.start)
+	fmt.Fprintf(r.w, "%s: %d files in %s (%.0f files/s)\n",
+		r.label, done, formatDuration(elapsed), perSecond(done, elapsed))
+}
+
+func perSecond(n int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
+
+// formatDuration rounds d for display: milliseconds below a second, tenths
+// of a second below a minute, whole seconds above.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	default:
+		return d.Round(time.Second).String()
+	}
+}
@@ -0,0 +1,69 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a now func whose time is advanced by the test.
+func fakeClock() (func() time.Time, func(time.Duration)) {
+	t := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time { return t }, func(d time.Duration) { t = t.Add(d) }
+}
+
+func TestReporter_Throttled(t *testing.T) {
+	var buf bytes.Buffer
+	now, advance := fakeClock()
+	r := newReporter(&buf, "Scanning", time.Second, now)
+
+	r.Update(10, 100)
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output before the first interval, got %q", buf.String())
+	}
+
+	advance(2 * time.Second)
+	r.Update(50, 100)
+	want := "Scanning: 50/100 files (25 files/s, ETA 2s)\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+
+	advance(500 * time.Millisecond)
+	r.Update(60, 100)
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("expected throttled output, got %q", buf.String())
+	}
+}
+
+func TestReporter_Finish(t *testing.T) {
+	var buf bytes.Buffer
+	now, advance := fakeClock()
+	r := newReporter(&buf, "Indexing", time.Second, now)
+
+	advance(250 * time.Millisecond)
+	r.Update(100, 100)
+	r.Finish(100)
+
+	want := "Indexing: 100 files in 250ms (400 files/s)\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{1234 * time.Microsecond, "1ms"},
+		{1540 * time.Millisecond, "1.5s"},
+		{95*time.Second + 400*time.Millisecond, "1m35s"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.d); got != tt.want {
+			t.Errorf("formatDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
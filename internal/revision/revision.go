@@ -0,0 +1,296 @@
+// Package revision indexes Git revisions straight from git objects, so that
+// any branch, tag or commit can be browsed and searched without checking it
+// out. Indexes are cached by commit hash.
+package revision
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/esakat/markdown-kb/internal/charset"
+	"github.com/esakat/markdown-kb/internal/config"
+	gitpkg "github.com/esakat/markdown-kb/internal/git"
+	"github.com/esakat/markdown-kb/internal/ignore"
+	"github.com/esakat/markdown-kb/internal/index"
+	"github.com/esakat/markdown-kb/internal/parser"
+	"github.com/esakat/markdown-kb/internal/scanner"
+)
+
+// DefaultCacheSize is the number of revision indexes a Cache keeps.
+const DefaultCacheSize = 4
+
+// blobBatchSize bounds how many blobs are held in memory at once.
+const blobBatchSize = 500
+
+// Load indexes the documents in commit into store. Files are selected with
+// the repository's extensions and include/exclude rules; .gitignore and
+// .kbignore files are read from the working tree. progress, if non-nil,
+// receives "scanning" and "indexing" progress.
+func Load(store *index.Store, rootDir string, repoCfg config.RepoConfig, commit string, progress func(phase string, done, total int)) error {
+	if progress == nil {
+		progress = func(string, int, int) {}
+	}
+
+	entries, err := gitpkg.ListTree(rootDir, commit)
+	if err != nil {
+		return err
+	}
+	modTime, err := gitpkg.CommitTime(rootDir, commit)
+	if err != nil {
+		return err
+	}
+
+	matcher := ignore.New(rootDir, repoCfg.Include, repoCfg.Exclude)
+	exts := parser.NormalizeExtensions(repoCfg.Extensions)
+	var files []gitpkg.TreeEntry
+	for _, e := range entries {
+		if parser.HasExtension(path.Base(e.Path), exts) && !matcher.IgnoredPath(e.Path, false) {
+			files = append(files, e)
+		}
+	}
+
+	var docs []scanner.Document
+	var diags []scanner.Diagnostic
+	progress("scanning", 0, len(files))
+	for start := 0; start < len(files); start += blobBatchSize {
+		batch := files[start:min(start+blobBatchSize, len(files))]
+		hashes := make([]string, len(batch))
+		for i, e := range batch {
+			hashes[i] = e.Hash
+		}
+		blobs, err := gitpkg.ReadBlobs(rootDir, hashes)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range batch {
+			doc, err := parseBlob(e.Path, blobs[e.Hash], modTime)
+			if err != nil {
+				diags = append(diags, scanner.Diagnostic{Path: e.Path, Message: err.Error()})
+				continue
+			}
+			docs = append(docs, doc)
+		}
+		progress("scanning", start+len(batch), len(files))
+	}
+
+	err = store.IndexBatch(docs, func(done, total int) {
+		progress("indexing", done, total)
+	})
+	for _, d := range diags {
+		if diagErr := store.SetDiagnostic(d); diagErr != nil && err == nil {
+			err = diagErr
+		}
+	}
+	return err
+}
+
+// parseBlob parses the content of the file at filePath in a commit made at
+// modTime.
+func parseBlob(filePath string, data []byte, modTime time.Time) (scanner.Document, error) {
+	doc := scanner.Document{RelPath: filePath, ModTime: modTime, Size: int64(len(data))}
+	text, enc, err := charset.Decode(data)
+	if err != nil {
+		return doc, fmt.Errorf("decoding file: %w", err)
+	}
+	if enc != charset.UTF8 {
+		doc.Encoding = enc
+	}
+	scanner.ParseContent(&doc, text)
+	return doc, nil
+}
+
+// newStore creates an empty index configured from repoCfg.
+func newStore(repoCfg config.RepoConfig) (*index.Store, error) {
+	store, err := index.New()
+	if err != nil {
+		return nil, fmt.Errorf("creating index: %w", err)
+	}
+	store.SetChunkTokens(repoCfg.Chunks.MaxTokens)
+	store.SetExtensions(repoCfg.Extensions)
+	return store, nil
+}
+
+// Cache keeps the indexes of recently used revisions of one repository,
+// keyed by commit, so that switching between refs is cheap. It is safe for
+// concurrent use.
+type Cache struct {
+	rootDir string
+	repoCfg config.RepoConfig
+	size    int
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	order   []string // commits, least recently used first
+}
+
+// entry is a cached revision index. ready is closed once store or err is
+// set. An evicted entry's store is closed when its last user releases it.
+type entry struct {
+	ready   chan struct{}
+	store   *index.Store
+	err     error
+	refs    int
+	evicted bool
+}
+
+// NewCache creates a cache for the repository at rootDir holding up to size
+// revisions (DefaultCacheSize when size <= 0).
+func NewCache(rootDir string, repoCfg config.RepoConfig, size int) *Cache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &Cache{
+		rootDir: rootDir,
+		repoCfg: repoCfg,
+		size:    size,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Acquire returns the index for rev, building it on first use, together
+// with the resolved commit hash. release must be called once the store is
+// no longer used. The error wraps git.ErrUnknownRevision when rev doesn't
+// name a commit.
+func (c *Cache) Acquire(rev string) (store *index.Store, commit string, release func(), err error) {
+	commit, err = gitpkg.ResolveRevision(c.rootDir, rev)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	c.mu.Lock()
+	e, ok := c.entries[commit]
+	if !ok {
+		e = &entry{ready: make(chan struct{})}
+		c.entries[commit] = e
+		go c.build(commit, e)
+	}
+	e.refs++
+	c.touch(commit)
+	c.mu.Unlock()
+
+	<-e.ready
+	release = func() { c.release(e) }
+	if e.err != nil {
+		release()
+		return nil, "", nil, e.err
+	}
+	return e.store, commit, release, nil
+}
+
+// ReadDocument reads the document at filePath in commit straight from git
+// objects, without indexing the revision, as the index for commit would
+// return it. commit must be a hash from git.ResolveRevision. It returns nil
+// if commit has no such document.
+func (c *Cache) ReadDocument(commit, filePath string) (*index.DocumentDetail, error) {
+	exts := parser.NormalizeExtensions(c.repoCfg.Extensions)
+	if !fs.ValidPath(filePath) || !parser.HasExtension(path.Base(filePath), exts) ||
+		ignore.New(c.rootDir, c.repoCfg.Include, c.repoCfg.Exclude).IgnoredPath(filePath, false) {
+		return nil, nil
+	}
+	data, err := gitpkg.ReadFile(c.rootDir, commit, filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	modTime, err := gitpkg.CommitTime(c.rootDir, commit)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseBlob(filePath, data, modTime)
+	if err != nil {
+		// Undecodable files are diagnostics, not documents.
+		return nil, nil
+	}
+	title, _ := doc.Frontmatter["title"].(string)
+	return &index.DocumentDetail{
+		DocumentSummary: index.DocumentSummary{Path: filePath, Title: title, Meta: doc.Frontmatter, ModTime: modTime, Size: doc.Size},
+		Body:            doc.Body,
+		Encoding:        doc.Encoding,
+	}, nil
+}
+
+func (c *Cache) build(commit string, e *entry) {
+	store, err := newStore(c.repoCfg)
+	if err == nil {
+		if err = Load(store, c.rootDir, c.repoCfg, commit, nil); err != nil {
+			store.Close()
+			store = nil
+			err = fmt.Errorf("indexing %s: %w", commit, err)
+		}
+	}
+
+	c.mu.Lock()
+	e.store, e.err = store, err
+	if err != nil && c.entries[commit] == e {
+		// Don't cache failures; the next request retries.
+		c.remove(commit)
+	}
+	c.mu.Unlock()
+	close(e.ready)
+}
+
+// touch marks commit as most recently used and evicts the least recently
+// used entries beyond the cache size. c.mu must be held.
+func (c *Cache) touch(commit string) {
+	for i, k := range c.order {
+		if k == commit {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.order = append(c.order, commit)
+	for len(c.order) > c.size {
+		c.remove(c.order[0])
+	}
+}
+
+// remove drops commit from the cache, closing its store once unused.
+// c.mu must be held.
+func (c *Cache) remove(commit string) {
+	e := c.entries[commit]
+	delete(c.entries, commit)
+	for i, k := range c.order {
+		if k == commit {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	if e != nil {
+		e.evicted = true
+		c.closeIfUnused(e)
+	}
+}
+
+func (c *Cache) release(e *entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.refs--
+	c.closeIfUnused(e)
+}
+
+// closeIfUnused closes an evicted entry's store once nobody uses it and it
+// has finished building. c.mu must be held.
+func (c *Cache) closeIfUnused(e *entry) {
+	if !e.evicted || e.refs > 0 || e.store == nil {
+		return
+	}
+	e.store.Close()
+	e.store = nil
+}
+
+// Close releases all cached indexes. Stores still in use are closed when
+// released.
+func (c *Cache) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for commit := range c.entries {
+		c.remove(commit)
+	}
+}
@@ -0,0 +1,224 @@
+package revision
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/esakat/markdown-kb/internal/config"
+	gitpkg "github.com/esakat/markdown-kb/internal/git"
+	"github.com/esakat/markdown-kb/internal/index"
+)
+
+// newTestRepo creates a repository with a v1 tag and a later commit, and
+// leaves uncommitted edits in the working tree.
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test",
+			"GIT_AUTHOR_EMAIL=test@test.com",
+			"GIT_COMMITTER_NAME=Test",
+			"GIT_COMMITTER_EMAIL=test@test.com",
+		)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755)
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+	}
+
+	run("init", "-b", "main")
+	write("guide.md", "---\ntitle: Guide v1\n---\n\nInstall with make.\n")
+	write("notes.txt", "not a document")
+	write("sjis.md", "\x83\x65\x83\x58\x83\x67\n") // "テスト" in Shift_JIS
+	run("add", ".")
+	run("commit", "-m", "v1")
+	run("tag", "v1")
+
+	write("guide.md", "---\ntitle: Guide v2\n---\n\nInstall with go install.\n")
+	write("docs/new.md", "# New page\n")
+	run("add", ".")
+	run("commit", "-m", "v2")
+
+	write("guide.md", "# Uncommitted\n")
+	return dir
+}
+
+func loadRevision(t *testing.T, dir, rev string) *index.Store {
+	t.Helper()
+	commit, err := gitpkg.ResolveRevision(dir, rev)
+	if err != nil {
+		t.Fatalf("ResolveRevision(%q) error = %v", rev, err)
+	}
+	store, err := index.New()
+	if err != nil {
+		t.Fatalf("index.New() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := Load(store, dir, config.RepoConfig{}, commit, nil); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return store
+}
+
+func TestLoad(t *testing.T) {
+	dir := newTestRepo(t)
+
+	store := loadRevision(t, dir, "v1")
+	_, total, _ := store.ListDocuments(0, 0)
+	if total != 2 {
+		t.Errorf("v1 documents = %d, want 2", total)
+	}
+	doc, _ := store.GetDocument("guide.md")
+	if doc == nil || doc.Title != "Guide v1" {
+		t.Fatalf("guide.md at v1 = %+v", doc)
+	}
+	if doc.ModTime.IsZero() {
+		t.Error("expected the commit time as ModTime")
+	}
+	sjis, _ := store.GetDocument("sjis.md")
+	if sjis == nil || sjis.Encoding != "shift_jis" || sjis.Body != "テスト\n" {
+		t.Errorf("sjis.md at v1 = %+v", sjis)
+	}
+
+	store = loadRevision(t, dir, "main")
+	doc, _ = store.GetDocument("guide.md")
+	if doc == nil || doc.Title != "Guide v2" {
+		t.Errorf("guide.md at main = %+v, want committed content", doc)
+	}
+	if doc, _ := store.GetDocument("docs/new.md"); doc == nil {
+		t.Error("docs/new.md missing at main")
+	}
+	results, _, _ := store.Search("go install", 10, 0)
+	if len(results) != 1 {
+		t.Errorf("search at main = %+v", results)
+	}
+}
+
+func TestLoad_Exclude(t *testing.T) {
+	dir := newTestRepo(t)
+	commit, _ := gitpkg.ResolveRevision(dir, "main")
+	store, _ := index.New()
+	defer store.Close()
+
+	if err := Load(store, dir, config.RepoConfig{Exclude: []string{"docs/**"}}, commit, nil); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if doc, _ := store.GetDocument("docs/new.md"); doc != nil {
+		t.Error("excluded docs/new.md was indexed")
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir := newTestRepo(t)
+	c := NewCache(dir, config.RepoConfig{}, 1)
+	defer c.Close()
+
+	store, commit, release, err := c.Acquire("v1")
+	if err != nil {
+		t.Fatalf("Acquire(v1) error = %v", err)
+	}
+	if len(commit) != 40 {
+		t.Errorf("commit = %q", commit)
+	}
+	again, _, release2, _ := c.Acquire(commit)
+	if again != store {
+		t.Error("expected the cached index for the same commit")
+	}
+	release2()
+
+	// Evicting v1 must not close it while still in use.
+	other, _, releaseOther, err := c.Acquire("main")
+	if err != nil {
+		t.Fatalf("Acquire(main) error = %v", err)
+	}
+	if doc, err := store.GetDocument("guide.md"); err != nil || doc == nil || doc.Title != "Guide v1" {
+		t.Errorf("evicted store unusable while acquired: %+v, %v", doc, err)
+	}
+	release()
+	releaseOther()
+
+	if other == store {
+		t.Error("expected distinct indexes per commit")
+	}
+
+	if _, _, _, err := c.Acquire("no-such-ref"); !errors.Is(err, gitpkg.ErrUnknownRevision) {
+		t.Errorf("Acquire(no-such-ref) error = %v, want ErrUnknownRevision", err)
+	}
+}
+
+func TestCache_ConcurrentAcquire(t *testing.T) {
+	dir := newTestRepo(t)
+	c := NewCache(dir, config.RepoConfig{}, 0)
+	defer c.Close()
+
+	stores := make([]*index.Store, 8)
+	var wg sync.WaitGroup
+	for i := range stores {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store, _, release, err := c.Acquire("v1")
+			if err != nil {
+				t.Errorf("Acquire() error = %v", err)
+				return
+			}
+			defer release()
+			stores[i] = store
+		}(i)
+	}
+	wg.Wait()
+
+	for _, s := range stores[1:] {
+		if s != stores[0] {
+			t.Fatal("concurrent requests built separate indexes")
+		}
+	}
+}
+
+func TestCache_ReadDocument(t *testing.T) {
+	dir := newTestRepo(t)
+	c := NewCache(dir, config.RepoConfig{Exclude: []string{"docs/**"}}, 0)
+	defer c.Close()
+	commit, _ := gitpkg.ResolveRevision(dir, "v1")
+	store := loadRevision(t, dir, "v1")
+
+	// Documents read one by one match the indexed revision.
+	for _, path := range []string{"guide.md", "sjis.md"} {
+		doc, err := c.ReadDocument(commit, path)
+		if err != nil || doc == nil {
+			t.Fatalf("ReadDocument(%s) = %+v, %v", path, doc, err)
+		}
+		indexed, _ := store.GetDocument(path)
+		got, _ := json.Marshal(doc)
+		want, _ := json.Marshal(indexed)
+		if string(got) != string(want) {
+			t.Errorf("ReadDocument(%s) = %s, want %s", path, got, want)
+		}
+	}
+
+	head, _ := gitpkg.ResolveRevision(dir, "main")
+	for _, path := range []string{"notes.txt", "missing.md", "docs/new.md", "../guide.md"} {
+		if doc, err := c.ReadDocument(head, path); err != nil || doc != nil {
+			t.Errorf("ReadDocument(%s) = %+v, %v, want none", path, doc, err)
+		}
+	}
+
+	if len(c.entries) != 0 {
+		t.Errorf("ReadDocument indexed %d revisions", len(c.entries))
+	}
+}
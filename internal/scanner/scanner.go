@@ -5,11 +5,15 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"time"
-	"unicode/utf8"
 
+	"github.com/esakat/markdown-kb/internal/charset"
+	"github.com/esakat/markdown-kb/internal/fswalk"
+	"github.com/esakat/markdown-kb/internal/ignore"
 	"github.com/esakat/markdown-kb/internal/parser"
 )
 
@@ -19,27 +23,64 @@ type Document struct {
 	AbsPath     string         // absolute path
 	Frontmatter map[string]any // parsed YAML frontmatter (nil if none or invalid)
 	Body        string         // content after frontmatter
+	BodyOffset  int            // number of lines preceding Body in the file
 	ModTime     time.Time      // file modification time
 	Size        int64          // file size in bytes
+	Encoding    string         // original encoding if transcoded from non-UTF-8 (e.g. "shift_jis")
 }
 
-// skipDirs contains directory names that should be skipped during scanning.
-var skipDirs = map[string]bool{
-	".git":         true,
-	".svn":         true,
-	".hg":          true,
-	"node_modules": true,
+// Diagnostic reports a document file that could not be loaded.
+type Diagnostic struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Options controls which files Scan picks up.
+type Options struct {
+	// Ignore decides which paths are skipped. When nil, a matcher with
+	// the built-in rules plus .gitignore and .kbignore files is used.
+	Ignore *ignore.Matcher
+	// Extensions lists the accepted document extensions. When empty,
+	// parser.DefaultExtensions is used.
+	Extensions []string
+	// Workers bounds how many files are read concurrently. When <= 0,
+	// DefaultWorkers is used.
+	Workers int
+	// Progress, if set, is called after each file is read with the
+	// number of files processed so far and the total. Calls are
+	// serialized.
+	Progress func(done, total int)
+	// OnDiagnostic, if set, is called for each file that is skipped
+	// because it can't be read or decoded. Calls are serialized.
+	OnDiagnostic func(Diagnostic)
+	// FollowSymlinks makes the scan follow symbolic links whose targets
+	// are inside rootDir or SymlinkRoots. Files reachable through several
+	// paths are returned once.
+	FollowSymlinks bool
+	// SymlinkRoots lists additional absolute directories that followed
+	// links may point into.
+	SymlinkRoots []string
 }
 
 // Scan recursively walks rootDir and returns all .md files as Documents.
-// Results are sorted by RelPath. Symlinks are not followed.
+// Results are sorted by RelPath. Symlinks are not followed; see
+// Options.FollowSymlinks.
 func Scan(rootDir string) ([]Document, error) {
+	return ScanWithOptions(rootDir, Options{})
+}
+
+// ScanWithOptions is like Scan but applies opts.
+func ScanWithOptions(rootDir string, opts Options) ([]Document, error) {
 	absRoot, err := filepath.Abs(rootDir)
 	if err != nil {
 		return nil, fmt.Errorf("resolving root directory: %w", err)
 	}
 
-	info, err := os.Lstat(absRoot)
+	stat := os.Lstat
+	if opts.FollowSymlinks {
+		stat = os.Stat
+	}
+	info, err := stat(absRoot)
 	if err != nil {
 		return nil, fmt.Errorf("accessing directory %q: %w", rootDir, err)
 	}
@@ -47,80 +88,47 @@ func Scan(rootDir string) ([]Document, error) {
 		return nil, fmt.Errorf("%q is not a directory", rootDir)
 	}
 
-	var docs []Document
+	matcher := opts.Ignore
+	if matcher == nil {
+		matcher = ignore.New(absRoot, nil, nil)
+	}
+	exts := parser.NormalizeExtensions(opts.Extensions)
 
-	err = filepath.WalkDir(absRoot, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil // skip unreadable entries
-		}
+	// Walk serially to collect candidates; reading and parsing happen in
+	// a worker pool below.
+	var files []candidate
+	seen := make(map[fswalk.FileID]int) // index into files
 
-		// Skip symlinks
-		if d.Type()&fs.ModeSymlink != 0 {
-			if d.IsDir() {
+	walkOpts := fswalk.Options{FollowSymlinks: opts.FollowSymlinks, AllowedRoots: opts.SymlinkRoots}
+	err = fswalk.Walk(absRoot, walkOpts, func(e fswalk.Entry) error {
+		if e.IsDir {
+			// Skip ignored directories
+			if matcher.Ignored(e.RelPath, true) {
 				return fs.SkipDir
 			}
 			return nil
 		}
 
-		if d.IsDir() {
-			// Skip known directories and dot-prefixed directories (except root)
-			name := d.Name()
-			if path != absRoot {
-				if skipDirs[name] || strings.HasPrefix(name, ".") {
-					return fs.SkipDir
-				}
-			}
+		// Only process document files
+		if !parser.HasExtension(e.RelPath, exts) {
 			return nil
 		}
 
-		// Only process .md files
-		if filepath.Ext(path) != ".md" {
-			return nil
-		}
-
-		relPath, err := filepath.Rel(absRoot, path)
-		if err != nil {
-			return nil // skip on error
-		}
-
-		fi, err := d.Info()
-		if err != nil {
-			return nil
-		}
-
-		doc := Document{
-			RelPath: relPath,
-			AbsPath: path,
-			ModTime: fi.ModTime(),
-			Size:    fi.Size(),
-		}
-
-		// Empty file
-		if fi.Size() == 0 {
-			docs = append(docs, doc)
+		if matcher.Ignored(e.RelPath, false) {
 			return nil
 		}
 
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return nil // skip unreadable files
-		}
-
-		// Skip non-UTF-8 binary files
-		if !utf8.Valid(content) {
+		// A file reachable through several links is kept once, preferring
+		// a path that doesn't go through a link.
+		c := candidate{relPath: e.RelPath, absPath: e.AbsPath, viaSymlink: e.ViaSymlink}
+		if i, ok := seen[e.ID]; ok {
+			if files[i].viaSymlink && !c.viaSymlink {
+				files[i] = c
+			}
 			return nil
 		}
-
-		meta, body, parseErr := parser.ParseFrontmatter(strings.NewReader(string(content)))
-		if parseErr != nil {
-			// Bad frontmatter: put full content in body, leave frontmatter nil
-			doc.Body = string(content)
-		} else {
-			doc.Frontmatter = meta
-			doc.Body = body
-		}
-
-		docs = append(docs, doc)
+		seen[e.ID] = len(files)
+		files = append(files, c)
 		return nil
 	})
 
@@ -128,9 +136,164 @@ func Scan(rootDir string) ([]Document, error) {
 		return nil, fmt.Errorf("walking directory: %w", err)
 	}
 
+	docs := readAll(files, opts)
+
 	sort.Slice(docs, func(i, j int) bool {
 		return docs[i].RelPath < docs[j].RelPath
 	})
 
 	return docs, nil
 }
+
+// candidate is a file selected by the walk, waiting to be read.
+type candidate struct {
+	relPath    string
+	absPath    string
+	viaSymlink bool
+}
+
+// readAll reads and parses files with a bounded number of workers.
+// Unreadable and binary files are dropped.
+func readAll(files []candidate, opts Options) []Document {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers()
+	}
+	if workers > len(files) {
+		workers = len(files)
+	}
+
+	results := make([]*Document, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				doc, err := readDocument(files[i].relPath, files[i].absPath)
+				if err == nil {
+					results[i] = &doc
+				}
+
+				mu.Lock()
+				if err != nil && opts.OnDiagnostic != nil {
+					opts.OnDiagnostic(Diagnostic{Path: files[i].relPath, Message: err.Error()})
+				}
+				done++
+				if opts.Progress != nil {
+					opts.Progress(done, len(files))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	docs := make([]Document, 0, len(files))
+	for _, doc := range results {
+		if doc != nil {
+			docs = append(docs, *doc)
+		}
+	}
+	return docs
+}
+
+// ReadDocument reads and parses the document at relPath under rootDir.
+// Files in a non-UTF-8 encoding are transcoded to UTF-8; files that can't
+// be decoded return an error wrapping charset.ErrUnknownEncoding.
+func ReadDocument(rootDir, relPath string) (Document, error) {
+	return readDocument(relPath, filepath.Join(rootDir, relPath))
+}
+
+func readDocument(relPath, absPath string) (Document, error) {
+	fi, err := os.Stat(absPath)
+	if err != nil {
+		return Document{}, fmt.Errorf("reading file: %w", err)
+	}
+
+	doc := Document{
+		RelPath: relPath,
+		AbsPath: absPath,
+		ModTime: fi.ModTime(),
+		Size:    fi.Size(),
+	}
+
+	// Empty file
+	if fi.Size() == 0 {
+		return doc, nil
+	}
+
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return Document{}, fmt.Errorf("reading file: %w", err)
+	}
+
+	text, enc, err := charset.Decode(content)
+	if err != nil {
+		return Document{}, fmt.Errorf("decoding file: %w", err)
+	}
+	if enc != charset.UTF8 {
+		doc.Encoding = enc
+	}
+
+	ParseContent(&doc, text)
+	return doc, nil
+}
+
+// DefaultWorkers returns the number of concurrent file readers used when
+// Options.Workers is not set.
+func DefaultWorkers() int {
+	n := runtime.NumCPU() * 2
+	if n > maxDefaultWorkers {
+		n = maxDefaultWorkers
+	}
+	return n
+}
+
+// maxDefaultWorkers bounds the default read concurrency so that large
+// machines don't exhaust file descriptors.
+const maxDefaultWorkers = 16
+
+// ParseContent splits raw file content into frontmatter and body and stores
+// them on doc. Bad frontmatter leaves Frontmatter nil and keeps the full
+// content as Body. MDX syntax is stripped from the body of .mdx files, so
+// doc.RelPath must be set beforehand.
+func ParseContent(doc *Document, content string) {
+	meta, body, parseErr := parser.ParseFrontmatter(strings.NewReader(content))
+	if parseErr != nil {
+		doc.Frontmatter = nil
+		doc.Body = content
+		doc.BodyOffset = 0
+		if parser.IsMDX(doc.RelPath) {
+			doc.Body = parser.StripMDX(doc.Body)
+		}
+		return
+	}
+	if parser.IsMDX(doc.RelPath) {
+		body = parser.StripMDX(body)
+	}
+	doc.Frontmatter = meta
+	doc.Body = body
+	doc.BodyOffset = countLines(content) - strings.Count(body, "\n")
+	if doc.BodyOffset < 0 {
+		doc.BodyOffset = 0
+	}
+}
+
+// countLines returns the number of lines in s, counting a trailing line
+// without a newline.
+func countLines(s string) int {
+	n := strings.Count(s, "\n")
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		n++
+	}
+	return n
+}
@@ -1,10 +1,14 @@
 package scanner
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
+
+	"github.com/esakat/markdown-kb/internal/ignore"
 )
 
 func testdataDir(t *testing.T) string {
@@ -187,8 +191,9 @@ func TestScan_NotADirectory(t *testing.T) {
 
 func TestScan_BinaryFileSkipped(t *testing.T) {
 	tmp := t.TempDir()
-	// Write a .md file with invalid UTF-8 bytes
-	if err := os.WriteFile(filepath.Join(tmp, "binary.md"), []byte{0xff, 0xfe, 0x00, 0x01}, 0o644); err != nil {
+	// Write a .md file with bytes that are not text in any supported
+	// encoding (FF FE alone would be a UTF-16LE byte order mark)
+	if err := os.WriteFile(filepath.Join(tmp, "binary.md"), []byte{0x89, 0x00, 0xff, 0xfe, 0x00, 0x01}, 0o644); err != nil {
 		t.Fatal(err)
 	}
 	// Write a valid .md file
@@ -196,7 +201,10 @@ func TestScan_BinaryFileSkipped(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	docs, err := Scan(tmp)
+	var diags []Diagnostic
+	docs, err := ScanWithOptions(tmp, Options{OnDiagnostic: func(d Diagnostic) {
+		diags = append(diags, d)
+	}})
 	if err != nil {
 		t.Fatalf("Scan() error = %v", err)
 	}
@@ -206,6 +214,42 @@ func TestScan_BinaryFileSkipped(t *testing.T) {
 	if docs[0].RelPath != "valid.md" {
 		t.Errorf("expected valid.md, got %q", docs[0].RelPath)
 	}
+	if len(diags) != 1 || diags[0].Path != "binary.md" {
+		t.Errorf("expected a diagnostic for binary.md, got %+v", diags)
+	}
+}
+
+func TestScan_LegacyEncodings(t *testing.T) {
+	tmp := t.TempDir()
+	// "---\ntitle: 設計\n---\n# 見出し" in Shift_JIS
+	sjis := []byte("---\ntitle: \x90\xdd\x8c\x76\n---\n# \x8c\xa9\x8f\x6f\x82\xb5")
+	// "# 見出し" in UTF-16LE with a byte order mark
+	utf16 := []byte("\xff\xfe#\x00 \x00\x8b\x89\xfa\x51\x57\x30")
+	os.WriteFile(filepath.Join(tmp, "sjis.md"), sjis, 0o644)
+	os.WriteFile(filepath.Join(tmp, "utf16.md"), utf16, 0o644)
+	os.WriteFile(filepath.Join(tmp, "utf8.md"), []byte("# 見出し"), 0o644)
+
+	docs, err := Scan(tmp)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(docs) != 3 {
+		t.Fatalf("expected 3 docs, got %d", len(docs))
+	}
+
+	byPath := make(map[string]Document)
+	for _, d := range docs {
+		byPath[d.RelPath] = d
+	}
+	if d := byPath["sjis.md"]; d.Encoding != "shift_jis" || d.Frontmatter["title"] != "設計" || !strings.Contains(d.Body, "# 見出し") {
+		t.Errorf("sjis.md = %+v", d)
+	}
+	if d := byPath["utf16.md"]; d.Encoding != "utf-16le" || strings.TrimSpace(d.Body) != "# 見出し" {
+		t.Errorf("utf16.md = %+v", d)
+	}
+	if d := byPath["utf8.md"]; d.Encoding != "" {
+		t.Errorf("utf8.md Encoding = %q, want empty", d.Encoding)
+	}
 }
 
 func TestScan_SymlinkNotFollowed(t *testing.T) {
@@ -252,6 +296,162 @@ func TestScan_NonMdFilesIgnored(t *testing.T) {
 	}
 }
 
+func TestScan_HonoursIgnoreFiles(t *testing.T) {
+	tmp := t.TempDir()
+	os.MkdirAll(filepath.Join(tmp, "build"), 0o755)
+	os.MkdirAll(filepath.Join(tmp, "docs", "drafts"), 0o755)
+	os.WriteFile(filepath.Join(tmp, ".gitignore"), []byte("build/\n"), 0o644)
+	os.WriteFile(filepath.Join(tmp, ".kbignore"), []byte("CHANGELOG.md\n"), 0o644)
+	os.WriteFile(filepath.Join(tmp, "docs", ".gitignore"), []byte("drafts/\n"), 0o644)
+	os.WriteFile(filepath.Join(tmp, "readme.md"), []byte("# Read"), 0o644)
+	os.WriteFile(filepath.Join(tmp, "CHANGELOG.md"), []byte("# Changes"), 0o644)
+	os.WriteFile(filepath.Join(tmp, "build", "out.md"), []byte("# Out"), 0o644)
+	os.WriteFile(filepath.Join(tmp, "docs", "guide.md"), []byte("# Guide"), 0o644)
+	os.WriteFile(filepath.Join(tmp, "docs", "drafts", "wip.md"), []byte("# WIP"), 0o644)
+
+	docs, err := Scan(tmp)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	var got []string
+	for _, d := range docs {
+		got = append(got, filepath.ToSlash(d.RelPath))
+	}
+	want := []string{"docs/guide.md", "readme.md"}
+	if len(got) != len(want) {
+		t.Fatalf("Scan() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("docs[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestScanWithOptions_IncludeExclude(t *testing.T) {
+	tmp := t.TempDir()
+	os.MkdirAll(filepath.Join(tmp, "docs", "generated"), 0o755)
+	os.WriteFile(filepath.Join(tmp, "readme.md"), []byte("# Read"), 0o644)
+	os.WriteFile(filepath.Join(tmp, "docs", "guide.md"), []byte("# Guide"), 0o644)
+	os.WriteFile(filepath.Join(tmp, "docs", "generated", "api.md"), []byte("# API"), 0o644)
+
+	m := ignore.New(tmp, []string{"docs/**"}, []string{"docs/generated/**"})
+	docs, err := ScanWithOptions(tmp, Options{Ignore: m})
+	if err != nil {
+		t.Fatalf("ScanWithOptions() error = %v", err)
+	}
+	if len(docs) != 1 || filepath.ToSlash(docs[0].RelPath) != "docs/guide.md" {
+		t.Errorf("expected only docs/guide.md, got %v", docs)
+	}
+}
+
+func TestScanWithOptions_Extensions(t *testing.T) {
+	tmp := t.TempDir()
+	os.WriteFile(filepath.Join(tmp, "a.md"), []byte("# A"), 0o644)
+	os.WriteFile(filepath.Join(tmp, "b.markdown"), []byte("# B"), 0o644)
+	os.WriteFile(filepath.Join(tmp, "c.md.txt"), []byte("# C"), 0o644)
+	os.WriteFile(filepath.Join(tmp, "d.txt"), []byte("plain"), 0o644)
+	os.WriteFile(filepath.Join(tmp, "e.mdx"), []byte("import X from './x'\n\n# E\n\n<X>\nBody\n</X>\n"), 0o644)
+
+	docs, err := ScanWithOptions(tmp, Options{Extensions: []string{"md", ".markdown", ".mdx", ".md.txt"}})
+	if err != nil {
+		t.Fatalf("ScanWithOptions() error = %v", err)
+	}
+
+	var got []string
+	for _, d := range docs {
+		got = append(got, d.RelPath)
+	}
+	want := []string{"a.md", "b.markdown", "c.md.txt", "e.mdx"}
+	if len(got) != len(want) {
+		t.Fatalf("docs = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("docs[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	mdx := docs[3].Body
+	if strings.Contains(mdx, "import") || strings.Contains(mdx, "<X>") {
+		t.Errorf("MDX syntax not stripped: %q", mdx)
+	}
+	if !strings.Contains(mdx, "# E") || !strings.Contains(mdx, "Body") {
+		t.Errorf("MDX prose missing: %q", mdx)
+	}
+}
+
+func TestScanWithOptions_WorkersAndProgress(t *testing.T) {
+	tmp := t.TempDir()
+	for i := 0; i < 50; i++ {
+		dir := filepath.Join(tmp, fmt.Sprintf("d%d", i%5))
+		os.MkdirAll(dir, 0o755)
+		os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%02d.md", i)), []byte(fmt.Sprintf("---\ntitle: F%d\n---\n# F%d", i, i)), 0o644)
+	}
+
+	var calls, last int
+	docs, err := ScanWithOptions(tmp, Options{
+		Workers: 4,
+		Progress: func(done, total int) {
+			calls++
+			last = done
+			if total != 50 {
+				t.Errorf("total = %d, want 50", total)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("ScanWithOptions() error = %v", err)
+	}
+	if len(docs) != 50 {
+		t.Fatalf("expected 50 docs, got %d", len(docs))
+	}
+	if calls != 50 || last != 50 {
+		t.Errorf("progress calls = %d, last = %d, want 50", calls, last)
+	}
+	for _, d := range docs {
+		if d.Frontmatter["title"] == nil {
+			t.Errorf("%s: frontmatter not parsed", d.RelPath)
+		}
+	}
+}
+
+func TestScanWithOptions_FollowSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	shared := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "notes"), 0o755)
+	os.WriteFile(filepath.Join(dir, "notes", "a.md"), []byte("# A"), 0o644)
+	os.WriteFile(filepath.Join(shared, "s.md"), []byte("# S"), 0o644)
+	if err := os.Symlink("notes", filepath.Join(dir, "alias")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	os.Symlink(shared, filepath.Join(dir, "shared"))
+
+	docs, err := Scan(dir)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(docs) != 1 || docs[0].RelPath != filepath.Join("notes", "a.md") {
+		t.Errorf("without following, got %v", docs)
+	}
+
+	docs, err = ScanWithOptions(dir, Options{FollowSymlinks: true, SymlinkRoots: []string{shared}})
+	if err != nil {
+		t.Fatalf("ScanWithOptions() error = %v", err)
+	}
+	var paths []string
+	for _, d := range docs {
+		paths = append(paths, filepath.ToSlash(d.RelPath))
+	}
+	// alias/a.md is the same file as notes/a.md and is reported once,
+	// under the path without a link.
+	want := []string{"notes/a.md", "shared/s.md"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+}
+
 func TestScan_ResultsAreSorted(t *testing.T) {
 	docs, err := Scan(testdataDir(t))
 	if err != nil {
@@ -267,3 +467,20 @@ func TestScan_ResultsAreSorted(t *testing.T) {
 		t.Errorf("results should be sorted by RelPath, got %v", paths)
 	}
 }
+
+func TestParseContent_BodyOffset(t *testing.T) {
+	var doc Document
+	ParseContent(&doc, "---\ntitle: A\ntags:\n  - x\n---\n\n# A\n")
+	if doc.BodyOffset != 5 {
+		t.Errorf("BodyOffset = %d, want 5", doc.BodyOffset)
+	}
+	if doc.Frontmatter["title"] != "A" {
+		t.Errorf("title = %v, want %q", doc.Frontmatter["title"], "A")
+	}
+
+	var plain Document
+	ParseContent(&plain, "# No frontmatter\n")
+	if plain.BodyOffset != 0 {
+		t.Errorf("BodyOffset = %d, want 0 without frontmatter", plain.BodyOffset)
+	}
+}
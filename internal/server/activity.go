@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+
+	gitpkg "github.com/esakat/markdown-kb/internal/git"
+	"github.com/esakat/markdown-kb/internal/parser"
+)
+
+// handleActivity lists recent commits that changed documents anywhere in
+// the repository, with the documents each one changed. It takes since,
+// until, author and path (a path prefix) filters and page/limit.
+func (s *Server) handleActivity(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.RootDir == "" {
+		writeError(w, http.StatusInternalServerError, "git integration requires root directory")
+		return
+	}
+
+	page := queryInt(r, "page", 1)
+	limit := queryInt(r, "limit", 20)
+	if limit > 100 {
+		limit = 100
+	}
+
+	q := r.URL.Query()
+	// One extra commit tells whether there is a next page.
+	commits, err := gitpkg.Activity(s.cfg.RootDir, gitpkg.ActivityOptions{
+		Rev:        s.commit,
+		Since:      q.Get("since"),
+		Until:      q.Get("until"),
+		Author:     q.Get("author"),
+		PathPrefix: q.Get("path"),
+		Extensions: parser.NormalizeExtensions(s.RepoConfig().Extensions),
+		Skip:       (page - 1) * limit,
+		Limit:      limit + 1,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get activity")
+		return
+	}
+
+	hasMore := len(commits) > limit
+	if hasMore {
+		commits = commits[:limit]
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data":     commits,
+		"page":     page,
+		"limit":    limit,
+		"has_more": hasMore,
+	})
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHandleActivity(t *testing.T) {
+	_, ts := newTestServerWithGitRepo(t)
+
+	type activity struct {
+		Data []struct {
+			Hash    string `json:"hash"`
+			Message string `json:"message"`
+			Files   []struct {
+				Status string `json:"status"`
+				Path   string `json:"path"`
+			} `json:"files"`
+		} `json:"data"`
+		HasMore bool `json:"has_more"`
+	}
+
+	var body activity
+	resp := getInto(t, ts.URL+"/api/v1/activity?limit=1", &body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if len(body.Data) != 1 || !body.HasMore || body.Data[0].Message != "update: expand guide.md" {
+		t.Fatalf("first page = %+v", body)
+	}
+	if f := body.Data[0].Files; len(f) != 1 || f[0].Status != "modified" || f[0].Path != "guide.md" {
+		t.Errorf("files = %+v", f)
+	}
+
+	body = activity{}
+	getInto(t, ts.URL+"/api/v1/activity?limit=1&page=2", &body)
+	if len(body.Data) != 1 || body.HasMore || body.Data[0].Files[0].Status != "added" {
+		t.Errorf("second page = %+v", body)
+	}
+
+	for _, query := range []string{"author=nobody", "path=docs/", "until=2000-01-01"} {
+		body = activity{}
+		getInto(t, ts.URL+"/api/v1/activity?"+query, &body)
+		if len(body.Data) != 0 {
+			t.Errorf("%s: got %d commits, want 0", query, len(body.Data))
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	gitpkg "github.com/esakat/markdown-kb/internal/git"
+	"github.com/esakat/markdown-kb/internal/parser"
+)
+
+// commitFileItem is a document changed by a commit, with its title from
+// the index when the document is still there.
+type commitFileItem struct {
+	gitpkg.CommitFile
+	Title string `json:"title,omitempty"`
+}
+
+// handleCommit describes one commit: its message, author, committer and
+// parents, and every document it changed with line counts and diffs.
+func (s *Server) handleCommit(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.RootDir == "" {
+		writeError(w, http.StatusInternalServerError, "git integration requires root directory")
+		return
+	}
+
+	detail, err := gitpkg.ShowCommit(s.cfg.RootDir, r.PathValue("hash"), parser.NormalizeExtensions(s.RepoConfig().Extensions))
+	if errors.Is(err, gitpkg.ErrUnknownRevision) {
+		writeError(w, http.StatusNotFound, "commit not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get commit")
+		return
+	}
+
+	files := make([]commitFileItem, 0, len(detail.Files))
+	for _, f := range detail.Files {
+		item := commitFileItem{CommitFile: f}
+		if doc, err := s.store.GetDocument(f.Path); err == nil && doc != nil {
+			item.Title = doc.Title
+		}
+		files = append(files, item)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data": struct {
+			*gitpkg.CommitDetail
+			Files []commitFileItem `json:"files"`
+		}{detail, files},
+	})
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHandleCommit(t *testing.T) {
+	dir := newRevisionTestRepo(t)
+	ts := newRevisionTestServer(t, dir, "")
+
+	var body struct {
+		Data struct {
+			Hash    string   `json:"hash"`
+			Message string   `json:"message"`
+			Parents []string `json:"parents"`
+			Files   []struct {
+				Path      string `json:"path"`
+				Status    string `json:"status"`
+				Title     string `json:"title"`
+				Additions int    `json:"additions"`
+				Deletions int    `json:"deletions"`
+				Hunks     []any  `json:"hunks"`
+			} `json:"files"`
+		} `json:"data"`
+	}
+	resp := getInto(t, ts.URL+"/api/v1/git/commits/main", &body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if body.Data.Message != "v2" || len(body.Data.Hash) != 40 || len(body.Data.Parents) != 1 {
+		t.Errorf("commit = %+v", body.Data)
+	}
+	// diagram.txt isn't a document.
+	files := body.Data.Files
+	if len(files) != 2 {
+		t.Fatalf("files = %+v, want guide.md and new.md", files)
+	}
+	if f := files[0]; f.Path != "guide.md" || f.Status != "modified" || f.Title != "Guide draft" || f.Additions != 2 || f.Deletions != 2 || len(f.Hunks) != 1 {
+		t.Errorf("guide.md = %+v", f)
+	}
+	if f := files[1]; f.Path != "new.md" || f.Status != "added" || f.Additions != 1 {
+		t.Errorf("new.md = %+v", f)
+	}
+
+	var errBody map[string]any
+	if resp := getInto(t, ts.URL+"/api/v1/git/commits/deadbeef", &errBody); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status for unknown commit = %d, want 404", resp.StatusCode)
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/esakat/markdown-kb/internal/index"
+	"github.com/esakat/markdown-kb/internal/parser"
+)
+
+// Fields selectable with ?fields= on each endpoint. "meta" selects the whole
+// frontmatter map; "meta.<key>" selects individual frontmatter keys.
+var (
+	documentFields = map[string]bool{
+		"path": true, "title": true, "meta": true, "mod_time": true, "size": true,
+		"word_count": true, "outline": true, "git": true,
+	}
+	searchFields = map[string]bool{
+		"path": true, "title": true, "snippet": true, "score": true, "meta": true,
+		"word_count": true, "outline": true, "git": true,
+	}
+	treeFields = map[string]bool{
+		"name": true, "type": true, "path": true, "title": true, "tags": true, "meta": true,
+		"git_status": true, "git": true,
+	}
+)
+
+// fieldSet is a parsed ?fields= projection. The document path is always
+// included so projected items can still be identified.
+type fieldSet struct {
+	top     map[string]bool
+	metaAll bool
+	meta    []string
+}
+
+// parseFields parses the fields query parameter against the allowed set.
+// It returns nil when no projection was requested.
+func parseFields(r *http.Request, allowed map[string]bool) (*fieldSet, error) {
+	raw := r.URL.Query().Get("fields")
+	if raw == "" {
+		return nil, nil
+	}
+
+	fs := &fieldSet{top: make(map[string]bool)}
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if key, ok := strings.CutPrefix(f, "meta."); ok && allowed["meta"] {
+			if key == "" {
+				return nil, fmt.Errorf("invalid field %q", f)
+			}
+			fs.meta = append(fs.meta, key)
+			continue
+		}
+		if !allowed[f] {
+			return nil, fmt.Errorf("unknown field %q", f)
+		}
+		if f == "meta" {
+			fs.metaAll = true
+			continue
+		}
+		fs.top[f] = true
+	}
+	return fs, nil
+}
+
+// needsBody reports whether the projection includes fields computed from
+// the document body.
+func (fs *fieldSet) needsBody() bool {
+	return fs.top["word_count"] || fs.top["outline"]
+}
+
+// project returns the selected fields of v, a JSON-serializable value,
+// with meta narrowed to the selected frontmatter keys.
+func (fs *fieldSet) project(v any, meta map[string]any) map[string]any {
+	var full map[string]any
+	data, _ := json.Marshal(v)
+	json.Unmarshal(data, &full)
+
+	out := make(map[string]any)
+	if p, ok := full["path"]; ok {
+		out["path"] = p
+	}
+	for k := range fs.top {
+		if val, ok := full[k]; ok {
+			out[k] = val
+		}
+	}
+
+	switch {
+	case fs.metaAll:
+		out["meta"] = meta
+	case len(fs.meta) > 0:
+		selected := make(map[string]any)
+		for _, k := range fs.meta {
+			if val, ok := meta[k]; ok {
+				selected[k] = val
+			}
+		}
+		out["meta"] = selected
+	}
+	return out
+}
+
+// addBodyFields fills word_count and outline for a projected document.
+func addBodyFields(store *index.Store, item map[string]any, fs *fieldSet, path string) {
+	if !fs.needsBody() {
+		return
+	}
+	doc, err := store.GetDocument(path)
+	if err != nil || doc == nil {
+		return
+	}
+	if fs.top["word_count"] {
+		item["word_count"] = parser.WordCount(doc.Body)
+	}
+	if fs.top["outline"] {
+		outline := parser.ExtractOutline(doc.Body)
+		if outline == nil {
+			outline = []parser.Heading{}
+		}
+		item["outline"] = outline
+	}
+}
+
+// projectTree applies a projection to the file nodes of a tree. All nodes
+// keep their name and type, and directories keep their children.
+func projectTree(node *index.TreeNode, fs *fieldSet, metas map[string]map[string]any) map[string]any {
+	if node.Type == "file" {
+		leaf := *node
+		leaf.Children = nil
+		out := fs.project(leaf, metas[node.Path])
+		// Keep name and type so clients can still render the tree.
+		out["name"] = node.Name
+		out["type"] = node.Type
+		return out
+	}
+
+	children := make([]map[string]any, 0, len(node.Children))
+	for _, child := range node.Children {
+		children = append(children, projectTree(child, fs, metas))
+	}
+	return map[string]any{
+		"name":     node.Name,
+		"type":     node.Type,
+		"children": children,
+	}
+}
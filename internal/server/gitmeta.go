@@ -0,0 +1,106 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	gitpkg "github.com/esakat/markdown-kb/internal/git"
+	"github.com/esakat/markdown-kb/internal/index"
+)
+
+// SetGitMeta makes the repository's git history available to every
+// endpoint: document dates, authors, commit counts and renames, and
+// sorting and filtering listings by them. Until it is called, dates are
+// looked up with git log per request.
+func (s *Server) SetGitMeta(c *gitpkg.MetaCache) {
+	s.gitMeta.Store(c)
+}
+
+// gitMetaFor returns the history of the document at path in st, or nil if
+// there is none. It describes the working tree, so revisions go without.
+func (s *Server) gitMetaFor(st revStore, path string) *gitpkg.FileMeta {
+	c := s.gitMeta.Load()
+	if c == nil || st.commit != "" {
+		return nil
+	}
+	m, ok := c.Get(path)
+	if !ok {
+		return nil
+	}
+	return &m
+}
+
+// documentItem is a listed document with its git history.
+type documentItem struct {
+	index.DocumentSummary
+	Git *gitpkg.FileMeta `json:"git,omitempty"`
+}
+
+// searchItem is a search hit with the document's git history.
+type searchItem struct {
+	index.SearchResult
+	Git *gitpkg.FileMeta `json:"git,omitempty"`
+}
+
+// gitSortKeys are the values of ?sort= on the document listing; a leading
+// "-" sorts in descending order.
+var gitSortKeys = map[string]func(m *gitpkg.FileMeta) time.Time{
+	"git_created": func(m *gitpkg.FileMeta) time.Time { return m.Created },
+	"git_updated": func(m *gitpkg.FileMeta) time.Time { return m.Updated },
+}
+
+// gitQuery is the part of a listing request that needs git history: the
+// ?sort= order and the ?author= filter.
+type gitQuery struct {
+	key    func(m *gitpkg.FileMeta) time.Time
+	desc   bool
+	author string
+}
+
+// parseGitQuery parses ?sort= and ?author=. It returns nil when neither is
+// set.
+func parseGitQuery(r *http.Request) (*gitQuery, error) {
+	q := &gitQuery{author: r.URL.Query().Get("author")}
+	if raw := r.URL.Query().Get("sort"); raw != "" {
+		name, desc := strings.CutPrefix(raw, "-")
+		key, ok := gitSortKeys[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort %q: want git_created or git_updated, optionally prefixed with -", raw)
+		}
+		q.key, q.desc = key, desc
+	}
+	if q.key == nil && q.author == "" {
+		return nil, nil
+	}
+	return q, nil
+}
+
+// apply filters and orders items. Documents without history don't match
+// an author filter and sort last.
+func (q *gitQuery) apply(items []documentItem) []documentItem {
+	if q.author != "" {
+		kept := items[:0]
+		for _, item := range items {
+			if item.Git != nil && strings.Contains(strings.ToLower(item.Git.Author), strings.ToLower(q.author)) {
+				kept = append(kept, item)
+			}
+		}
+		items = kept
+	}
+	if q.key != nil {
+		sort.SliceStable(items, func(i, j int) bool {
+			a, b := items[i].Git, items[j].Git
+			if a == nil || b == nil {
+				return a != nil
+			}
+			if q.desc {
+				return q.key(a).After(q.key(b))
+			}
+			return q.key(a).Before(q.key(b))
+		})
+	}
+	return items
+}
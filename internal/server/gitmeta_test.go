@@ -0,0 +1,98 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gitpkg "github.com/esakat/markdown-kb/internal/git"
+	"github.com/esakat/markdown-kb/internal/scanner"
+)
+
+func TestGitMeta(t *testing.T) {
+	srv, ts := newTestServerWithGitRepo(t)
+
+	// Sorting by git history needs the cache.
+	var errBody map[string]any
+	if resp := getInto(t, ts.URL+"/api/v1/documents?sort=git_updated", &errBody); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status without cache = %d, want 400", resp.StatusCode)
+	}
+
+	// An uncommitted document has no history.
+	os.WriteFile(filepath.Join(srv.cfg.RootDir, "draft.md"), []byte("# Draft\n"), 0o644)
+	doc, err := scanner.ReadDocument(srv.cfg.RootDir, "draft.md")
+	if err != nil {
+		t.Fatalf("ReadDocument() error = %v", err)
+	}
+	srv.store.IndexDocument(doc)
+
+	cache := gitpkg.NewMetaCache(srv.cfg.RootDir)
+	if err := cache.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	srv.SetGitMeta(cache)
+
+	var got struct {
+		Data struct {
+			Path string `json:"path"`
+		} `json:"data"`
+		Git *gitpkg.FileMeta `json:"git"`
+	}
+	getInto(t, ts.URL+"/api/v1/documents/guide.md", &got)
+	if got.Git == nil || got.Git.Commits != 2 || got.Git.Author != "Test" {
+		t.Errorf("git = %+v, want 2 commits by Test", got.Git)
+	}
+
+	type listing struct {
+		Data []struct {
+			Path string           `json:"path"`
+			Git  *gitpkg.FileMeta `json:"git"`
+		} `json:"data"`
+		Total int `json:"total"`
+	}
+	var list listing
+	getInto(t, ts.URL+"/api/v1/documents?sort=-git_updated", &list)
+	if len(list.Data) != 2 || list.Data[0].Path != "guide.md" || list.Data[0].Git == nil || list.Data[1].Git != nil {
+		t.Errorf("sorted listing = %+v, want guide.md with history first", list.Data)
+	}
+
+	list = listing{}
+	getInto(t, ts.URL+"/api/v1/documents?author=test&limit=1", &list)
+	if list.Total != 1 || len(list.Data) != 1 || list.Data[0].Path != "guide.md" {
+		t.Errorf("author listing = %+v", list)
+	}
+
+	if resp := getInto(t, ts.URL+"/api/v1/documents?sort=title", &errBody); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status for unknown sort = %d, want 400", resp.StatusCode)
+	}
+	// History describes the working tree, so it can't order a revision.
+	for _, query := range []string{"sort=git_updated&rev=HEAD", "author=test&rev=HEAD"} {
+		if resp := getInto(t, ts.URL+"/api/v1/documents?"+query, &errBody); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, resp.StatusCode)
+		}
+	}
+
+	list = listing{}
+	getInto(t, ts.URL+"/api/v1/search?q=programming", &list)
+	if len(list.Data) != 1 || list.Data[0].Git == nil || list.Data[0].Git.Commits != 2 {
+		t.Errorf("search = %+v, want guide.md with history", list.Data)
+	}
+
+	var tree struct {
+		Data struct {
+			Children []struct {
+				Path string           `json:"path"`
+				Git  *gitpkg.FileMeta `json:"git"`
+			} `json:"children"`
+		} `json:"data"`
+	}
+	getInto(t, ts.URL+"/api/v1/tree?fields=path,git", &tree)
+	nodes := map[string]*gitpkg.FileMeta{}
+	for _, c := range tree.Data.Children {
+		nodes[c.Path] = c.Git
+	}
+	if nodes["guide.md"] == nil || nodes["guide.md"].Commits != 2 || nodes["draft.md"] != nil {
+		t.Errorf("tree = %+v, want history for guide.md only", tree.Data.Children)
+	}
+}
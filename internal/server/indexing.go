@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IndexProgress describes a running initial index.
+type IndexProgress struct {
+	Phase string `json:"phase"` // "scanning" or "indexing"
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// progressBroadcastInterval throttles index_progress WebSocket events.
+const progressBroadcastInterval = 250 * time.Millisecond
+
+// indexState tracks whether the initial index is still being built.
+type indexState struct {
+	mu            sync.RWMutex
+	progress      *IndexProgress // nil once indexing is complete
+	lastBroadcast time.Time
+}
+
+// SetIndexProgress marks the index as incomplete and records progress.
+// Progress is broadcast to WebSocket clients as "index_progress" events,
+// at most every progressBroadcastInterval and on every phase change.
+func (s *Server) SetIndexProgress(phase string, done, total int) {
+	p := IndexProgress{Phase: phase, Done: done, Total: total}
+
+	s.index.mu.Lock()
+	changed := s.index.progress == nil || s.index.progress.Phase != phase
+	broadcast := changed || time.Since(s.index.lastBroadcast) >= progressBroadcastInterval
+	s.index.progress = &p
+	if broadcast {
+		s.index.lastBroadcast = time.Now()
+	}
+	s.index.mu.Unlock()
+
+	if broadcast {
+		s.Broadcast(WSEvent{Type: "index_progress", Progress: &p})
+	}
+}
+
+// SetIndexComplete marks the initial index as complete and notifies
+// WebSocket clients with an "index_complete" event.
+func (s *Server) SetIndexComplete() {
+	s.index.mu.Lock()
+	wasIndexing := s.index.progress != nil
+	s.index.progress = nil
+	s.index.mu.Unlock()
+
+	if wasIndexing {
+		s.Broadcast(WSEvent{Type: "index_complete"})
+	}
+}
+
+// indexProgress returns the current progress, or nil when the index is
+// complete.
+func (s *Server) indexProgress() *IndexProgress {
+	s.index.mu.RLock()
+	defer s.index.mu.RUnlock()
+	if s.index.progress == nil {
+		return nil
+	}
+	p := *s.index.progress
+	return &p
+}
+
+// withIndexStatus flags resp as partial while the initial index is being
+// built.
+func (s *Server) withIndexStatus(resp map[string]any) map[string]any {
+	if s.indexProgress() != nil {
+		resp["index_incomplete"] = true
+	}
+	return resp
+}
+
+// indexStatusMiddleware sets X-Index-Incomplete on API responses while the
+// initial index is being built.
+func (s *Server) indexStatusMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") && s.indexProgress() != nil {
+			w.Header().Set("X-Index-Incomplete", "true")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
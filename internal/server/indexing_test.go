@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+func TestHandleHealth_Indexing(t *testing.T) {
+	srv, ts := newTestServer(t)
+	srv.SetIndexProgress("indexing", 2, 3)
+
+	var body struct {
+		Status   string        `json:"status"`
+		Indexing IndexProgress `json:"indexing"`
+	}
+	resp, err := http.Get(ts.URL + "/api/health")
+	if err != nil {
+		t.Fatalf("GET /api/health error = %v", err)
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+
+	if body.Status != "indexing" {
+		t.Errorf("status = %q, want %q", body.Status, "indexing")
+	}
+	want := IndexProgress{Phase: "indexing", Done: 2, Total: 3}
+	if body.Indexing != want {
+		t.Errorf("indexing = %+v, want %+v", body.Indexing, want)
+	}
+
+	srv.SetIndexComplete()
+	body.Status = ""
+	resp, err = http.Get(ts.URL + "/api/health")
+	if err != nil {
+		t.Fatalf("GET /api/health error = %v", err)
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if body.Status != "ok" {
+		t.Errorf("status after completion = %q, want %q", body.Status, "ok")
+	}
+}
+
+func TestIndexIncompleteFlag(t *testing.T) {
+	srv, ts := newTestServer(t)
+	srv.SetIndexProgress("scanning", 10, 100)
+
+	for _, path := range []string{"/api/v1/documents", "/api/v1/search?q=Go", "/api/v1/tags", "/api/v1/tree", "/api/v1/graph"} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", path, err)
+		}
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+
+		if resp.Header.Get("X-Index-Incomplete") != "true" {
+			t.Errorf("%s: missing X-Index-Incomplete header", path)
+		}
+		if body["index_incomplete"] != true {
+			t.Errorf("%s: index_incomplete = %v, want true", path, body["index_incomplete"])
+		}
+		if body["data"] == nil {
+			t.Errorf("%s: expected partial data", path)
+		}
+	}
+
+	srv.SetIndexComplete()
+	resp, err := http.Get(ts.URL + "/api/v1/documents")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	var body map[string]any
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if _, ok := body["index_incomplete"]; ok || resp.Header.Get("X-Index-Incomplete") != "" {
+		t.Error("expected no incomplete flag after indexing completes")
+	}
+}
+
+func TestIndexProgress_Broadcast(t *testing.T) {
+	srv, ts := newTestServer(t)
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/v1/ws"
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, _, err := websocket.Dial(ctx, wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close(websocket.StatusNormalClosure, "")
+	time.Sleep(100 * time.Millisecond)
+
+	srv.SetIndexProgress("scanning", 0, 10)
+	srv.SetIndexProgress("scanning", 1, 10) // throttled
+	srv.SetIndexProgress("indexing", 5, 10) // phase change
+	srv.SetIndexComplete()
+
+	var got []WSEvent
+	for i := 0; i < 3; i++ {
+		_, data, err := c.Read(ctx)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var ev WSEvent
+		json.Unmarshal(data, &ev)
+		got = append(got, ev)
+	}
+
+	if got[0].Type != "index_progress" || got[0].Progress == nil || got[0].Progress.Phase != "scanning" {
+		t.Errorf("event 0 = %+v", got[0])
+	}
+	if got[1].Type != "index_progress" || got[1].Progress == nil || got[1].Progress.Done != 5 {
+		t.Errorf("event 1 = %+v", got[1])
+	}
+	if got[2].Type != "index_complete" {
+		t.Errorf("event 2 = %+v", got[2])
+	}
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/esakat/markdown-kb/internal/config"
+	"github.com/esakat/markdown-kb/internal/index"
+	"github.com/esakat/markdown-kb/internal/revision"
+)
+
+// Repo is one repository served by a multi-repository server.
+type Repo struct {
+	Name    string
+	RootDir string
+	Config  config.RepoConfig
+	Store   *index.Store
+	// Commit is the commit Store was built from (kb serve --rev); empty
+	// when it reflects the working tree.
+	Commit string
+	// Revisions answers requests with ?rev=; nil disables them.
+	Revisions *revision.Cache
+}
+
+// NewMulti creates a server for several repositories; repos must not be
+// empty. Each repository's API is served under /api/v1/repos/{name}/. The
+// first repository also backs the unprefixed /api/v1 routes, the web UI and
+// llms.txt, so single-repository clients keep working. cfg supplies the
+// port; its RootDir and Repo are taken from the first repository.
+func NewMulti(cfg config.ServeConfig, repos []Repo) *Server {
+	first := repos[0]
+	cfg.RootDir = first.RootDir
+	cfg.Repo = first.Config
+	s := New(cfg, first.Store)
+	s.name = first.Name
+	s.commit = first.Commit
+	s.revisions = first.Revisions
+
+	for _, r := range repos[1:] {
+		child := &Server{
+			cfg:       config.ServeConfig{RootDir: r.RootDir, Repo: r.Config},
+			store:     r.Store,
+			hub:       s.hub,
+			mux:       http.NewServeMux(),
+			name:      r.Name,
+			commit:    r.Commit,
+			revisions: r.Revisions,
+		}
+		child.registerAPIRoutes()
+		s.repos = append(s.repos, child)
+	}
+	for _, r := range s.repos {
+		r.labelEvents = len(s.repos) > 1
+	}
+	return s
+}
+
+// Repo returns the server for the named repository, or nil if there is
+// none. Index progress and change events for that repository go through it.
+func (s *Server) Repo(name string) *Server {
+	for _, r := range s.repos {
+		if r.name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Broadcast sends event to all WebSocket clients. When several
+// repositories are served, the event is labelled with this repository.
+func (s *Server) Broadcast(event WSEvent) {
+	if s.labelEvents {
+		event.Repo = s.name
+	}
+	s.hub.Broadcast(event)
+}
+
+// handleRepoAPI serves /api/v1/repos/{repo}/{rest...} from the named
+// repository's /api/v1/{rest...} routes.
+func (s *Server) handleRepoAPI(w http.ResponseWriter, r *http.Request) {
+	repo := s.Repo(r.PathValue("repo"))
+	if repo == nil {
+		writeError(w, http.StatusNotFound, "repository not found")
+		return
+	}
+
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = "/api/v1/" + r.PathValue("rest")
+	r2.URL.RawPath = ""
+
+	// The outer middleware reported the default repository's status.
+	w.Header().Del("X-Index-Incomplete")
+	repo.indexStatusMiddleware(repo.mux).ServeHTTP(w, r2)
+}
+
+func (s *Server) handleListRepos(w http.ResponseWriter, r *http.Request) {
+	repos := make([]map[string]any, 0, len(s.repos))
+	for _, repo := range s.repos {
+		_, total, _ := repo.store.ListDocuments(0, 0)
+		repoCfg := repo.RepoConfig()
+		item := map[string]any{
+			"name":      repo.name,
+			"title":     repoCfg.Title,
+			"theme":     repoCfg.Theme,
+			"documents": total,
+		}
+		if p := repo.indexProgress(); p != nil {
+			item["indexing"] = p
+		}
+		repos = append(repos, item)
+	}
+	s.writeReposJSON(w, map[string]any{"data": repos})
+}
+
+// repoSearchResult is a search hit labelled with its repository.
+type repoSearchResult struct {
+	Repo string `json:"repo"`
+	index.SearchResult
+}
+
+// handleRepoSearch searches several repositories and merges the hits by
+// score. BM25 scores from separate indexes aren't comparable, so each hit
+// is scored relative to the best hit in its repository, from 1 down to 0.
+// The optional "repos" parameter is a comma-separated list of repository
+// names; all repositories are searched by default.
+func (s *Server) handleRepoSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		writeError(w, http.StatusBadRequest, "query parameter 'q' is required")
+		return
+	}
+
+	page := queryInt(r, "page", 1)
+	limit := queryInt(r, "limit", 20)
+	if limit > 100 {
+		limit = 100
+	}
+	offset := (page - 1) * limit
+
+	repos := s.repos
+	if names := r.URL.Query().Get("repos"); names != "" {
+		repos = nil
+		for _, name := range strings.Split(names, ",") {
+			repo := s.Repo(strings.TrimSpace(name))
+			if repo == nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown repository %q", name))
+				return
+			}
+			repos = append(repos, repo)
+		}
+	}
+
+	filters := make(map[string]string)
+	if status := r.URL.Query().Get("status"); status != "" {
+		filters["status"] = status
+	}
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		filters["tags"] = tag
+	}
+
+	// Each repository contributes its best offset+limit hits; the merged
+	// page is cut from their union.
+	var merged []repoSearchResult
+	total := 0
+	for _, repo := range repos {
+		var results []index.SearchResult
+		var n int
+		var err error
+		if len(filters) > 0 {
+			results, n, err = repo.store.SearchWithFilter(q, filters, offset+limit, 0)
+		} else {
+			results, n, err = repo.store.Search(q, offset+limit, 0)
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "search failed")
+			return
+		}
+		total += n
+		for _, res := range results {
+			// BM25 scores are negative; the first hit is the best match.
+			if best := results[0].Score; best != 0 {
+				res.Score /= best
+			} else {
+				res.Score = 1
+			}
+			merged = append(merged, repoSearchResult{Repo: repo.name, SearchResult: res})
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Score > merged[j].Score
+	})
+	if offset > len(merged) {
+		offset = len(merged)
+	}
+	end := offset + limit
+	if end > len(merged) {
+		end = len(merged)
+	}
+	data := merged[offset:end]
+	if data == nil {
+		data = []repoSearchResult{}
+	}
+
+	s.writeReposJSON(w, map[string]any{
+		"data":  data,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// writeReposJSON writes a cross-repository response, flagging it as
+// partial while any repository is still being indexed.
+func (s *Server) writeReposJSON(w http.ResponseWriter, resp map[string]any) {
+	w.Header().Del("X-Index-Incomplete")
+	for _, repo := range s.repos {
+		if repo.indexProgress() != nil {
+			w.Header().Set("X-Index-Incomplete", "true")
+			resp["index_incomplete"] = true
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
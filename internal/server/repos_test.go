@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/esakat/markdown-kb/internal/config"
+	"github.com/esakat/markdown-kb/internal/index"
+	"github.com/esakat/markdown-kb/internal/scanner"
+	"nhooyr.io/websocket"
+)
+
+func newTestStore(t *testing.T, docs ...scanner.Document) *index.Store {
+	t.Helper()
+	store, err := index.New()
+	if err != nil {
+		t.Fatalf("index.New() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	for _, doc := range docs {
+		if err := store.IndexDocument(doc); err != nil {
+			t.Fatalf("IndexDocument(%q) error = %v", doc.RelPath, err)
+		}
+	}
+	return store
+}
+
+func newMultiTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	now := time.Now()
+	handbook := newTestStore(t,
+		scanner.Document{RelPath: "deploy.md", Frontmatter: map[string]any{"title": "Deploy"}, Body: "Deploy with kubectl.", ModTime: now},
+		scanner.Document{RelPath: "oncall.md", Frontmatter: map[string]any{"title": "On-call"}, Body: "Page the on-call engineer.", ModTime: now},
+	)
+	notes := newTestStore(t,
+		scanner.Document{RelPath: "kubectl.md", Frontmatter: map[string]any{"title": "kubectl tips"}, Body: "kubectl get pods", ModTime: now},
+	)
+
+	srv := NewMulti(config.ServeConfig{}, []Repo{
+		{Name: "handbook", Config: config.RepoConfig{Title: "Handbook", Theme: "nord"}, Store: handbook},
+		{Name: "notes", Config: config.RepoConfig{Title: "Notes", Theme: "dracula"}, Store: notes},
+	})
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return srv, ts
+}
+
+func getInto(t *testing.T, url string, v any) *http.Response {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s error = %v", url, err)
+	}
+	defer resp.Body.Close()
+	json.NewDecoder(resp.Body).Decode(v)
+	return resp
+}
+
+func TestHandleListRepos(t *testing.T) {
+	srv, ts := newMultiTestServer(t)
+	srv.Repo("notes").SetIndexProgress("scanning", 1, 5)
+
+	var body struct {
+		Data []struct {
+			Name      string         `json:"name"`
+			Title     string         `json:"title"`
+			Theme     string         `json:"theme"`
+			Documents int            `json:"documents"`
+			Indexing  *IndexProgress `json:"indexing"`
+		} `json:"data"`
+		IndexIncomplete bool `json:"index_incomplete"`
+	}
+	getInto(t, ts.URL+"/api/v1/repos", &body)
+
+	if len(body.Data) != 2 {
+		t.Fatalf("got %d repos, want 2", len(body.Data))
+	}
+	if r := body.Data[0]; r.Name != "handbook" || r.Title != "Handbook" || r.Theme != "nord" || r.Documents != 2 || r.Indexing != nil {
+		t.Errorf("repo 0 = %+v", r)
+	}
+	if r := body.Data[1]; r.Name != "notes" || r.Documents != 1 || r.Indexing == nil {
+		t.Errorf("repo 1 = %+v", r)
+	}
+	if !body.IndexIncomplete {
+		t.Error("expected index_incomplete while a repository is indexing")
+	}
+}
+
+func TestHandleRepoAPI(t *testing.T) {
+	_, ts := newMultiTestServer(t)
+
+	tests := []struct {
+		path  string
+		total int
+	}{
+		{"/api/v1/documents", 2}, // default repository
+		{"/api/v1/repos/handbook/documents", 2},
+		{"/api/v1/repos/notes/documents", 1},
+	}
+	for _, tt := range tests {
+		var body struct {
+			Total int `json:"total"`
+		}
+		resp := getInto(t, ts.URL+tt.path, &body)
+		if resp.StatusCode != http.StatusOK || body.Total != tt.total {
+			t.Errorf("%s: status %d, total %d, want total %d", tt.path, resp.StatusCode, body.Total, tt.total)
+		}
+	}
+
+	var config map[string]any
+	getInto(t, ts.URL+"/api/v1/repos/notes/config", &config)
+	if config["title"] != "Notes" || config["theme"] != "dracula" {
+		t.Errorf("notes config = %v", config)
+	}
+
+	var doc struct {
+		Data index.DocumentDetail `json:"data"`
+	}
+	resp := getInto(t, ts.URL+"/api/v1/repos/notes/documents/kubectl.md", &doc)
+	if resp.StatusCode != http.StatusOK || doc.Data.Title != "kubectl tips" {
+		t.Errorf("notes document: status %d, %+v", resp.StatusCode, doc.Data)
+	}
+
+	resp = getInto(t, ts.URL+"/api/v1/repos/missing/documents", &map[string]any{})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown repo status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestHandleRepoAPI_IndexStatusPerRepo(t *testing.T) {
+	srv, ts := newMultiTestServer(t)
+	srv.Repo("notes").SetIndexProgress("scanning", 0, 1)
+
+	resp := getInto(t, ts.URL+"/api/v1/repos/handbook/documents", &map[string]any{})
+	if resp.Header.Get("X-Index-Incomplete") != "" {
+		t.Error("handbook should not be flagged while notes is indexing")
+	}
+	resp = getInto(t, ts.URL+"/api/v1/repos/notes/documents", &map[string]any{})
+	if resp.Header.Get("X-Index-Incomplete") != "true" {
+		t.Error("notes should be flagged while indexing")
+	}
+}
+
+func TestHandleRepoSearch(t *testing.T) {
+	_, ts := newMultiTestServer(t)
+
+	var body struct {
+		Data []struct {
+			Repo  string  `json:"repo"`
+			Path  string  `json:"path"`
+			Score float64 `json:"score"`
+		} `json:"data"`
+		Total int `json:"total"`
+	}
+	getInto(t, ts.URL+"/api/v1/repos:search?q=kubectl", &body)
+	if body.Total != 2 || len(body.Data) != 2 {
+		t.Fatalf("got total %d, %d results, want 2", body.Total, len(body.Data))
+	}
+	got := map[string]string{}
+	for _, r := range body.Data {
+		got[r.Repo] = r.Path
+	}
+	if got["handbook"] != "deploy.md" || got["notes"] != "kubectl.md" {
+		t.Errorf("results = %+v", body.Data)
+	}
+	// Scores are relative to the best hit in each repository.
+	for _, r := range body.Data {
+		if r.Score != 1 {
+			t.Errorf("%s/%s: score = %v, want 1", r.Repo, r.Path, r.Score)
+		}
+	}
+
+	body.Data = nil
+	getInto(t, ts.URL+"/api/v1/repos:search?q=kubectl&repos=notes", &body)
+	if len(body.Data) != 1 || body.Data[0].Repo != "notes" {
+		t.Errorf("restricted results = %+v", body.Data)
+	}
+
+	body.Data = nil
+	getInto(t, ts.URL+"/api/v1/repos:search?q=kubectl&limit=1&page=2", &body)
+	if len(body.Data) != 1 {
+		t.Errorf("page 2 results = %+v", body.Data)
+	}
+
+	for _, path := range []string{"/api/v1/repos:search", "/api/v1/repos:search?q=x&repos=missing"} {
+		resp := getInto(t, ts.URL+path, &map[string]any{})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestBroadcast_LabelsRepo(t *testing.T) {
+	srv, ts := newMultiTestServer(t)
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/v1/ws"
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, _, err := websocket.Dial(ctx, wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close(websocket.StatusNormalClosure, "")
+	time.Sleep(100 * time.Millisecond)
+
+	srv.Repo("notes").Broadcast(WSEvent{Type: "updated", Path: "kubectl.md"})
+
+	_, data, err := c.Read(ctx)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var ev WSEvent
+	json.Unmarshal(data, &ev)
+	if ev.Repo != "notes" || ev.Path != "kubectl.md" {
+		t.Errorf("event = %+v", ev)
+	}
+}
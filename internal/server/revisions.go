@@ -0,0 +1,106 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	gitpkg "github.com/esakat/markdown-kb/internal/git"
+	"github.com/esakat/markdown-kb/internal/index"
+)
+
+// revStore is the index a request reads from.
+type revStore struct {
+	*index.Store
+	commit    string // commit the index was built from; empty for the working tree
+	requested bool   // selected with ?rev=, so it is fully indexed
+	release   func()
+
+	// read, if set, reads single documents from git objects in place of
+	// the index, which is then nil; see documentFor.
+	read func(path string) (*index.DocumentDetail, error)
+}
+
+// getDocument returns the document at path, or nil if there is none.
+func (st revStore) getDocument(path string) (*index.DocumentDetail, error) {
+	if st.read != nil {
+		return st.read(path)
+	}
+	return st.GetDocument(path)
+}
+
+// storeFor returns the index that answers r: the revision named by its
+// "rev" query parameter, or the server's own index. The caller must call
+// release when done. On failure the error response has been written and ok
+// is false.
+func (s *Server) storeFor(w http.ResponseWriter, r *http.Request) (st revStore, ok bool) {
+	rev := r.URL.Query().Get("rev")
+	if rev == "" {
+		return revStore{Store: s.store, commit: s.commit, release: func() {}}, true
+	}
+	if s.revisions == nil {
+		writeError(w, http.StatusBadRequest, "revisions are not available for this repository")
+		return revStore{}, false
+	}
+
+	store, commit, release, err := s.revisions.Acquire(rev)
+	if errors.Is(err, gitpkg.ErrUnknownRevision) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown revision %q", rev))
+		return revStore{}, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load revision")
+		return revStore{}, false
+	}
+	// A revision index is complete even while the working tree is indexed.
+	w.Header().Del("X-Index-Incomplete")
+	return revStore{Store: store, commit: commit, requested: true, release: release}, true
+}
+
+// documentFor is storeFor for handlers that only read single documents
+// with getDocument. A revision named by ?rev= is read straight from git
+// objects instead of being indexed as a whole.
+func (s *Server) documentFor(w http.ResponseWriter, r *http.Request) (st revStore, ok bool) {
+	rev := r.URL.Query().Get("rev")
+	if rev == "" || s.revisions == nil {
+		return s.storeFor(w, r)
+	}
+
+	commit, err := gitpkg.ResolveRevision(s.cfg.RootDir, rev)
+	if errors.Is(err, gitpkg.ErrUnknownRevision) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown revision %q", rev))
+		return revStore{}, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load revision")
+		return revStore{}, false
+	}
+	w.Header().Del("X-Index-Incomplete")
+	read := func(path string) (*index.DocumentDetail, error) {
+		return s.revisions.ReadDocument(commit, path)
+	}
+	return revStore{commit: commit, requested: true, release: func() {}, read: read}, true
+}
+
+// pathAtRevision returns the path that the file requested at path had in
+// st's revision, following renames, so that links keep working across
+// them. It returns path itself when no rename applies or git fails.
+func (s *Server) pathAtRevision(st revStore, path string) string {
+	moved, err := gitpkg.PathAt(s.cfg.RootDir, path, st.commit)
+	if err != nil {
+		return path
+	}
+	return moved
+}
+
+// withStoreStatus labels resp with the commit it was read from and, for the
+// server's own index, whether indexing is still in progress.
+func (s *Server) withStoreStatus(st revStore, resp map[string]any) map[string]any {
+	if st.commit != "" {
+		resp["rev"] = st.commit
+	}
+	if st.requested {
+		return resp
+	}
+	return s.withIndexStatus(resp)
+}
@@ -0,0 +1,297 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/esakat/markdown-kb/internal/config"
+	gitpkg "github.com/esakat/markdown-kb/internal/git"
+	"github.com/esakat/markdown-kb/internal/revision"
+	"github.com/esakat/markdown-kb/internal/scanner"
+)
+
+// newRevisionTestRepo creates a repository with a v1 tag, a later commit
+// adding new.md, and an uncommitted edit to guide.md.
+func newRevisionTestRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test",
+			"GIT_AUTHOR_EMAIL=test@test.com",
+			"GIT_COMMITTER_NAME=Test",
+			"GIT_COMMITTER_EMAIL=test@test.com",
+		)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+
+	run("init", "-b", "main")
+	os.WriteFile(filepath.Join(dir, "guide.md"), []byte("---\ntitle: Guide v1\n---\n\nFirst edition.\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "diagram.txt"), []byte("diagram v1"), 0o644)
+	run("add", ".")
+	run("commit", "-m", "v1")
+	run("tag", "v1")
+
+	os.WriteFile(filepath.Join(dir, "guide.md"), []byte("---\ntitle: Guide v2\n---\n\nSecond edition.\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "diagram.txt"), []byte("diagram v2"), 0o644)
+	os.WriteFile(filepath.Join(dir, "new.md"), []byte("# New\n"), 0o644)
+	run("add", ".")
+	run("commit", "-m", "v2")
+
+	os.WriteFile(filepath.Join(dir, "guide.md"), []byte("---\ntitle: Guide draft\n---\n\nDraft edition.\n"), 0o644)
+	return dir
+}
+
+func newRevisionTestServer(t *testing.T, dir, commit string) *httptest.Server {
+	t.Helper()
+	store := newTestStore(t)
+	if commit == "" {
+		docs, err := scanner.Scan(dir)
+		if err != nil {
+			t.Fatalf("scanner.Scan() error = %v", err)
+		}
+		store.IndexBatch(docs, nil)
+	} else if err := revision.Load(store, dir, config.RepoConfig{}, commit, nil); err != nil {
+		t.Fatalf("revision.Load() error = %v", err)
+	}
+
+	revisions := revision.NewCache(dir, config.RepoConfig{}, 0)
+	t.Cleanup(revisions.Close)
+	srv := NewMulti(config.ServeConfig{}, []Repo{{
+		Name:      "docs",
+		RootDir:   dir,
+		Store:     store,
+		Commit:    commit,
+		Revisions: revisions,
+	}})
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestRevisionQuery(t *testing.T) {
+	dir := newRevisionTestRepo(t)
+	ts := newRevisionTestServer(t, dir, "")
+	v1, _ := gitpkg.ResolveRevision(dir, "v1")
+
+	var doc struct {
+		Data struct {
+			Title string `json:"title"`
+			Body  string `json:"body"`
+		} `json:"data"`
+		Rev string `json:"rev"`
+	}
+	getInto(t, ts.URL+"/api/v1/documents/guide.md", &doc)
+	if doc.Data.Title != "Guide draft" || doc.Rev != "" {
+		t.Errorf("working tree document = %+v", doc)
+	}
+	getInto(t, ts.URL+"/api/v1/documents/guide.md?rev=v1", &doc)
+	if doc.Data.Title != "Guide v1" || doc.Rev != v1 {
+		t.Errorf("v1 document = %+v, want rev %s", doc, v1)
+	}
+	resp := getInto(t, ts.URL+"/api/v1/documents/new.md?rev=v1", &map[string]any{})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("new.md at v1 status = %d, want 404", resp.StatusCode)
+	}
+
+	batchResp, err := http.Post(ts.URL+"/api/v1/documents:batchGet?rev=v1", "application/json", strings.NewReader(`{"paths":["guide.md","new.md"]}`))
+	if err != nil {
+		t.Fatalf("POST batchGet error = %v", err)
+	}
+	var batch struct {
+		Data []struct {
+			Title string `json:"title"`
+		} `json:"data"`
+		Found int    `json:"found"`
+		Rev   string `json:"rev"`
+	}
+	json.NewDecoder(batchResp.Body).Decode(&batch)
+	batchResp.Body.Close()
+	if batch.Found != 1 || batch.Data[0].Title != "Guide v1" || batch.Rev != v1 {
+		t.Errorf("batchGet at v1 = %+v", batch)
+	}
+
+	var search struct {
+		Data []struct {
+			Path string `json:"path"`
+		} `json:"data"`
+		Rev string `json:"rev"`
+	}
+	getInto(t, ts.URL+"/api/v1/search?q=Second&rev=main", &search)
+	if len(search.Data) != 1 || search.Data[0].Path != "guide.md" || search.Rev == "" {
+		t.Errorf("search at main = %+v", search)
+	}
+	search.Data = nil
+	getInto(t, ts.URL+"/api/v1/search?q=Second&rev=v1", &search)
+	if len(search.Data) != 0 {
+		t.Errorf("search at v1 = %+v, want no hits", search)
+	}
+
+	var tree struct {
+		Data struct {
+			Children []struct {
+				Path string `json:"path"`
+			} `json:"children"`
+		} `json:"data"`
+	}
+	getInto(t, ts.URL+"/api/v1/tree?rev=v1", &tree)
+	if len(tree.Data.Children) != 1 || tree.Data.Children[0].Path != "guide.md" {
+		t.Errorf("tree at v1 = %+v", tree.Data)
+	}
+
+	resp, err = http.Get(ts.URL + "/api/v1/raw/diagram.txt?rev=v1")
+	if err != nil {
+		t.Fatalf("GET raw error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "diagram v1" {
+		t.Errorf("raw at v1 = %q", body)
+	}
+}
+
+func TestRevisionQuery_Errors(t *testing.T) {
+	dir := newRevisionTestRepo(t)
+	ts := newRevisionTestServer(t, dir, "")
+
+	for _, path := range []string{"/api/v1/documents/guide.md?rev=nope", "/api/v1/search?q=x&rev=--all", "/api/v1/raw/missing.txt?rev=v1"} {
+		resp := getInto(t, ts.URL+path, &map[string]any{})
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", path, resp.StatusCode)
+		}
+	}
+
+	_, plain := newTestServer(t)
+	resp := getInto(t, plain.URL+"/api/v1/documents/guide.md?rev=v1", &map[string]any{})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("without revisions: status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestPinnedRevision(t *testing.T) {
+	dir := newRevisionTestRepo(t)
+	v1, _ := gitpkg.ResolveRevision(dir, "v1")
+	ts := newRevisionTestServer(t, dir, v1)
+
+	var docs struct {
+		Total int    `json:"total"`
+		Rev   string `json:"rev"`
+	}
+	getInto(t, ts.URL+"/api/v1/documents", &docs)
+	if docs.Total != 1 || docs.Rev != v1 {
+		t.Errorf("pinned documents = %+v", docs)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/v1/raw/guide.md")
+	if err != nil {
+		t.Fatalf("GET raw error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "First edition.") {
+		t.Errorf("pinned raw = %q, want v1 content", body)
+	}
+}
+
+func TestRevisionQuery_FollowsRenames(t *testing.T) {
+	dir := newRevisionTestRepo(t)
+	for _, args := range [][]string{
+		{"commit", "-q", "-am", "draft"},
+		{"mv", "guide.md", "handbook.md"},
+		{"commit", "-q", "-m", "rename"},
+	} {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@test.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	ts := newRevisionTestServer(t, dir, "")
+
+	var doc struct {
+		Data struct {
+			Path  string `json:"path"`
+			Title string `json:"title"`
+		} `json:"data"`
+		PathAtRev string `json:"path_at_rev"`
+	}
+	// The current name reads the old version.
+	getInto(t, ts.URL+"/api/v1/documents/handbook.md?rev=v1", &doc)
+	if doc.Data.Title != "Guide v1" || doc.PathAtRev != "guide.md" {
+		t.Errorf("handbook.md at v1 = %+v", doc)
+	}
+	// An old link still resolves after the rename.
+	doc.PathAtRev = ""
+	getInto(t, ts.URL+"/api/v1/documents/guide.md?rev=main", &doc)
+	if doc.Data.Path != "handbook.md" || doc.Data.Title != "Guide draft" || doc.PathAtRev != "handbook.md" {
+		t.Errorf("guide.md at main = %+v", doc)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/v1/raw/handbook.md?rev=v1")
+	if err != nil {
+		t.Fatalf("GET raw error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "First edition.") {
+		t.Errorf("raw handbook.md at v1 = %q", body)
+	}
+}
+
+func TestRevisionQuery_Subdirectory(t *testing.T) {
+	repo := t.TempDir()
+	dir := filepath.Join(repo, "docs")
+	os.MkdirAll(dir, 0o755)
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@test.com"}, args...)...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	os.WriteFile(filepath.Join(dir, "a.md"), []byte("---\ntitle: A v1\n---\n\nFirst.\n"), 0o644)
+	git("add", ".")
+	git("commit", "-q", "-m", "v1")
+	git("tag", "v1")
+	os.WriteFile(filepath.Join(dir, "a.md"), []byte("---\ntitle: A draft\n---\n\nDraft.\n"), 0o644)
+	ts := newRevisionTestServer(t, dir, "")
+
+	resp, err := http.Get(ts.URL + "/api/v1/raw/a.md?rev=v1")
+	if err != nil {
+		t.Fatalf("GET raw error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "First.") {
+		t.Errorf("raw a.md at v1 = %d %q", resp.StatusCode, body)
+	}
+
+	var diff struct {
+		Data struct {
+			Frontmatter struct {
+				Changed []struct {
+					Old any `json:"old"`
+				} `json:"changed"`
+			} `json:"frontmatter"`
+		} `json:"data"`
+	}
+	getInto(t, ts.URL+"/api/v1/git/diff/a.md?against=index&format=json", &diff)
+	if c := diff.Data.Frontmatter.Changed; len(c) != 1 || c[0].Old != "A v1" {
+		t.Errorf("frontmatter changes against the index = %+v, want title from A v1", c)
+	}
+}
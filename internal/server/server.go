@@ -1,19 +1,27 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/esakat/markdown-kb/internal/config"
 	gitpkg "github.com/esakat/markdown-kb/internal/git"
 	"github.com/esakat/markdown-kb/internal/index"
+	"github.com/esakat/markdown-kb/internal/llms"
+	"github.com/esakat/markdown-kb/internal/parser"
+	"github.com/esakat/markdown-kb/internal/revision"
 	"github.com/esakat/markdown-kb/web"
 )
 
@@ -21,11 +29,22 @@ var version = "dev"
 
 // Server is the HTTP server that serves the web UI and API.
 type Server struct {
-	cfg    config.ServeConfig
+	cfg    config.ServeConfig // cfg.Repo is guarded by cfgMu; use RepoConfig
+	cfgMu  sync.RWMutex
 	store  *index.Store
 	hub    *Hub
 	mux    *http.ServeMux
 	server *http.Server
+	index  indexState
+
+	name        string    // repository name in /api/v1/repos/{name}/
+	repos       []*Server // all served repositories, this one first
+	labelEvents bool      // set WSEvent.Repo when serving several repositories
+
+	commit    string          // commit the index was built from (kb serve --rev)
+	revisions *revision.Cache // answers ?rev= requests; nil disables them
+
+	gitMeta atomic.Pointer[gitpkg.MetaCache] // set by SetGitMeta
 }
 
 // New creates a new server instance.
@@ -35,7 +54,9 @@ func New(cfg config.ServeConfig, store *index.Store) *Server {
 		store: store,
 		hub:   NewHub(),
 		mux:   http.NewServeMux(),
+		name:  config.RepoName(cfg.RootDir),
 	}
+	s.repos = []*Server{s}
 	s.registerRoutes()
 	s.server = &http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.Port),
@@ -50,32 +71,49 @@ func (s *Server) Hub() *Hub {
 }
 
 func (s *Server) registerRoutes() {
+	s.registerAPIRoutes()
+	s.mux.HandleFunc("GET /api/v1/repos", s.handleListRepos)
+	s.mux.HandleFunc("GET /api/v1/repos:search", s.handleRepoSearch)
+	s.mux.HandleFunc("GET /api/v1/repos/{repo}/{rest...}", s.handleRepoAPI)
+	s.mux.HandleFunc("POST /api/v1/repos/{repo}/{rest...}", s.handleRepoAPI)
+	s.mux.HandleFunc("GET /api/v1/ws", s.hub.ServeWS)
+	s.mux.HandleFunc("GET /api/health", s.handleHealth)
+	s.mux.HandleFunc("GET /llms.txt", s.handleLLMsTxt)
+	s.mux.HandleFunc("GET /llms-full.txt", s.handleLLMsFullTxt)
+
+	// SPA catch-all (lowest priority in ServeMux)
+	sub, err := fs.Sub(web.DistFS, "dist")
+	if err != nil {
+		sub = web.DistFS
+	}
+	s.mux.Handle("GET /", spaHandler(sub))
+}
+
+// registerAPIRoutes registers the per-repository API, which is also served
+// under /api/v1/repos/{name}/.
+func (s *Server) registerAPIRoutes() {
 	s.mux.HandleFunc("GET /api/v1/documents", s.handleListDocuments)
 	s.mux.HandleFunc("GET /api/v1/documents/{path...}", s.handleGetDocument)
+	s.mux.HandleFunc("POST /api/v1/documents:batchGet", s.handleBatchGetDocuments)
 	s.mux.HandleFunc("GET /api/v1/search", s.handleSearch)
+	s.mux.HandleFunc("GET /api/v1/chunks/search", s.handleChunkSearch)
 	s.mux.HandleFunc("GET /api/v1/tags", s.handleListTags)
 	s.mux.HandleFunc("GET /api/v1/metadata/fields", s.handleMetadataFields)
 	s.mux.HandleFunc("GET /api/v1/git/history/{path...}", s.handleHistory)
 	s.mux.HandleFunc("GET /api/v1/git/diff/{path...}", s.handleDiff)
 	s.mux.HandleFunc("GET /api/v1/git/blame/{path...}", s.handleBlame)
+	s.mux.HandleFunc("GET /api/v1/git/commits/{hash}", s.handleCommit)
+	s.mux.HandleFunc("GET /api/v1/activity", s.handleActivity)
 	s.mux.HandleFunc("GET /api/v1/tree", s.handleTree)
 	s.mux.HandleFunc("GET /api/v1/graph", s.handleGraph)
 	s.mux.HandleFunc("GET /api/v1/raw/{path...}", s.handleRawFile)
 	s.mux.HandleFunc("GET /api/v1/config", s.handleConfig)
-	s.mux.HandleFunc("GET /api/v1/ws", s.hub.ServeWS)
-	s.mux.HandleFunc("GET /api/health", s.handleHealth)
-
-	// SPA catch-all (lowest priority in ServeMux)
-	sub, err := fs.Sub(web.DistFS, "dist")
-	if err != nil {
-		sub = web.DistFS
-	}
-	s.mux.Handle("GET /", spaHandler(sub))
+	s.mux.HandleFunc("GET /api/v1/diagnostics", s.handleDiagnostics)
 }
 
 // Handler returns the HTTP handler with CORS middleware.
 func (s *Server) Handler() http.Handler {
-	return corsMiddleware(s.mux)
+	return corsMiddleware(s.indexStatusMiddleware(s.mux))
 }
 
 // Start begins listening on the configured port.
@@ -100,8 +138,9 @@ func (s *Server) Shutdown(ctx context.Context) error {
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Index-Incomplete")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -132,16 +171,40 @@ func queryInt(r *http.Request, key string, defaultVal int) int {
 	return v
 }
 
+// RepoConfig returns the repository configuration currently in effect.
+func (s *Server) RepoConfig() config.RepoConfig {
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+	return s.cfg.Repo
+}
+
+// SetRepoConfig replaces the repository configuration, e.g. after
+// .markdown-kb.yml was edited, and notifies WebSocket clients with a
+// "config" event so they re-fetch /api/v1/config. Settings that shape the
+// index (include, exclude, extensions, chunks, symlinks) are not re-applied
+// to the existing index.
+func (s *Server) SetRepoConfig(cfg config.RepoConfig) {
+	s.cfgMu.Lock()
+	s.cfg.Repo = cfg
+	s.cfgMu.Unlock()
+
+	s.Broadcast(WSEvent{Type: "config"})
+}
+
 func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	repoCfg := s.RepoConfig()
 	resp := map[string]any{
-		"title":     s.cfg.Repo.Title,
-		"theme":     s.cfg.Repo.Theme,
+		"title":     repoCfg.Title,
+		"theme":     repoCfg.Theme,
 		"themes":    config.ValidThemes,
-		"font":      s.cfg.Repo.Font,
+		"font":      repoCfg.Font,
 		"fonts":     config.ValidFonts,
-		"tag_icons": s.cfg.Repo.TagIcons,
+		"tag_icons": repoCfg.TagIcons,
+		// Lets the UI resolve [[page]] links and label documents without
+		// assuming ".md".
+		"extensions": parser.NormalizeExtensions(repoCfg.Extensions),
 	}
-	if preset := config.GetFontPreset(s.cfg.Repo.Font); preset != nil {
+	if preset := config.GetFontPreset(repoCfg.Font); preset != nil {
 		resp["font_url"] = preset.URL
 		resp["font_family"] = preset.Family
 	}
@@ -151,11 +214,29 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	docs, total, _ := s.store.ListDocuments(0, 0)
 	_ = docs
-	writeJSON(w, http.StatusOK, map[string]any{
-		"status":    "ok",
-		"version":   version,
-		"documents": total,
-	})
+	diags, _ := s.store.ListDiagnostics()
+	resp := map[string]any{
+		"status":      "ok",
+		"version":     version,
+		"documents":   total,
+		"diagnostics": len(diags),
+	}
+	if p := s.indexProgress(); p != nil {
+		resp["status"] = "indexing"
+		resp["indexing"] = p
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleDiagnostics lists document files that could not be loaded, such as
+// files in an undetectable encoding.
+func (s *Server) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	diags, err := s.store.ListDiagnostics()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list diagnostics")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.withIndexStatus(map[string]any{"data": diags}))
 }
 
 func (s *Server) handleListDocuments(w http.ResponseWriter, r *http.Request) {
@@ -167,6 +248,26 @@ func (s *Server) handleListDocuments(w http.ResponseWriter, r *http.Request) {
 
 	offset := (page - 1) * limit
 
+	fields, err := parseFields(r, documentFields)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	gitQ, err := parseGitQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if gitQ != nil && s.gitMeta.Load() == nil {
+		writeError(w, http.StatusBadRequest, "git history is not available for this repository")
+		return
+	}
+	if gitQ != nil && r.URL.Query().Get("rev") != "" {
+		// Git history describes the working tree, not the revision.
+		writeError(w, http.StatusBadRequest, "'sort' and 'author' can't be combined with 'rev'")
+		return
+	}
+
 	// Build filters from query params
 	filters := make(map[string]string)
 	if status := r.URL.Query().Get("status"); status != "" {
@@ -176,14 +277,26 @@ func (s *Server) handleListDocuments(w http.ResponseWriter, r *http.Request) {
 		filters["tags"] = tag
 	}
 
+	st, ok := s.storeFor(w, r)
+	if !ok {
+		return
+	}
+	defer st.release()
+
+	// Sorting and filtering by git history happen here rather than in
+	// the index, so they need every matching document.
+	pageLimit, pageOffset := limit, offset
+	if gitQ != nil {
+		pageLimit, pageOffset = -1, 0
+	}
+
 	var docs []index.DocumentSummary
 	var total int
-	var err error
 
 	if len(filters) > 0 {
-		docs, total, err = s.store.ListDocumentsWithFilter(filters, limit, offset)
+		docs, total, err = st.ListDocumentsWithFilter(filters, pageLimit, pageOffset)
 	} else {
-		docs, total, err = s.store.ListDocuments(limit, offset)
+		docs, total, err = st.ListDocuments(pageLimit, pageOffset)
 	}
 
 	if err != nil {
@@ -191,16 +304,33 @@ func (s *Server) handleListDocuments(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if docs == nil {
-		docs = []index.DocumentSummary{}
+	items := make([]documentItem, 0, len(docs))
+	for _, d := range docs {
+		items = append(items, documentItem{DocumentSummary: d, Git: s.gitMetaFor(st, d.Path)})
+	}
+	if gitQ != nil {
+		items = gitQ.apply(items)
+		total = len(items)
+		items = items[min(offset, len(items)):min(offset+limit, len(items))]
 	}
 
-	writeJSON(w, http.StatusOK, map[string]any{
-		"data":  docs,
+	var data any = items
+	if fields != nil {
+		projected := make([]map[string]any, 0, len(items))
+		for _, d := range items {
+			item := fields.project(d, d.Meta)
+			addBodyFields(st.Store, item, fields, d.Path)
+			projected = append(projected, item)
+		}
+		data = projected
+	}
+
+	writeJSON(w, http.StatusOK, s.withStoreStatus(st, map[string]any{
+		"data":  data,
 		"total": total,
 		"page":  page,
 		"limit": limit,
-	})
+	}))
 }
 
 func (s *Server) handleGetDocument(w http.ResponseWriter, r *http.Request) {
@@ -210,42 +340,207 @@ func (s *Server) handleGetDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	doc, err := s.store.GetDocument(path)
+	st, ok := s.documentFor(w, r)
+	if !ok {
+		return
+	}
+	defer st.release()
+
+	doc, err := st.getDocument(path)
+	if err == nil && doc == nil {
+		// A link written without its extension, such as [[page]], names
+		// page.mdx as well as page.md.
+		exts := parser.NormalizeExtensions(s.RepoConfig().Extensions)
+		base := parser.TrimExtension(path, exts)
+		for _, ext := range exts {
+			if doc, err = st.getDocument(base + ext); err != nil || doc != nil {
+				break
+			}
+		}
+		if doc != nil {
+			path = doc.Path
+		}
+	}
+	if err == nil && doc == nil && st.commit != "" {
+		// The document may have had another name at that revision.
+		if moved := s.pathAtRevision(st, path); moved != path {
+			doc, err = st.getDocument(moved)
+		}
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to get document")
 		return
 	}
 	if doc == nil {
+		if !st.requested && s.indexProgress() != nil {
+			writeError(w, http.StatusNotFound, "document not found (index is still being built)")
+			return
+		}
 		writeError(w, http.StatusNotFound, "document not found")
 		return
 	}
 
 	result := map[string]any{"data": doc}
+	if st.commit != "" {
+		result["rev"] = st.commit
+		if doc.Path != path {
+			result["path_at_rev"] = doc.Path
+		}
+	}
 
-	// Enrich with Git dates if available and frontmatter lacks dates
-	if s.cfg.RootDir != "" {
-		_, hasCreated := doc.Meta["created"]
-		_, hasUpdated := doc.Meta["updated"]
-		if !hasCreated || !hasUpdated {
-			created, updated, gitErr := gitpkg.FileDates(s.cfg.RootDir, path)
-			if gitErr == nil {
-				gitDates := map[string]any{}
-				if !hasCreated && !created.IsZero() {
-					gitDates["created"] = created.Format(time.RFC3339)
-				}
-				if !hasUpdated && !updated.IsZero() {
-					gitDates["updated"] = updated.Format(time.RFC3339)
-				}
-				if len(gitDates) > 0 {
-					result["git_dates"] = gitDates
-				}
-			}
+	// Enrich with Git dates if available and frontmatter lacks dates. They
+	// describe the working tree, so revisions go without.
+	if st.commit == "" {
+		if gitDates := s.gitDates(path, doc.Meta); len(gitDates) > 0 {
+			result["git_dates"] = gitDates
 		}
 	}
+	if m := s.gitMetaFor(st, path); m != nil {
+		result["git"] = m
+	}
+	if status := s.worktreeStatus(st, doc.Path); status != nil {
+		result["git_status"] = status(doc.Path)
+	}
 
 	writeJSON(w, http.StatusOK, result)
 }
 
+// gitDates returns Git-derived created/updated dates for the fields the
+// frontmatter lacks, or nil when Git is unavailable.
+func (s *Server) gitDates(path string, meta map[string]any) map[string]any {
+	if s.cfg.RootDir == "" {
+		return nil
+	}
+	_, hasCreated := meta["created"]
+	_, hasUpdated := meta["updated"]
+	if hasCreated && hasUpdated {
+		return nil
+	}
+
+	var created, updated time.Time
+	if c := s.gitMeta.Load(); c != nil {
+		m, _ := c.Get(path)
+		created, updated = m.Created, m.Updated
+	} else {
+		var err error
+		if created, updated, err = gitpkg.FileDates(s.cfg.RootDir, path); err != nil {
+			return nil
+		}
+	}
+	gitDates := map[string]any{}
+	if !hasCreated && !created.IsZero() {
+		gitDates["created"] = created.Format(time.RFC3339)
+	}
+	if !hasUpdated && !updated.IsZero() {
+		gitDates["updated"] = updated.Format(time.RFC3339)
+	}
+	return gitDates
+}
+
+// maxBatchPaths caps the number of documents in one batchGet request.
+const maxBatchPaths = 100
+
+// batchFields are the optional parts a batchGet request can select.
+var batchFields = map[string]bool{
+	"meta":      true,
+	"body":      true,
+	"outline":   true,
+	"git_dates": true,
+	"git":       true,
+}
+
+func (s *Server) handleBatchGetDocuments(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Paths  []string `json:"paths"`
+		Fields []string `json:"fields"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.Paths) == 0 {
+		writeError(w, http.StatusBadRequest, "'paths' is required")
+		return
+	}
+	if len(req.Paths) > maxBatchPaths {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d paths per request", maxBatchPaths))
+		return
+	}
+
+	// Default to what GET /documents/{path} returns, minus Git dates.
+	fields := map[string]bool{"meta": true, "body": true}
+	if len(req.Fields) > 0 {
+		fields = make(map[string]bool)
+		for _, f := range req.Fields {
+			if !batchFields[f] {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown field %q", f))
+				return
+			}
+			fields[f] = true
+		}
+	}
+
+	st, ok := s.storeFor(w, r)
+	if !ok {
+		return
+	}
+	defer st.release()
+
+	results := make([]map[string]any, 0, len(req.Paths))
+	found := 0
+	for _, path := range req.Paths {
+		doc, err := st.GetDocument(path)
+		if err != nil {
+			results = append(results, map[string]any{"path": path, "error": "failed to get document"})
+			continue
+		}
+		if doc == nil {
+			results = append(results, map[string]any{"path": path, "error": "document not found"})
+			continue
+		}
+		found++
+
+		item := map[string]any{
+			"path":     doc.Path,
+			"title":    doc.Title,
+			"mod_time": doc.ModTime,
+			"size":     doc.Size,
+		}
+		if fields["meta"] {
+			item["meta"] = doc.Meta
+		}
+		if fields["body"] {
+			item["body"] = doc.Body
+		}
+		if fields["outline"] {
+			outline := parser.ExtractOutline(doc.Body)
+			if outline == nil {
+				outline = []parser.Heading{}
+			}
+			item["outline"] = outline
+		}
+		// Git dates describe the working tree, so revisions go without.
+		if fields["git_dates"] && st.commit == "" {
+			if gitDates := s.gitDates(doc.Path, doc.Meta); len(gitDates) > 0 {
+				item["git_dates"] = gitDates
+			}
+		}
+		if fields["git"] {
+			if m := s.gitMetaFor(st, doc.Path); m != nil {
+				item["git"] = m
+			}
+		}
+		results = append(results, item)
+	}
+
+	writeJSON(w, http.StatusOK, s.withStoreStatus(st, map[string]any{
+		"data":    results,
+		"found":   found,
+		"missing": len(req.Paths) - found,
+	}))
+}
+
 func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query().Get("q")
 	if q == "" {
@@ -260,6 +555,12 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 	}
 	offset := (page - 1) * limit
 
+	fields, err := parseFields(r, searchFields)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Build filters from query params
 	filters := make(map[string]string)
 	if status := r.URL.Query().Get("status"); status != "" {
@@ -269,14 +570,19 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 		filters["tags"] = tag
 	}
 
+	st, ok := s.storeFor(w, r)
+	if !ok {
+		return
+	}
+	defer st.release()
+
 	var results []index.SearchResult
 	var total int
-	var err error
 
 	if len(filters) > 0 {
-		results, total, err = s.store.SearchWithFilter(q, filters, limit, offset)
+		results, total, err = st.SearchWithFilter(q, filters, limit, offset)
 	} else {
-		results, total, err = s.store.Search(q, limit, offset)
+		results, total, err = st.Search(q, limit, offset)
 	}
 
 	if err != nil {
@@ -284,16 +590,72 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if results == nil {
-		results = []index.SearchResult{}
+	items := make([]searchItem, 0, len(results))
+	for _, res := range results {
+		items = append(items, searchItem{SearchResult: res, Git: s.gitMetaFor(st, res.Path)})
 	}
 
-	writeJSON(w, http.StatusOK, map[string]any{
-		"data":  results,
+	var data any = items
+	if fields != nil {
+		projected := make([]map[string]any, 0, len(items))
+		for _, res := range items {
+			item := fields.project(res, res.Meta)
+			addBodyFields(st.Store, item, fields, res.Path)
+			projected = append(projected, item)
+		}
+		data = projected
+	}
+
+	writeJSON(w, http.StatusOK, s.withStoreStatus(st, map[string]any{
+		"data":  data,
 		"total": total,
 		"page":  page,
 		"limit": limit,
-	})
+	}))
+}
+
+// defaultChunkBudget and maxChunkBudget bound the token budget accepted by
+// the chunk search endpoint.
+const (
+	defaultChunkBudget = 4000
+	maxChunkBudget     = 100000
+)
+
+func (s *Server) handleChunkSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		writeError(w, http.StatusBadRequest, "query parameter 'q' is required")
+		return
+	}
+
+	budget := queryInt(r, "budget", defaultChunkBudget)
+	if budget > maxChunkBudget {
+		budget = maxChunkBudget
+	}
+
+	filters := make(map[string]string)
+	if status := r.URL.Query().Get("status"); status != "" {
+		filters["status"] = status
+	}
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		filters["tags"] = tag
+	}
+
+	chunks, used, err := s.store.SearchChunks(q, filters, budget)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "chunk search failed")
+		return
+	}
+
+	if chunks == nil {
+		chunks = []index.Chunk{}
+	}
+
+	writeJSON(w, http.StatusOK, s.withIndexStatus(map[string]any{
+		"data":   chunks,
+		"tokens": used,
+		"budget": budget,
+	}))
 }
 
 func (s *Server) handleListTags(w http.ResponseWriter, r *http.Request) {
@@ -307,7 +669,7 @@ func (s *Server) handleListTags(w http.ResponseWriter, r *http.Request) {
 		tags = []index.TagCount{}
 	}
 
-	writeJSON(w, http.StatusOK, map[string]any{"data": tags})
+	writeJSON(w, http.StatusOK, s.withIndexStatus(map[string]any{"data": tags}))
 }
 
 func (s *Server) handleMetadataFields(w http.ResponseWriter, r *http.Request) {
@@ -321,18 +683,49 @@ func (s *Server) handleMetadataFields(w http.ResponseWriter, r *http.Request) {
 		fields = []index.MetadataField{}
 	}
 
-	writeJSON(w, http.StatusOK, map[string]any{"data": fields})
+	writeJSON(w, http.StatusOK, s.withIndexStatus(map[string]any{"data": fields}))
 }
 
 func (s *Server) handleTree(w http.ResponseWriter, r *http.Request) {
-	entries, err := s.store.ListPaths()
+	fields, err := parseFields(r, treeFields)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	st, ok := s.storeFor(w, r)
+	if !ok {
+		return
+	}
+	defer st.release()
+
+	entries, err := st.ListPaths()
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to build tree")
 		return
 	}
 
 	tree := index.BuildTree(entries)
-	writeJSON(w, http.StatusOK, map[string]any{"data": tree})
+	// git status looks at the whole working tree, so it is only run when
+	// asked for.
+	if fields != nil && fields.top["git_status"] {
+		if status := s.worktreeStatus(st); status != nil {
+			setTreeStatus(tree, status)
+		}
+	}
+	if fields != nil && fields.top["git"] {
+		setTreeGit(tree, func(path string) *gitpkg.FileMeta { return s.gitMetaFor(st, path) })
+	}
+	if fields == nil {
+		writeJSON(w, http.StatusOK, s.withStoreStatus(st, map[string]any{"data": tree}))
+		return
+	}
+
+	metas := make(map[string]map[string]any, len(entries))
+	for _, e := range entries {
+		metas[e.Path] = e.Meta
+	}
+	writeJSON(w, http.StatusOK, s.withStoreStatus(st, map[string]any{"data": projectTree(tree, fields, metas)}))
 }
 
 func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
@@ -380,8 +773,24 @@ func (s *Server) handleDiff(w http.ResponseWriter, r *http.Request) {
 
 	from := r.URL.Query().Get("from")
 	to := r.URL.Query().Get("to")
-	if from == "" || to == "" {
-		writeError(w, http.StatusBadRequest, "'from' and 'to' query parameters are required")
+	against := r.URL.Query().Get("against")
+	switch {
+	case against == "":
+		if from == "" || to == "" {
+			writeError(w, http.StatusBadRequest, "'from' and 'to' query parameters are required")
+			return
+		}
+	case from != "" || to != "":
+		writeError(w, http.StatusBadRequest, "'against' can't be combined with 'from' and 'to'")
+		return
+	case against == "head":
+		// Everything not yet committed.
+		from, to = "HEAD", gitpkg.WorktreeRev
+	case against == "index":
+		// Everything not yet staged.
+		from, to = gitpkg.IndexRev, gitpkg.WorktreeRev
+	default:
+		writeError(w, http.StatusBadRequest, "against must be head or index")
 		return
 	}
 
@@ -390,6 +799,33 @@ func (s *Server) handleDiff(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if against == "" {
+		// Only commits: the working tree and index are reached through
+		// against, and anything else must not reach git as an option.
+		for _, rev := range []*string{&from, &to} {
+			hash, err := gitpkg.ResolveRevision(s.cfg.RootDir, *rev)
+			if errors.Is(err, gitpkg.ErrUnknownRevision) {
+				writeError(w, http.StatusNotFound, fmt.Sprintf("unknown revision %q", *rev))
+				return
+			}
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "failed to resolve revision")
+				return
+			}
+			*rev = hash
+		}
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "raw":
+	case "json":
+		s.handleStructuredDiff(w, r, docPath, from, to)
+		return
+	default:
+		writeError(w, http.StatusBadRequest, "format must be raw or json")
+		return
+	}
+
 	diff, err := gitpkg.Diff(s.cfg.RootDir, docPath, from, to)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to get diff")
@@ -399,6 +835,65 @@ func (s *Server) handleDiff(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]any{"data": diff})
 }
 
+// handleStructuredDiff serves ?format=json: the diff parsed into hunks and
+// lines, word segments with ?words=true, and the frontmatter keys that
+// changed.
+func (s *Server) handleStructuredDiff(w http.ResponseWriter, r *http.Request, docPath, from, to string) {
+	var opts gitpkg.DiffOptions
+	if raw := r.URL.Query().Get("words"); raw != "" {
+		words, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "words must be true or false")
+			return
+		}
+		opts.Words = words
+	}
+
+	files, err := gitpkg.StructuredDiff(s.cfg.RootDir, docPath, from, to, opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get diff")
+		return
+	}
+
+	result := map[string]any{"from": from, "to": to, "files": files}
+	if fm, ok := s.frontmatterDiff(docPath, from, to); ok {
+		result["frontmatter"] = fm
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"data": result})
+}
+
+// frontmatterDiff compares the frontmatter of the document at docPath in
+// two commits, the index or the working tree. A side without the document
+// has no frontmatter. It reports false when either side can't be read or
+// parsed.
+func (s *Server) frontmatterDiff(docPath, from, to string) (parser.FrontmatterDiff, bool) {
+	var metas [2]map[string]any
+	for i, rev := range []string{from, to} {
+		var data []byte
+		var err error
+		switch rev {
+		case gitpkg.WorktreeRev:
+			data, err = os.ReadFile(filepath.Join(s.cfg.RootDir, filepath.FromSlash(docPath)))
+		case gitpkg.IndexRev:
+			data, err = gitpkg.ReadFile(s.cfg.RootDir, "", docPath)
+		default:
+			data, err = gitpkg.ReadFile(s.cfg.RootDir, rev, docPath)
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return parser.FrontmatterDiff{}, false
+		}
+		meta, _, err := parser.ParseFrontmatter(bytes.NewReader(data))
+		if err != nil {
+			return parser.FrontmatterDiff{}, false
+		}
+		metas[i] = meta
+	}
+	return parser.DiffFrontmatter(metas[0], metas[1]), true
+}
+
 func (s *Server) handleBlame(w http.ResponseWriter, r *http.Request) {
 	docPath := r.PathValue("path")
 	if docPath == "" {
@@ -468,7 +963,44 @@ func (s *Server) handleRawFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.ServeFile(w, r, fullPath)
+	st, ok := s.documentFor(w, r)
+	if !ok {
+		return
+	}
+	defer st.release()
+
+	// Label transcoded documents with their original charset so clients
+	// can decode the raw bytes.
+	setEncoding := func(filePath string) {
+		if doc, err := st.getDocument(filePath); err == nil && doc != nil && doc.Encoding != "" {
+			w.Header().Set("Content-Type", "text/markdown; charset="+doc.Encoding)
+		}
+	}
+
+	if st.commit == "" {
+		setEncoding(filePath)
+		http.ServeFile(w, r, fullPath)
+		return
+	}
+
+	data, err := gitpkg.ReadFile(s.cfg.RootDir, st.commit, filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		// The file may have had another name at that revision.
+		if moved := s.pathAtRevision(st, filePath); moved != filePath {
+			filePath = moved
+			data, err = gitpkg.ReadFile(s.cfg.RootDir, st.commit, filePath)
+		}
+	}
+	setEncoding(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		writeError(w, http.StatusNotFound, "file not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read file")
+		return
+	}
+	http.ServeContent(w, r, filePath, time.Time{}, bytes.NewReader(data))
 }
 
 func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
@@ -478,5 +1010,35 @@ func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]any{"data": graph})
+	writeJSON(w, http.StatusOK, s.withIndexStatus(map[string]any{"data": graph}))
+}
+
+func (s *Server) handleLLMsTxt(w http.ResponseWriter, r *http.Request) {
+	entries, err := llms.Collect(s.store)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to build llms.txt")
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	linkBase := fmt.Sprintf("%s://%s/api/v1/raw/", scheme, r.Host)
+
+	repoCfg := s.RepoConfig()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	llms.WriteIndex(w, repoCfg.Title, repoCfg.Description, linkBase, entries)
+}
+
+func (s *Server) handleLLMsFullTxt(w http.ResponseWriter, r *http.Request) {
+	entries, err := llms.Collect(s.store)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to build llms-full.txt")
+		return
+	}
+
+	repoCfg := s.RepoConfig()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	llms.WriteFull(w, repoCfg.Title, repoCfg.Description, entries)
 }
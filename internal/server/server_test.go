@@ -3,16 +3,22 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/esakat/markdown-kb/internal/config"
+	gitpkg "github.com/esakat/markdown-kb/internal/git"
 	"github.com/esakat/markdown-kb/internal/index"
+	"github.com/esakat/markdown-kb/internal/parser"
 	"github.com/esakat/markdown-kb/internal/scanner"
 )
 
@@ -202,6 +208,124 @@ func TestHandleGetDocument_NotFound(t *testing.T) {
 	}
 }
 
+func TestHandleGetDocument_WithoutExtension(t *testing.T) {
+	srv, ts := newTestServer(t)
+	srv.SetRepoConfig(config.RepoConfig{Extensions: []string{".md", ".markdown"}})
+	srv.store.IndexDocument(scanner.Document{RelPath: "notes.markdown", Frontmatter: map[string]any{"title": "Notes"}, Body: "Notes.\n"})
+
+	// [[notes]] and [[guide]] links reach the document with its extension.
+	for link, want := range map[string]string{"notes": "notes.markdown", "guide": "guide.md", "notes.md": "notes.markdown"} {
+		var body struct {
+			Data struct {
+				Path string `json:"path"`
+			} `json:"data"`
+		}
+		if resp := getInto(t, ts.URL+"/api/v1/documents/"+link, &body); resp.StatusCode != http.StatusOK || body.Data.Path != want {
+			t.Errorf("GET %s = %d %q, want %q", link, resp.StatusCode, body.Data.Path, want)
+		}
+	}
+
+	var body map[string]any
+	getInto(t, ts.URL+"/api/v1/config", &body)
+	if exts, _ := body["extensions"].([]any); len(exts) != 2 || exts[1] != ".markdown" {
+		t.Errorf("config extensions = %v", body["extensions"])
+	}
+}
+
+func postBatchGet(t *testing.T, url, body string) (int, map[string]any) {
+	t.Helper()
+	resp, err := http.Post(url+"/api/v1/documents:batchGet", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	var out map[string]any
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+func TestHandleBatchGetDocuments(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	status, body := postBatchGet(t, ts.URL, `{"paths":["guide.md","missing.md","api.md"]}`)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+
+	data, _ := body["data"].([]any)
+	if len(data) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(data))
+	}
+	first := data[0].(map[string]any)
+	if first["path"] != "guide.md" || first["body"] == nil || first["meta"] == nil {
+		t.Errorf("expected guide.md with body and meta, got %v", first)
+	}
+	missing := data[1].(map[string]any)
+	if missing["error"] != "document not found" {
+		t.Errorf("expected per-path not found error, got %v", missing)
+	}
+	if data[2].(map[string]any)["path"] != "api.md" {
+		t.Errorf("results should keep request order, got %v", data[2])
+	}
+	if found, _ := body["found"].(float64); found != 2 {
+		t.Errorf("found = %v, want 2", body["found"])
+	}
+}
+
+func TestHandleBatchGetDocuments_FieldSelection(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	_, body := postBatchGet(t, ts.URL, `{"paths":["guide.md"],"fields":["meta","outline"]}`)
+	doc := body["data"].([]any)[0].(map[string]any)
+
+	if _, ok := doc["body"]; ok {
+		t.Error("body should be omitted when not selected")
+	}
+	if _, ok := doc["meta"]; !ok {
+		t.Error("expected meta")
+	}
+	outline, _ := doc["outline"].([]any)
+	if len(outline) != 1 || outline[0].(map[string]any)["text"] != "Go Guide" {
+		t.Errorf("outline = %v", doc["outline"])
+	}
+}
+
+func TestHandleBatchGetDocuments_BadRequests(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	tooMany := make([]string, maxBatchPaths+1)
+	for i := range tooMany {
+		tooMany[i] = "a.md"
+	}
+	tooManyJSON, _ := json.Marshal(map[string]any{"paths": tooMany})
+
+	for name, reqBody := range map[string]string{
+		"invalid json":  `{`,
+		"no paths":      `{"paths":[]}`,
+		"unknown field": `{"paths":["guide.md"],"fields":["secret"]}`,
+		"too many":      string(tooManyJSON),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if status, _ := postBatchGet(t, ts.URL, reqBody); status != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", status, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestHandleBatchGetDocuments_GitDates(t *testing.T) {
+	_, ts := newTestServerWithGitRepo(t)
+
+	_, body := postBatchGet(t, ts.URL, `{"paths":["guide.md"],"fields":["git_dates"]}`)
+	doc := body["data"].([]any)[0].(map[string]any)
+
+	gitDates, ok := doc["git_dates"].(map[string]any)
+	if !ok || gitDates["created"] == nil || gitDates["updated"] == nil {
+		t.Errorf("expected git_dates with created and updated, got %v", doc["git_dates"])
+	}
+}
+
 func TestHandleSearch(t *testing.T) {
 	_, ts := newTestServer(t)
 
@@ -241,6 +365,54 @@ func TestHandleSearch_MissingQuery(t *testing.T) {
 	}
 }
 
+func TestHandleChunkSearch(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/api/v1/chunks/search?q=programming&budget=500")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var body map[string]any
+	json.NewDecoder(resp.Body).Decode(&body)
+
+	data, ok := body["data"].([]any)
+	if !ok || len(data) == 0 {
+		t.Fatalf("expected chunk results, got %v", body["data"])
+	}
+	chunk := data[0].(map[string]any)
+	if chunk["path"] != "guide.md" {
+		t.Errorf("path = %v, want %q", chunk["path"], "guide.md")
+	}
+	for _, key := range []string{"id", "text", "tokens", "start_line", "end_line"} {
+		if _, ok := chunk[key]; !ok {
+			t.Errorf("expected %q in chunk", key)
+		}
+	}
+	if budget, _ := body["budget"].(float64); budget != 500 {
+		t.Errorf("budget = %v, want 500", body["budget"])
+	}
+}
+
+func TestHandleChunkSearch_MissingQuery(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/api/v1/chunks/search")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
 func TestHandleListTags(t *testing.T) {
 	_, ts := newTestServer(t)
 
@@ -758,6 +930,49 @@ func TestHandleDiff(t *testing.T) {
 	}
 }
 
+func TestHandleDiff_Structured(t *testing.T) {
+	dir := newRevisionTestRepo(t)
+	ts := newRevisionTestServer(t, dir, "")
+
+	var body struct {
+		Data struct {
+			Files       []gitpkg.FileDiff      `json:"files"`
+			Frontmatter parser.FrontmatterDiff `json:"frontmatter"`
+		} `json:"data"`
+	}
+	resp := getInto(t, ts.URL+"/api/v1/git/diff/guide.md?from=v1&to=main&format=json&words=true", &body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if len(body.Data.Files) != 1 || len(body.Data.Files[0].Hunks) != 1 {
+		t.Fatalf("files = %+v, want one file with one hunk", body.Data.Files)
+	}
+	hunk := body.Data.Files[0].Hunks[0]
+	var added []string
+	for _, line := range hunk.Lines {
+		if line.Type == "add" {
+			added = append(added, fmt.Sprintf("%d:%s", line.NewLine, line.Content))
+		}
+	}
+	if want := []string{"2:title: Guide v2", "5:Second edition."}; !slices.Equal(added, want) {
+		t.Errorf("added lines = %v, want %v", added, want)
+	}
+	if len(hunk.Words) == 0 {
+		t.Error("expected word segments with words=true")
+	}
+	fm := body.Data.Frontmatter
+	if len(fm.Changed) != 1 || fm.Changed[0].Key != "title" || fm.Changed[0].Old != "Guide v1" || fm.Changed[0].New != "Guide v2" {
+		t.Errorf("frontmatter = %+v, want title changed", fm)
+	}
+
+	for _, query := range []string{"format=html", "format=json&words=maybe"} {
+		var errBody map[string]any
+		if resp := getInto(t, ts.URL+"/api/v1/git/diff/guide.md?from=v1&to=main&"+query, &errBody); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, resp.StatusCode)
+		}
+	}
+}
+
 func TestHandleDiff_MissingParams(t *testing.T) {
 	_, ts := newTestServerWithGitRepo(t)
 
@@ -897,6 +1112,24 @@ func TestHandleConfig_WithRepoConfig(t *testing.T) {
 	}
 }
 
+func TestSetRepoConfig(t *testing.T) {
+	srv, ts := newTestServer(t)
+
+	srv.SetRepoConfig(config.RepoConfig{Title: "Reloaded", Theme: "nord"})
+
+	resp, err := http.Get(ts.URL + "/api/v1/config")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body map[string]any
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body["title"] != "Reloaded" || body["theme"] != "nord" {
+		t.Errorf("config after reload = %v", body)
+	}
+}
+
 func TestHandleGraph(t *testing.T) {
 	_, ts := newTestServer(t)
 
@@ -935,3 +1168,163 @@ func TestHandleGraph(t *testing.T) {
 		t.Error("expected at least 1 edge for shared 'go' tag")
 	}
 }
+
+func TestHandleLLMsTxt(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/llms.txt")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	want := "- [Go Guide](" + ts.URL + "/api/v1/raw/guide.md): Learn Go programming language."
+	if !strings.Contains(string(body), want) {
+		t.Errorf("llms.txt missing %q\n%s", want, body)
+	}
+}
+
+func TestHandleLLMsFullTxt(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/llms-full.txt")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "これは日本語のドキュメントです。") {
+		t.Errorf("llms-full.txt missing document body\n%s", body)
+	}
+}
+
+func getJSON(t *testing.T, url string) (int, map[string]any) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s error = %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	var body map[string]any
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func TestHandleListDocuments_Fields(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	status, body := getJSON(t, ts.URL+"/api/v1/documents?fields=title,meta.status,word_count")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+
+	for _, item := range body["data"].([]any) {
+		doc := item.(map[string]any)
+		if _, ok := doc["path"]; !ok {
+			t.Error("path should always be included")
+		}
+		if _, ok := doc["title"]; !ok {
+			t.Error("expected title")
+		}
+		if _, ok := doc["size"]; ok {
+			t.Error("size should be omitted")
+		}
+		meta := doc["meta"].(map[string]any)
+		if len(meta) != 1 || meta["status"] == nil {
+			t.Errorf("meta = %v, want only status", meta)
+		}
+		if wc, _ := doc["word_count"].(float64); wc <= 0 {
+			t.Errorf("word_count = %v, want > 0", doc["word_count"])
+		}
+	}
+}
+
+func TestHandleListDocuments_UnknownField(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	if status, _ := getJSON(t, ts.URL+"/api/v1/documents?fields=body"); status != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestHandleSearch_Fields(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	_, body := getJSON(t, ts.URL+"/api/v1/search?q=programming&fields=snippet,outline")
+	data := body["data"].([]any)
+	if len(data) == 0 {
+		t.Fatal("expected search results")
+	}
+	hit := data[0].(map[string]any)
+	if _, ok := hit["meta"]; ok {
+		t.Error("meta should be omitted")
+	}
+	if _, ok := hit["snippet"]; !ok {
+		t.Error("expected snippet")
+	}
+	outline, _ := hit["outline"].([]any)
+	if len(outline) != 1 {
+		t.Errorf("outline = %v, want 1 heading", hit["outline"])
+	}
+}
+
+func TestHandleTree_Fields(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	_, body := getJSON(t, ts.URL+"/api/v1/tree?fields=meta.status")
+	root := body["data"].(map[string]any)
+	children := root["children"].([]any)
+	if len(children) != 3 {
+		t.Fatalf("expected 3 files, got %d", len(children))
+	}
+	file := children[0].(map[string]any)
+	if file["name"] == nil || file["type"] != "file" || file["path"] == nil {
+		t.Errorf("file node missing name/type/path: %v", file)
+	}
+	if _, ok := file["title"]; ok {
+		t.Error("title should be omitted")
+	}
+	if meta, _ := file["meta"].(map[string]any); meta["status"] == nil {
+		t.Errorf("expected meta.status, got %v", file["meta"])
+	}
+}
+
+func TestHandleDiagnostics(t *testing.T) {
+	srv, ts := newTestServer(t)
+	srv.store.SetDiagnostic(scanner.Diagnostic{Path: "legacy.md", Message: "decoding file: unknown or unsupported text encoding"})
+
+	var body struct {
+		Data []scanner.Diagnostic `json:"data"`
+	}
+	resp, err := http.Get(ts.URL + "/api/v1/diagnostics")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+	json.NewDecoder(resp.Body).Decode(&body)
+
+	if len(body.Data) != 1 || body.Data[0].Path != "legacy.md" {
+		t.Errorf("data = %+v", body.Data)
+	}
+
+	var health map[string]any
+	resp2, err := http.Get(ts.URL + "/api/health")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp2.Body.Close()
+	json.NewDecoder(resp2.Body).Decode(&health)
+	if health["diagnostics"] != float64(1) {
+		t.Errorf("health diagnostics = %v, want 1", health["diagnostics"])
+	}
+}
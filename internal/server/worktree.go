@@ -0,0 +1,47 @@
+package server
+
+import (
+	gitpkg "github.com/esakat/markdown-kb/internal/git"
+	"github.com/esakat/markdown-kb/internal/index"
+)
+
+// worktreeStatus looks up the git status of the files at paths, or of every
+// file when none are given, and returns a function giving the status of
+// each: "clean", "modified", "staged" or "untracked". It returns nil
+// outside a git repository and for revisions, which have no uncommitted
+// changes.
+func (s *Server) worktreeStatus(st revStore, paths ...string) func(path string) string {
+	if s.cfg.RootDir == "" || st.commit != "" {
+		return nil
+	}
+	changed, err := gitpkg.Status(s.cfg.RootDir, paths...)
+	if err != nil {
+		return nil
+	}
+	return func(path string) string {
+		if status, ok := changed[path]; ok {
+			return status
+		}
+		return gitpkg.StatusClean
+	}
+}
+
+// setTreeStatus fills in the git status of every file below node.
+func setTreeStatus(node *index.TreeNode, status func(path string) string) {
+	if node.Type == "file" {
+		node.GitStatus = status(node.Path)
+	}
+	for _, child := range node.Children {
+		setTreeStatus(child, status)
+	}
+}
+
+// setTreeGit fills in the git history of every file below node.
+func setTreeGit(node *index.TreeNode, meta func(path string) *gitpkg.FileMeta) {
+	if node.Type == "file" {
+		node.Git = meta(node.Path)
+	}
+	for _, child := range node.Children {
+		setTreeGit(child, meta)
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorktreeStatus(t *testing.T) {
+	dir := newRevisionTestRepo(t)
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("---\ntitle: Notes\n---\n\nNot committed.\n"), 0o644)
+	ts := newRevisionTestServer(t, dir, "")
+
+	var tree struct {
+		Data struct {
+			Children []struct {
+				Path      string `json:"path"`
+				GitStatus string `json:"git_status"`
+			} `json:"children"`
+		} `json:"data"`
+	}
+	getInto(t, ts.URL+"/api/v1/tree?fields=git_status", &tree)
+	got := map[string]string{}
+	for _, c := range tree.Data.Children {
+		got[c.Path] = c.GitStatus
+	}
+	want := map[string]string{"guide.md": "modified", "new.md": "clean", "notes.md": "untracked"}
+	for path, status := range want {
+		if got[path] != status {
+			t.Errorf("tree status of %s = %q, want %q", path, got[path], status)
+		}
+	}
+
+	// Only a tree asking for it runs git status.
+	var plain map[string]any
+	getInto(t, ts.URL+"/api/v1/tree", &plain)
+	if strings.Contains(fmt.Sprint(plain), "git_status") {
+		t.Errorf("tree without fields = %v, want no git_status", plain)
+	}
+
+	var doc struct {
+		GitStatus string `json:"git_status"`
+	}
+	getInto(t, ts.URL+"/api/v1/documents/guide.md", &doc)
+	if doc.GitStatus != "modified" {
+		t.Errorf("document git_status = %q, want modified", doc.GitStatus)
+	}
+	// Revisions have no uncommitted changes.
+	doc.GitStatus = ""
+	getInto(t, ts.URL+"/api/v1/documents/guide.md?rev=v1", &doc)
+	if doc.GitStatus != "" {
+		t.Errorf("git_status at a revision = %q, want none", doc.GitStatus)
+	}
+
+	var diff struct {
+		Data string `json:"data"`
+	}
+	getInto(t, ts.URL+"/api/v1/git/diff/guide.md?against=head", &diff)
+	if !strings.Contains(diff.Data, "+Draft edition.") {
+		t.Errorf("diff against HEAD = %q, want the draft", diff.Data)
+	}
+	diff.Data = ""
+	getInto(t, ts.URL+"/api/v1/git/diff/notes.md?against=index", &diff)
+	if !strings.Contains(diff.Data, "+Not committed.") {
+		t.Errorf("diff of untracked file = %q, want it added", diff.Data)
+	}
+
+	var structured struct {
+		Data struct {
+			Frontmatter struct {
+				Changed []struct {
+					Key string `json:"key"`
+					New any    `json:"new"`
+				} `json:"changed"`
+			} `json:"frontmatter"`
+		} `json:"data"`
+	}
+	getInto(t, ts.URL+"/api/v1/git/diff/guide.md?against=head&format=json", &structured)
+	if c := structured.Data.Frontmatter.Changed; len(c) != 1 || c[0].New != "Guide draft" {
+		t.Errorf("frontmatter changes = %+v, want title to Guide draft", c)
+	}
+
+	// Revisions must be commits, never options or the working tree.
+	out := filepath.Join(t.TempDir(), "out")
+	for _, query := range []string{"from=--output=" + out + "&to=v1", "from=v1&to=:worktree", "from=:index&to=:worktree"} {
+		var errBody map[string]any
+		if resp := getInto(t, ts.URL+"/api/v1/git/diff/guide.md?"+query, &errBody); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", query, resp.StatusCode)
+		}
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("diff wrote %s", out)
+	}
+
+	for _, query := range []string{"against=stash", "against=head&from=v1"} {
+		var errBody map[string]any
+		if resp := getInto(t, ts.URL+"/api/v1/git/diff/guide.md?"+query, &errBody); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, resp.StatusCode)
+		}
+	}
+}
@@ -12,8 +12,21 @@ import (
 
 // WSEvent is a message sent over WebSocket to clients.
 type WSEvent struct {
-	Type string `json:"type"` // "created", "updated", "deleted"
-	Path string `json:"path"` // relative path of the changed file
+	Type     string         `json:"type"`               // "created", "updated", "deleted", "renamed", "dir_deleted", "dir_renamed", "reindexed", "batch", "config", "index_progress", "index_complete"
+	Path     string         `json:"path,omitempty"`     // relative path of the changed file or directory
+	From     string         `json:"from,omitempty"`     // previous path, for "renamed" and "dir_renamed"
+	Paths    []string       `json:"paths,omitempty"`    // documents removed by "dir_deleted", or their new paths for "dir_renamed"
+	Commit   string         `json:"commit,omitempty"`   // new HEAD commit, for "reindexed"
+	Changes  *ChangeSet     `json:"changes,omitempty"`  // set for "reindexed" and "batch"
+	Progress *IndexProgress `json:"progress,omitempty"` // set for "index_progress"
+	Repo     string         `json:"repo,omitempty"`     // repository name when serving several repositories
+}
+
+// ChangeSet lists the documents changed by a batch of updates.
+type ChangeSet struct {
+	Created []string `json:"created"`
+	Updated []string `json:"updated"`
+	Deleted []string `json:"deleted"`
 }
 
 // Hub manages WebSocket connections and broadcasts events.
@@ -0,0 +1,278 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// debounce is how long a file must stay quiet before its change is
+// reported.
+const debounce = 300 * time.Millisecond
+
+// pendingMove is a directory moved away from From, waiting to be paired
+// with the creation of its new name.
+type pendingMove struct {
+	from  string
+	timer *time.Timer
+}
+
+// unpairedFile is a removed or created document waiting to be paired with
+// a created or removed one with the same content.
+type unpairedFile struct {
+	path    string // absolute
+	sum     [sha256.Size]byte
+	created bool
+	timer   *time.Timer
+}
+
+// dispatcher turns raw changes found by the fsnotify loop or the poller
+// into debounced Events. Its methods are safe for concurrent use.
+type dispatcher struct {
+	w      *Watcher
+	handle func(Event)
+
+	mu          sync.Mutex
+	pending     map[string]*time.Timer // debounce timers by absolute path
+	move        *pendingMove
+	unpaired    []*unpairedFile
+	configTimer *time.Timer
+	gitTimer    *time.Timer
+	gitActive   bool            // git touched its directory recently
+	held        map[string]bool // documents changed while gitActive
+}
+
+func newDispatcher(w *Watcher, handle func(Event)) *dispatcher {
+	return &dispatcher{w: w, handle: handle, pending: make(map[string]*time.Timer), held: make(map[string]bool)}
+}
+
+func (d *dispatcher) relPath(path string) string {
+	rel, err := filepath.Rel(d.w.rootDir, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// schedule reports a change to the document at path once it has been
+// quiet for the debounce interval.
+func (d *dispatcher) schedule(path string) {
+	d.mu.Lock()
+	if t, ok := d.pending[path]; ok {
+		t.Reset(debounce)
+	} else {
+		d.pending[path] = time.AfterFunc(debounce, func() { d.fire(path) })
+	}
+	d.mu.Unlock()
+}
+
+// fire classifies the change to path by comparing its content hash with
+// the one last seen.
+func (d *dispatcher) fire(path string) {
+	d.mu.Lock()
+	delete(d.pending, path)
+	if d.gitActive {
+		// Wait to see whether git moved HEAD; checkHead reports it.
+		d.held[path] = true
+		d.mu.Unlock()
+		return
+	}
+	d.mu.Unlock()
+
+	sum, old, known, exists := d.w.rehash(path)
+	rel := d.relPath(path)
+	switch {
+	case exists && known:
+		d.handle(Event{Op: FileChanged, Path: rel})
+	case exists:
+		d.pair(path, sum, true, FileCreated)
+	case known && old.hashed:
+		d.pair(path, old.sum, false, FileRemoved)
+	default:
+		d.handle(Event{Op: FileRemoved, Path: rel})
+	}
+}
+
+// claimUnpaired takes the waiting file matching pred, if any, so that
+// exactly one of its counterpart and the timeout reports it.
+func (d *dispatcher) claimUnpaired(pred func(*unpairedFile) bool) *unpairedFile {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, u := range d.unpaired {
+		if pred(u) {
+			d.unpaired = append(d.unpaired[:i], d.unpaired[i+1:]...)
+			u.timer.Stop()
+			return u
+		}
+	}
+	return nil
+}
+
+// pair reports a rename if a file with sum was removed (created is true)
+// or created (created is false) recently, and otherwise waits pairWindow
+// for such a file before reporting op.
+func (d *dispatcher) pair(path string, sum [sha256.Size]byte, created bool, op Op) {
+	if u := d.claimUnpaired(func(u *unpairedFile) bool { return u.created != created && u.sum == sum }); u != nil {
+		from, to := u.path, path
+		if !created {
+			from, to = path, u.path
+		}
+		d.handle(Event{Op: FileRenamed, Path: d.relPath(to), From: d.relPath(from)})
+		return
+	}
+	u := &unpairedFile{path: path, sum: sum, created: created}
+	u.timer = time.AfterFunc(pairWindow, func() {
+		if d.claimUnpaired(func(c *unpairedFile) bool { return c == u }) != nil {
+			d.handle(Event{Op: op, Path: d.relPath(path)})
+		}
+	})
+	d.mu.Lock()
+	d.unpaired = append(d.unpaired, u)
+	d.mu.Unlock()
+}
+
+// cancelUnder drops pending file events and hashes below dir; the
+// directory event covers them.
+func (d *dispatcher) cancelUnder(dir string) {
+	prefix := dir + string(filepath.Separator)
+	d.mu.Lock()
+	for path, t := range d.pending {
+		if strings.HasPrefix(path, prefix) {
+			t.Stop()
+			delete(d.pending, path)
+		}
+	}
+	kept := d.unpaired[:0]
+	for _, u := range d.unpaired {
+		if strings.HasPrefix(u.path, prefix) {
+			u.timer.Stop()
+		} else {
+			kept = append(kept, u)
+		}
+	}
+	d.unpaired = kept
+	d.mu.Unlock()
+	d.w.forgetDir(dir)
+}
+
+// movedAway records that the directory at path was moved. It is reported
+// as renamed if claimMove pairs it with a new directory within the
+// debounce interval, and as removed otherwise. An earlier unclaimed move is
+// reported as removed.
+func (d *dispatcher) movedAway(path string) {
+	d.cancelUnder(path)
+	if from, ok := d.claimMove(); ok {
+		d.handle(Event{Op: DirRemoved, Path: from})
+	}
+	m := &pendingMove{from: d.relPath(path)}
+	m.timer = time.AfterFunc(debounce, func() {
+		d.mu.Lock()
+		claimed := d.move == m
+		if claimed {
+			d.move = nil
+		}
+		d.mu.Unlock()
+		if claimed {
+			d.handle(Event{Op: DirRemoved, Path: m.from})
+		}
+	})
+	d.mu.Lock()
+	d.move = m
+	d.mu.Unlock()
+}
+
+// claimMove takes the pending directory move, if any, so that exactly one
+// of the pairing creation and the timeout reports it.
+func (d *dispatcher) claimMove() (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.move == nil {
+		return "", false
+	}
+	m := d.move
+	d.move = nil
+	m.timer.Stop()
+	return m.from, true
+}
+
+// configChanged reports a change to the config file at rel once it has
+// been quiet for the debounce interval.
+func (d *dispatcher) configChanged(rel string) {
+	d.mu.Lock()
+	if d.configTimer == nil {
+		d.configTimer = time.AfterFunc(debounce, func() { d.handle(Event{Op: ConfigChanged, Path: rel}) })
+	} else {
+		d.configTimer.Reset(debounce)
+	}
+	d.mu.Unlock()
+}
+
+// gitActivity records that git touched its directory. Document changes
+// are held until git has been quiet for the debounce interval, so that a
+// checkout or pull is reported as a single HeadMoved event.
+func (d *dispatcher) gitActivity() {
+	d.mu.Lock()
+	d.gitActive = true
+	if d.gitTimer == nil {
+		d.gitTimer = time.AfterFunc(debounce, d.checkHead)
+	} else {
+		d.gitTimer.Reset(debounce)
+	}
+	d.mu.Unlock()
+}
+
+// checkHead reports a HeadMoved event if HEAD moved, and then releases the
+// held document changes that it doesn't cover.
+func (d *dispatcher) checkHead() {
+	if d.w.gitBusy() {
+		d.gitActivity()
+		return
+	}
+	move := d.w.headMoved()
+
+	d.mu.Lock()
+	if move != nil {
+		for _, rel := range move.paths() {
+			path := filepath.Join(d.w.rootDir, rel)
+			if t, ok := d.pending[path]; ok {
+				t.Stop()
+				delete(d.pending, path)
+			}
+			delete(d.held, path)
+		}
+	}
+	held := d.held
+	d.held = make(map[string]bool)
+	d.gitActive = false
+	d.mu.Unlock()
+
+	if move != nil {
+		d.handle(Event{Op: HeadMoved, Head: move})
+	}
+	for path := range held {
+		d.fire(path)
+	}
+}
+
+// stop cancels all pending events.
+func (d *dispatcher) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, t := range d.pending {
+		t.Stop()
+	}
+	if d.move != nil {
+		d.move.timer.Stop()
+	}
+	for _, u := range d.unpaired {
+		u.timer.Stop()
+	}
+	if d.configTimer != nil {
+		d.configTimer.Stop()
+	}
+	if d.gitTimer != nil {
+		d.gitTimer.Stop()
+	}
+}
@@ -0,0 +1,134 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	gitpkg "github.com/esakat/markdown-kb/internal/git"
+	"github.com/esakat/markdown-kb/internal/parser"
+)
+
+// HeadMove describes the documents changed by a checkout, pull, reset,
+// rebase or commit. Paths are relative to the root. Documents whose content
+// on disk is already what the watcher last saw (e.g. after a commit) are
+// left out.
+type HeadMove struct {
+	From     string // previous HEAD commit; empty for an unborn branch
+	To       string // new HEAD commit
+	Added    []string
+	Modified []string
+	Removed  []string
+}
+
+// gitFiles are the files in the git directory whose changes may mean that
+// HEAD moved or that git is rewriting the working tree.
+var gitFiles = map[string]bool{
+	"HEAD":        true,
+	"ORIG_HEAD":   true,
+	"index":       true,
+	"index.lock":  true,
+	"packed-refs": true,
+}
+
+// gitState tracks the repository's HEAD. It is nil when the root isn't in
+// a git repository.
+type gitState struct {
+	dir  string // absolute git directory
+	mu   sync.Mutex
+	head string // guarded by mu
+}
+
+// initGit finds the repository containing the root, if any.
+func (w *Watcher) initGit() {
+	dir, _, err := gitpkg.Dirs(w.rootDir)
+	if err != nil {
+		return
+	}
+	head, err := gitpkg.ResolveRevision(w.rootDir, "HEAD")
+	if err != nil && !errors.Is(err, gitpkg.ErrUnknownRevision) {
+		return
+	}
+	w.git = &gitState{dir: dir, head: head}
+}
+
+// isGitFile reports whether path is one of gitFiles in the git directory.
+func (w *Watcher) isGitFile(path string) bool {
+	return w.git != nil && filepath.Dir(path) == w.git.dir && gitFiles[filepath.Base(path)]
+}
+
+// gitBusy reports whether git holds the index lock, i.e. is in the middle
+// of an operation that may rewrite the working tree.
+func (w *Watcher) gitBusy() bool {
+	_, err := os.Stat(filepath.Join(w.git.dir, "index.lock"))
+	return err == nil
+}
+
+// headMoved checks whether HEAD moved since the last call and, if so,
+// returns the documents that changed on disk as a result. It returns nil
+// when HEAD didn't move or the change can't be determined; per-file events
+// then cover the documents.
+func (w *Watcher) headMoved() *HeadMove {
+	w.git.mu.Lock()
+	defer w.git.mu.Unlock()
+
+	head, err := gitpkg.ResolveRevision(w.rootDir, "HEAD")
+	if err != nil || head == w.git.head {
+		return nil
+	}
+	from := w.git.head
+	w.git.head = head
+
+	base := from
+	if base == "" {
+		base = gitpkg.EmptyTree
+	}
+	changes, err := gitpkg.ChangedFiles(w.rootDir, base, head)
+	if err != nil {
+		return nil
+	}
+
+	move := &HeadMove{From: from, To: head}
+	for _, c := range changes {
+		rel := filepath.FromSlash(c.Path)
+		if !parser.HasExtension(rel, w.exts) || w.ignore.IgnoredPath(rel, false) {
+			continue
+		}
+		sum, old, known, exists := w.rehash(filepath.Join(w.rootDir, rel))
+		switch {
+		case exists && known && old.hashed && sum == old.sum:
+			// Already seen, e.g. the change was just committed.
+		case exists && c.Status == 'A' && !known:
+			move.Added = append(move.Added, rel)
+		case exists:
+			move.Modified = append(move.Modified, rel)
+		case known:
+			move.Removed = append(move.Removed, rel)
+		}
+	}
+	return move
+}
+
+// paths returns every path in m.
+func (m *HeadMove) paths() []string {
+	return slices.Concat(m.Added, m.Modified, m.Removed)
+}
+
+// Empty reports whether m changes no documents, as after a commit.
+func (m *HeadMove) Empty() bool {
+	return len(m.Added)+len(m.Modified)+len(m.Removed) == 0
+}
+
+// removesUnder reports whether m removes a document below dir.
+func (m *HeadMove) removesUnder(dir string) bool {
+	prefix := dir + string(filepath.Separator)
+	for _, p := range m.Removed {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
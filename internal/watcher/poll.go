@@ -0,0 +1,230 @@
+package watcher
+
+import (
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/esakat/markdown-kb/internal/config"
+	"github.com/esakat/markdown-kb/internal/fswalk"
+	"github.com/esakat/markdown-kb/internal/ignore"
+	"github.com/esakat/markdown-kb/internal/parser"
+)
+
+// DefaultPollInterval is how often the tree is rescanned in polling mode.
+const DefaultPollInterval = 2 * time.Second
+
+// fileStat is what polling compares to tell that a file changed.
+type fileStat struct {
+	modTime time.Time
+	size    int64
+	doc     bool // a document, as opposed to a config or ignore file
+}
+
+// snapshot is the state of the tree as seen by one poll, keyed by absolute
+// path.
+type snapshot struct {
+	files map[string]fileStat
+	dirs  map[string]bool
+	git   map[string]fileStat // see gitFiles
+}
+
+// snapshot stats the documents, the config file, ignore files and
+// directories that the watcher would watch, and the git files.
+func (w *Watcher) snapshot() (snapshot, error) {
+	snap := snapshot{files: make(map[string]fileStat), dirs: make(map[string]bool), git: make(map[string]fileStat)}
+	if w.git != nil {
+		for name := range gitFiles {
+			path := filepath.Join(w.git.dir, name)
+			if info, err := os.Stat(path); err == nil {
+				snap.git[path] = fileStat{modTime: info.ModTime(), size: info.Size()}
+			}
+		}
+	}
+	err := fswalk.Walk(w.rootDir, w.walk, func(e fswalk.Entry) error {
+		if e.IsDir {
+			if w.ignore.IgnoredPath(e.RelPath, true) {
+				return fs.SkipDir
+			}
+			snap.dirs[e.AbsPath] = true
+			return nil
+		}
+
+		base := filepath.Base(e.RelPath)
+		doc := parser.HasExtension(e.RelPath, w.exts) && !w.ignore.IgnoredPath(e.RelPath, false)
+		meta := ignore.IsIgnoreFile(base) || (e.RelPath == base && config.IsRepoConfigFile(base))
+		if !doc && !meta {
+			return nil
+		}
+		info, err := os.Stat(e.AbsPath)
+		if err != nil {
+			return nil
+		}
+		snap.files[e.AbsPath] = fileStat{modTime: info.ModTime(), size: info.Size(), doc: doc}
+		return nil
+	})
+	return snap, err
+}
+
+// startPolling takes the first snapshot and rescans every poll interval,
+// feeding differences to d.
+func (w *Watcher) startPolling(d *dispatcher) error {
+	prev, err := w.snapshot()
+	if err != nil {
+		return err
+	}
+	var docs []string
+	for path, st := range prev.files {
+		if st.doc {
+			docs = append(docs, path)
+		}
+	}
+	w.remember(docs)
+
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.done:
+				d.stop()
+				return
+			case <-ticker.C:
+				next, err := w.snapshot()
+				if err != nil {
+					continue
+				}
+				w.diff(d, prev, next)
+				prev = next
+			}
+		}
+	}()
+	return nil
+}
+
+// diff reports the changes between two snapshots. Directories that
+// disappeared are reported as renamed when a new directory holds the same
+// documents, and as removed otherwise; their documents are not reported
+// one by one.
+func (w *Watcher) diff(d *dispatcher, prev, next snapshot) {
+	if !maps.Equal(prev.git, next.git) {
+		d.gitActivity()
+	}
+	for path, st := range next.files {
+		if old, ok := prev.files[path]; !st.doc && (!ok || old != st) {
+			w.metaChanged(d, path)
+		}
+	}
+	for path, st := range prev.files {
+		if _, ok := next.files[path]; !st.doc && !ok {
+			w.metaChanged(d, path)
+		}
+	}
+
+	var covered []string // directories whose documents were reported
+	under := func(path string) bool {
+		for _, dir := range covered {
+			if strings.HasPrefix(path, dir+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	added := topDirs(next.dirs, prev.dirs)
+	for _, gone := range topDirs(prev.dirs, next.dirs) {
+		// A directory that is now ignored still exists; leave its
+		// documents in place as the fsnotify watcher would.
+		if _, err := os.Lstat(gone); err == nil {
+			covered = append(covered, gone)
+			continue
+		}
+		d.cancelUnder(gone)
+		covered = append(covered, gone)
+
+		docs := docsUnder(prev, gone)
+		i := slices.IndexFunc(added, func(dir string) bool {
+			return len(docs) > 0 && slices.Equal(docs, docsUnder(next, dir))
+		})
+		if i < 0 {
+			d.handle(Event{Op: DirRemoved, Path: d.relPath(gone)})
+			continue
+		}
+
+		to := added[i]
+		added = slices.Delete(added, i, i+1)
+		covered = append(covered, to)
+		moved := make([]string, len(docs))
+		for i, rel := range docs {
+			moved[i] = filepath.Join(to, rel)
+		}
+		w.remember(moved)
+		d.handle(Event{Op: DirRenamed, Path: d.relPath(to), From: d.relPath(gone)})
+		// Documents edited since the last poll are reported as well.
+		for _, rel := range docs {
+			if prev.files[filepath.Join(gone, rel)] != next.files[filepath.Join(to, rel)] {
+				d.schedule(filepath.Join(to, rel))
+			}
+		}
+	}
+
+	for path, st := range next.files {
+		if old, ok := prev.files[path]; st.doc && (!ok || old != st) && !under(path) {
+			d.schedule(path)
+		}
+	}
+	for path, st := range prev.files {
+		if _, ok := next.files[path]; st.doc && !ok && !under(path) {
+			// Documents that became ignored still exist and are left alone.
+			if _, err := os.Lstat(path); os.IsNotExist(err) {
+				d.schedule(path)
+			}
+		}
+	}
+}
+
+// metaChanged handles a change to the config file or an ignore file.
+func (w *Watcher) metaChanged(d *dispatcher, path string) {
+	rel := d.relPath(path)
+	if filepath.Dir(rel) == "." && config.IsRepoConfigFile(rel) {
+		d.configChanged(rel)
+		return
+	}
+	// Takes effect from the next poll
+	w.ignore.Invalidate(filepath.Dir(rel))
+}
+
+// topDirs returns the directories in a but not in b whose parents are not
+// among them either, sorted.
+func topDirs(a, b map[string]bool) []string {
+	var dirs []string
+	for dir := range a {
+		if b[dir] {
+			continue
+		}
+		if parent := filepath.Dir(dir); a[parent] && !b[parent] {
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	slices.Sort(dirs)
+	return dirs
+}
+
+// docsUnder returns the sorted paths, relative to dir, of the documents
+// below dir in snap.
+func docsUnder(snap snapshot, dir string) []string {
+	prefix := dir + string(filepath.Separator)
+	var docs []string
+	for path, st := range snap.files {
+		if st.doc && strings.HasPrefix(path, prefix) {
+			docs = append(docs, path[len(prefix):])
+		}
+	}
+	slices.Sort(docs)
+	return docs
+}
@@ -1,6 +1,8 @@
 package watcher
 
 import (
+	"crypto/sha256"
+	"errors"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -8,22 +10,105 @@ import (
 	"sync"
 	"time"
 
+	"github.com/esakat/markdown-kb/internal/config"
+	"github.com/esakat/markdown-kb/internal/fswalk"
+	"github.com/esakat/markdown-kb/internal/ignore"
+	"github.com/esakat/markdown-kb/internal/parser"
 	"github.com/fsnotify/fsnotify"
 )
 
-// skipDirs are directories that should not be watched.
-var skipDirs = map[string]bool{
-	".git":         true,
-	".svn":         true,
-	".hg":          true,
-	"node_modules": true,
+// Options controls which paths the watcher reports.
+type Options struct {
+	// Ignore decides which paths are skipped. When nil, a matcher with
+	// the built-in rules plus .gitignore and .kbignore files is used.
+	Ignore *ignore.Matcher
+	// Extensions lists the accepted document extensions. When empty,
+	// parser.DefaultExtensions is used.
+	Extensions []string
+	// FollowSymlinks makes the watcher also watch directories reached
+	// through symbolic links, as scanner.Options.FollowSymlinks does.
+	FollowSymlinks bool
+	// SymlinkRoots lists additional absolute directories that followed
+	// links may point into.
+	SymlinkRoots []string
+	// Poll detects changes by rescanning the tree every PollInterval and
+	// comparing modification times and sizes, for file systems where
+	// fsnotify misses events (network file systems, container bind
+	// mounts).
+	Poll bool
+	// PollInterval is the rescan interval in polling mode. When zero,
+	// DefaultPollInterval is used.
+	PollInterval time.Duration
+	// OnFallback, if non-nil, is called when fsnotify can't start and the
+	// watcher falls back to polling.
+	OnFallback func(err error)
+}
+
+// Op is the kind of change an Event reports.
+type Op int
+
+const (
+	// FileChanged reports that a document file was modified.
+	FileChanged Op = iota
+	// FileCreated reports a new document file.
+	FileCreated
+	// FileRemoved reports that a document file was removed.
+	FileRemoved
+	// FileRenamed reports that a document file was moved from Event.From
+	// to Event.Path with its content unchanged.
+	FileRenamed
+	// DirRemoved reports that a directory was removed or moved out of the
+	// tree. Documents under it are gone.
+	DirRemoved
+	// DirRenamed reports that a directory was moved within the tree from
+	// Event.From to Event.Path.
+	DirRenamed
+	// ConfigChanged reports that the repository config file
+	// (.markdown-kb.yml) at the root was created, edited or removed.
+	ConfigChanged
+	// HeadMoved reports that a checkout, pull, reset, rebase or commit
+	// moved HEAD. Event.Head lists the documents it changed, which are not
+	// reported one by one.
+	HeadMoved
+)
+
+// Event is a change reported by Watch. Paths are relative to the root.
+type Event struct {
+	Op   Op
+	Path string
+	From string    // previous path, for FileRenamed and DirRenamed
+	Head *HeadMove // for HeadMoved
+}
+
+// pairWindow is how long a removed or created file waits for its
+// counterpart with the same content before it is reported on its own
+// rather than as a rename.
+const pairWindow = 100 * time.Millisecond
+
+// fileSum is the content hash of a known document. Documents found when
+// the watcher starts are hashed in the background; hashed is false until
+// then.
+type fileSum struct {
+	sum    [sha256.Size]byte
+	hashed bool
 }
 
 // Watcher monitors the file system for changes to Markdown files
 // and triggers re-indexing.
 type Watcher struct {
 	rootDir  string
+	ignore   *ignore.Matcher
+	exts     []string
+	walk     fswalk.Options
 	fsw      *fsnotify.Watcher
+	watched  map[string]bool // directories added to fsw; used by the loop only
+	poll     bool
+	interval time.Duration
+	fallback func(err error)
+	git      *gitState
+	sumsMu   sync.Mutex
+	sums     map[string]fileSum // known documents, by absolute path
+	readFile func(name string) ([]byte, error)
 	done     chan struct{}
 	stopped  bool
 	stopOnce sync.Once
@@ -31,15 +116,85 @@ type Watcher struct {
 
 // New creates a new file watcher for the given directory.
 func New(rootDir string) *Watcher {
+	return NewWithOptions(rootDir, Options{})
+}
+
+// NewWithOptions creates a new file watcher that applies opts.
+func NewWithOptions(rootDir string, opts Options) *Watcher {
+	// Events carry absolute paths; relative ones are resolved against an
+	// absolute root.
+	if abs, err := filepath.Abs(rootDir); err == nil {
+		rootDir = abs
+	}
+	matcher := opts.Ignore
+	if matcher == nil {
+		matcher = ignore.New(rootDir, nil, nil)
+	}
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
 	return &Watcher{
-		rootDir: rootDir,
-		done:    make(chan struct{}),
+		rootDir:  rootDir,
+		ignore:   matcher,
+		exts:     parser.NormalizeExtensions(opts.Extensions),
+		walk:     fswalk.Options{FollowSymlinks: opts.FollowSymlinks, AllowedRoots: opts.SymlinkRoots},
+		watched:  make(map[string]bool),
+		sums:     make(map[string]fileSum),
+		readFile: os.ReadFile,
+		poll:     opts.Poll,
+		interval: interval,
+		fallback: opts.OnFallback,
+		done:     make(chan struct{}),
 	}
 }
 
 // Start begins watching for file changes. onChange is called with the
-// relative path of the changed .md file. Events are debounced per file.
+// relative path of the changed document file; a rename reports both paths.
+// Events are debounced per file. Directory events are not reported; use
+// Watch for those.
 func (w *Watcher) Start(onChange func(path string)) error {
+	return w.Watch(func(e Event) {
+		switch e.Op {
+		case FileRenamed:
+			onChange(e.From)
+			onChange(e.Path)
+		case FileChanged, FileCreated, FileRemoved:
+			onChange(e.Path)
+		case HeadMoved:
+			for _, path := range e.Head.paths() {
+				onChange(path)
+			}
+		}
+	})
+}
+
+// Watch begins watching for changes and calls handle for each one. File
+// changes are debounced per file. The watcher remembers a hash of every
+// document so that a file removed and another with the same content created
+// within the debounce window are reported as one FileRenamed; the hashes of
+// existing documents are computed in the background after Watch returns. Removing or
+// moving a directory yields a single DirRemoved or DirRenamed event instead
+// of events for the files in it. When the root is in a git repository,
+// document changes made while git updates the working tree are reported as
+// one HeadMoved event. If fsnotify can't start, the watcher polls instead.
+func (w *Watcher) Watch(handle func(Event)) error {
+	w.initGit()
+	d := newDispatcher(w, handle)
+	if !w.poll {
+		err := w.startNotify(d)
+		if err == nil {
+			return nil
+		}
+		if w.fallback != nil {
+			w.fallback(err)
+		}
+	}
+	return w.startPolling(d)
+}
+
+// startNotify watches the tree with fsnotify.
+func (w *Watcher) startNotify(d *dispatcher) error {
 	fsw, err := fsnotify.NewWatcher()
 	if err != nil {
 		return err
@@ -47,12 +202,20 @@ func (w *Watcher) Start(onChange func(path string)) error {
 	w.fsw = fsw
 
 	// Add root and all subdirectories
-	if err := w.addDirs(w.rootDir); err != nil {
+	var docs []string
+	if err := w.addDirs(w.rootDir, func(doc string) { docs = append(docs, doc) }); err != nil {
 		fsw.Close()
+		w.fsw = nil
+		w.watched = make(map[string]bool)
 		return err
 	}
+	w.remember(docs)
+	if w.git != nil {
+		// Best effort; without it checkouts are reported file by file.
+		fsw.Add(w.git.dir)
+	}
 
-	go w.loop(onChange)
+	go w.loop(d)
 
 	return nil
 }
@@ -67,48 +230,133 @@ func (w *Watcher) Stop() {
 	})
 }
 
-func (w *Watcher) addDirs(root string) error {
-	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
+// addDirs watches root and its subdirectories. onFile, if non-nil, is
+// called with the absolute path of every document file found.
+func (w *Watcher) addDirs(root string, onFile func(absPath string)) error {
+	rootRel, err := filepath.Rel(w.rootDir, root)
+	if err != nil {
+		return nil
+	}
+	if rootRel != "." && w.ignore.IgnoredPath(rootRel, true) {
+		return nil
+	}
+	if fi, err := os.Lstat(root); err != nil || (fi.Mode()&fs.ModeSymlink != 0 && !w.walk.FollowSymlinks) {
+		return nil
+	}
+	if err := w.fsw.Add(root); err != nil {
+		return err
+	}
+	w.watched[root] = true
+
+	// Linked directories are added under their link path, so events for
+	// files in link targets carry paths inside rootDir.
+	return fswalk.Walk(root, w.walk, func(e fswalk.Entry) error {
+		rel := filepath.Join(rootRel, e.RelPath)
+		if !e.IsDir {
+			if onFile != nil && parser.HasExtension(e.RelPath, w.exts) && !w.ignore.IgnoredPath(rel, false) {
+				onFile(e.AbsPath)
+			}
 			return nil
 		}
-		if d.IsDir() {
-			name := d.Name()
-			if path != root && (skipDirs[name] || strings.HasPrefix(name, ".")) {
-				return fs.SkipDir
-			}
-			return w.fsw.Add(path)
+		if w.ignore.IgnoredPath(rel, true) {
+			return fs.SkipDir
+		}
+		if err := w.fsw.Add(e.AbsPath); err != nil {
+			return err
 		}
+		w.watched[e.AbsPath] = true
 		return nil
 	})
 }
 
-func (w *Watcher) loop(onChange func(path string)) {
-	// Debounce: collect events per file, fire after 300ms of silence
-	const debounce = 300 * time.Millisecond
-	pending := make(map[string]*time.Timer)
-	var mu sync.Mutex
+// remember records the documents at paths as known and hashes them in
+// the background, so that a large tree doesn't hold up the caller.
+func (w *Watcher) remember(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	w.sumsMu.Lock()
+	for _, path := range paths {
+		if _, ok := w.sums[path]; !ok {
+			w.sums[path] = fileSum{}
+		}
+	}
+	w.sumsMu.Unlock()
+
+	go func() {
+		for _, path := range paths {
+			select {
+			case <-w.done:
+				return
+			default:
+			}
+			data, err := w.readFile(path)
+			if err != nil {
+				continue
+			}
+			w.sumsMu.Lock()
+			// A change seen meanwhile has already rehashed the file.
+			if s, ok := w.sums[path]; ok && !s.hashed {
+				w.sums[path] = fileSum{sum: sha256.Sum256(data), hashed: true}
+			}
+			w.sumsMu.Unlock()
+		}
+	}()
+}
+
+// rehash records the content hash of the document at path and returns it
+// with what was known about the file before. exists is false if the file
+// is gone.
+func (w *Watcher) rehash(path string) (sum [sha256.Size]byte, old fileSum, known, exists bool) {
+	data, err := w.readFile(path)
 
-	fire := func(path string) {
-		mu.Lock()
-		delete(pending, path)
-		mu.Unlock()
+	w.sumsMu.Lock()
+	defer w.sumsMu.Unlock()
+	old, known = w.sums[path]
+	if err != nil {
+		delete(w.sums, path)
+		return sum, old, known, !errors.Is(err, fs.ErrNotExist)
+	}
+	sum = sha256.Sum256(data)
+	w.sums[path] = fileSum{sum: sum, hashed: true}
+	return sum, old, known, true
+}
+
+// forgetDir drops the hashes of documents under dir.
+func (w *Watcher) forgetDir(dir string) {
+	prefix := dir + string(filepath.Separator)
+	w.sumsMu.Lock()
+	defer w.sumsMu.Unlock()
+	for path := range w.sums {
+		if strings.HasPrefix(path, prefix) {
+			delete(w.sums, path)
+		}
+	}
+}
 
-		rel, err := filepath.Rel(w.rootDir, path)
-		if err != nil {
-			rel = path
+// unwatch drops the watches on dir and the directories below it. It
+// reports false if dir isn't a watched directory.
+func (w *Watcher) unwatch(dir string) bool {
+	if !w.watched[dir] {
+		return false
+	}
+	prefix := dir + string(filepath.Separator)
+	for d := range w.watched {
+		if d == dir || strings.HasPrefix(d, prefix) {
+			// The kernel may already have dropped the watch.
+			w.fsw.Remove(d)
+			delete(w.watched, d)
 		}
-		onChange(rel)
 	}
+	return true
+}
 
+// loop turns fsnotify events into changes for d until the watcher stops.
+func (w *Watcher) loop(d *dispatcher) {
 	for {
 		select {
 		case <-w.done:
-			mu.Lock()
-			for _, t := range pending {
-				t.Stop()
-			}
-			mu.Unlock()
+			d.stop()
 			return
 
 		case event, ok := <-w.fsw.Events:
@@ -117,29 +365,76 @@ func (w *Watcher) loop(onChange func(path string)) {
 			}
 
 			path := event.Name
+			rel, err := filepath.Rel(w.rootDir, path)
+			if err != nil {
+				continue
+			}
+
+			if w.isGitFile(path) {
+				d.gitActivity()
+				continue
+			}
 
-			// If a new directory is created, watch it recursively
+			// The config file is debounced on its own
+			if filepath.Dir(rel) == "." && config.IsRepoConfigFile(rel) {
+				d.configChanged(rel)
+				continue
+			}
+
+			// Edited ignore files take effect for later events
+			if ignore.IsIgnoreFile(filepath.Base(path)) {
+				w.ignore.Invalidate(filepath.Dir(rel))
+				continue
+			}
+
+			// A watched directory was removed or moved. A move is reported
+			// as a rename if its new name shows up within the debounce
+			// window, and as a removal otherwise.
+			if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				if w.unwatch(path) {
+					if event.Has(fsnotify.Rename) {
+						d.movedAway(path)
+					} else {
+						d.cancelUnder(path)
+						d.handle(Event{Op: DirRemoved, Path: rel})
+					}
+					continue
+				}
+			}
+
+			// If a new directory is created, watch it recursively. A
+			// directory that arrives with content (moved in or copied)
+			// reports its documents, unless it completes a move within the
+			// tree.
 			if event.Has(fsnotify.Create) {
 				if info, err := os.Stat(path); err == nil && info.IsDir() {
-					w.addDirs(path)
+					from, moved := d.claimMove()
+					if moved && !w.ignore.IgnoredPath(rel, true) {
+						var docs []string
+						w.addDirs(path, func(doc string) { docs = append(docs, doc) })
+						w.remember(docs)
+						d.handle(Event{Op: DirRenamed, Path: rel, From: from})
+						continue
+					}
+					if moved {
+						d.handle(Event{Op: DirRemoved, Path: from})
+					}
+					w.addDirs(path, d.schedule)
 					continue
 				}
 			}
 
-			// Only care about .md files
-			if filepath.Ext(path) != ".md" {
+			// Only care about document files
+			if !parser.HasExtension(path, w.exts) {
 				continue
 			}
 
-			// Debounce per file
-			mu.Lock()
-			if t, ok := pending[path]; ok {
-				t.Reset(debounce)
-			} else {
-				p := path
-				pending[path] = time.AfterFunc(debounce, func() { fire(p) })
+			if w.ignore.IgnoredPath(rel, false) {
+				continue
 			}
-			mu.Unlock()
+
+			// Debounce per file
+			d.schedule(path)
 
 		case _, ok := <-w.fsw.Errors:
 			if !ok {
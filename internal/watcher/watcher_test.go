@@ -1,8 +1,12 @@
 package watcher
 
 import (
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -150,6 +154,102 @@ func TestWatcher_IgnoresNonMd(t *testing.T) {
 	}
 }
 
+func TestWatcher_IgnoresIgnoredPaths(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "build"), 0755)
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("build/\nscratch.md\n"), 0644)
+
+	w := New(dir)
+	var mu sync.Mutex
+	var events []string
+	err := w.Start(func(path string) {
+		mu.Lock()
+		events = append(events, path)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer w.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	os.WriteFile(filepath.Join(dir, "build", "out.md"), []byte("# Out"), 0644)
+	os.WriteFile(filepath.Join(dir, "scratch.md"), []byte("# Scratch"), 0644)
+	os.WriteFile(filepath.Join(dir, "kept.md"), []byte("# Kept"), 0644)
+
+	time.Sleep(600 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 1 || filepath.Base(events[0]) != "kept.md" {
+		t.Errorf("expected only kept.md event, got %v", events)
+	}
+}
+
+func TestWatcher_Extensions(t *testing.T) {
+	dir := t.TempDir()
+
+	w := NewWithOptions(dir, Options{Extensions: []string{".mdx", ".markdown"}})
+	var mu sync.Mutex
+	var events []string
+	err := w.Start(func(path string) {
+		mu.Lock()
+		events = append(events, path)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer w.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	os.WriteFile(filepath.Join(dir, "page.mdx"), []byte("# Page"), 0644)
+	os.WriteFile(filepath.Join(dir, "plain.md"), []byte("# Plain"), 0644)
+
+	time.Sleep(600 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 1 || events[0] != "page.mdx" {
+		t.Errorf("expected only page.mdx event, got %v", events)
+	}
+}
+
+func TestWatcher_FollowSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	shared := t.TempDir()
+	if err := os.Symlink(shared, filepath.Join(dir, "shared")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	w := NewWithOptions(dir, Options{FollowSymlinks: true, SymlinkRoots: []string{shared}})
+	var mu sync.Mutex
+	var events []string
+	err := w.Start(func(path string) {
+		mu.Lock()
+		events = append(events, path)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer w.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	os.WriteFile(filepath.Join(shared, "s.md"), []byte("# S"), 0644)
+
+	time.Sleep(600 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 1 || events[0] != filepath.Join("shared", "s.md") {
+		t.Errorf("expected shared/s.md event, got %v", events)
+	}
+}
+
 func TestWatcher_DetectsSubdirectory(t *testing.T) {
 	dir := t.TempDir()
 
@@ -191,6 +291,279 @@ func TestWatcher_DetectsSubdirectory(t *testing.T) {
 	}
 }
 
+// watchEvents starts w and returns a function reporting the events seen
+// so far.
+func watchEvents(t *testing.T, w *Watcher) func() []Event {
+	t.Helper()
+	var mu sync.Mutex
+	var events []Event
+	if err := w.Watch(func(e Event) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	}); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	t.Cleanup(w.Stop)
+	return func() []Event {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Event(nil), events...)
+	}
+}
+
+func TestWatcher_DirectoryRemoved(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "notes", "deep"), 0755)
+	os.WriteFile(filepath.Join(dir, "notes", "a.md"), []byte("# A"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes", "deep", "b.md"), []byte("# B"), 0644)
+
+	events := watchEvents(t, New(dir))
+	time.Sleep(100 * time.Millisecond)
+
+	os.RemoveAll(filepath.Join(dir, "notes"))
+	time.Sleep(600 * time.Millisecond)
+
+	var removed []string
+	for _, e := range events() {
+		if e.Op == FileChanged {
+			t.Errorf("unexpected file event %+v", e)
+		}
+		if e.Op == DirRemoved {
+			removed = append(removed, e.Path)
+		}
+	}
+	// The subdirectory may be reported before its parent; the parent must be.
+	if len(removed) == 0 || removed[len(removed)-1] != "notes" {
+		t.Errorf("DirRemoved events = %v, want notes last", removed)
+	}
+}
+
+func TestWatcher_DirectoryRenamed(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "old"), 0755)
+	os.WriteFile(filepath.Join(dir, "old", "a.md"), []byte("# A"), 0644)
+
+	events := watchEvents(t, New(dir))
+	time.Sleep(100 * time.Millisecond)
+
+	os.Rename(filepath.Join(dir, "old"), filepath.Join(dir, "new"))
+	time.Sleep(200 * time.Millisecond)
+	// The moved directory stays watched under its new name.
+	os.WriteFile(filepath.Join(dir, "new", "b.md"), []byte("# B"), 0644)
+	time.Sleep(600 * time.Millisecond)
+
+	got := events()
+	if len(got) == 0 || got[0] != (Event{Op: DirRenamed, Path: "new", From: "old"}) {
+		t.Fatalf("events = %+v, want DirRenamed old -> new first", got)
+	}
+	found := false
+	for _, e := range got[1:] {
+		if e.Op != FileCreated {
+			t.Errorf("unexpected event %+v", e)
+		}
+		if e.Path == filepath.Join("new", "b.md") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected event for new/b.md, got %+v", got)
+	}
+}
+
+func TestWatcher_DirectoryMovedOut(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "notes"), 0755)
+	os.WriteFile(filepath.Join(dir, "notes", "a.md"), []byte("# A"), 0644)
+
+	events := watchEvents(t, New(dir))
+	time.Sleep(100 * time.Millisecond)
+
+	os.Rename(filepath.Join(dir, "notes"), filepath.Join(outside, "notes"))
+	time.Sleep(600 * time.Millisecond)
+
+	got := events()
+	if len(got) != 1 || got[0] != (Event{Op: DirRemoved, Path: "notes"}) {
+		t.Errorf("events = %+v, want a single DirRemoved notes", got)
+	}
+}
+
+func TestWatcher_DirectoryMovedIn(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	os.MkdirAll(filepath.Join(outside, "notes"), 0755)
+	os.WriteFile(filepath.Join(outside, "notes", "a.md"), []byte("# A"), 0644)
+
+	events := watchEvents(t, New(dir))
+	time.Sleep(100 * time.Millisecond)
+
+	os.Rename(filepath.Join(outside, "notes"), filepath.Join(dir, "notes"))
+	time.Sleep(600 * time.Millisecond)
+
+	got := events()
+	if len(got) != 1 || got[0] != (Event{Op: FileCreated, Path: filepath.Join("notes", "a.md")}) {
+		t.Errorf("events = %+v, want a FileCreated for notes/a.md", got)
+	}
+}
+
+func TestWatcher_FileOps(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "exist.md"), []byte("# Old"), 0644)
+	os.WriteFile(filepath.Join(dir, "gone.md"), []byte("# Gone"), 0644)
+
+	events := watchEvents(t, New(dir))
+	time.Sleep(100 * time.Millisecond)
+
+	os.WriteFile(filepath.Join(dir, "exist.md"), []byte("# Updated"), 0644)
+	os.WriteFile(filepath.Join(dir, "new.md"), []byte("# New"), 0644)
+	os.Remove(filepath.Join(dir, "gone.md"))
+	time.Sleep(600 * time.Millisecond)
+
+	want := map[string]Op{"exist.md": FileChanged, "new.md": FileCreated, "gone.md": FileRemoved}
+	got := events()
+	if len(got) != len(want) {
+		t.Fatalf("events = %+v, want one per file", got)
+	}
+	for _, e := range got {
+		if want[e.Path] != e.Op {
+			t.Errorf("%s: op = %v, want %v", e.Path, e.Op, want[e.Path])
+		}
+	}
+}
+
+func TestWatcher_FileRenamed(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.md"), []byte("# A"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.md"), []byte("# B"), 0644)
+
+	events := watchEvents(t, New(dir))
+	time.Sleep(100 * time.Millisecond)
+
+	os.Rename(filepath.Join(dir, "a.md"), filepath.Join(dir, "sub", "a.md"))
+	// Content is matched, not the order of events.
+	os.Rename(filepath.Join(dir, "b.md"), filepath.Join(dir, "b2.md"))
+	time.Sleep(600 * time.Millisecond)
+
+	got := events()
+	want := map[Event]bool{
+		{Op: FileRenamed, Path: filepath.Join("sub", "a.md"), From: "a.md"}: true,
+		{Op: FileRenamed, Path: "b2.md", From: "b.md"}:                      true,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("events = %+v, want two renames", got)
+	}
+	for _, e := range got {
+		if !want[e] {
+			t.Errorf("unexpected event %+v", e)
+		}
+	}
+}
+
+func TestWatcher_CopyIsNotRename(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.md"), []byte("# A"), 0644)
+
+	events := watchEvents(t, New(dir))
+	time.Sleep(100 * time.Millisecond)
+
+	os.WriteFile(filepath.Join(dir, "copy.md"), []byte("# A"), 0644)
+	time.Sleep(600 * time.Millisecond)
+
+	got := events()
+	if len(got) != 1 || got[0] != (Event{Op: FileCreated, Path: "copy.md"}) {
+		t.Errorf("events = %+v, want a FileCreated for copy.md", got)
+	}
+}
+
+func TestWatcher_ConfigChanged(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+
+	events := watchEvents(t, New(dir))
+	time.Sleep(100 * time.Millisecond)
+
+	os.WriteFile(filepath.Join(dir, ".markdown-kb.yml"), []byte("title: A\n"), 0644)
+	os.WriteFile(filepath.Join(dir, ".markdown-kb.yml"), []byte("title: B\n"), 0644)
+	// Only the root config counts.
+	os.WriteFile(filepath.Join(dir, "sub", ".markdown-kb.yml"), []byte("title: C\n"), 0644)
+	time.Sleep(600 * time.Millisecond)
+
+	got := events()
+	if len(got) != 1 || got[0] != (Event{Op: ConfigChanged, Path: ".markdown-kb.yml"}) {
+		t.Errorf("events = %+v, want a single ConfigChanged", got)
+	}
+}
+
+func TestWatcher_Poll(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "old"), 0755)
+	os.WriteFile(filepath.Join(dir, "exist.md"), []byte("# Old"), 0644)
+	os.WriteFile(filepath.Join(dir, "gone.md"), []byte("# Gone"), 0644)
+	os.WriteFile(filepath.Join(dir, "old", "a.md"), []byte("# A"), 0644)
+
+	events := watchEvents(t, NewWithOptions(dir, Options{Poll: true, PollInterval: 50 * time.Millisecond}))
+
+	os.WriteFile(filepath.Join(dir, "exist.md"), []byte("# Updated"), 0644)
+	os.WriteFile(filepath.Join(dir, "new.md"), []byte("# New"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a document"), 0644)
+	os.Remove(filepath.Join(dir, "gone.md"))
+	os.Rename(filepath.Join(dir, "old"), filepath.Join(dir, "new"))
+	os.WriteFile(filepath.Join(dir, ".markdown-kb.yml"), []byte("title: X\n"), 0644)
+	time.Sleep(800 * time.Millisecond)
+
+	want := map[Event]bool{
+		{Op: FileChanged, Path: "exist.md"}:           true,
+		{Op: FileCreated, Path: "new.md"}:             true,
+		{Op: FileRemoved, Path: "gone.md"}:            true,
+		{Op: DirRenamed, Path: "new", From: "old"}:    true,
+		{Op: ConfigChanged, Path: ".markdown-kb.yml"}: true,
+	}
+	got := events()
+	if len(got) != len(want) {
+		t.Errorf("events = %+v, want %d", got, len(want))
+	}
+	for _, e := range got {
+		if !want[e] {
+			t.Errorf("unexpected event %+v", e)
+		}
+	}
+}
+
+func TestWatcher_PollDirectoryRemoved(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "notes", "deep"), 0755)
+	os.WriteFile(filepath.Join(dir, "notes", "deep", "a.md"), []byte("# A"), 0644)
+
+	events := watchEvents(t, NewWithOptions(dir, Options{Poll: true, PollInterval: 50 * time.Millisecond}))
+
+	os.RemoveAll(filepath.Join(dir, "notes"))
+	time.Sleep(300 * time.Millisecond)
+
+	got := events()
+	if len(got) != 1 || got[0] != (Event{Op: DirRemoved, Path: "notes"}) {
+		t.Errorf("events = %+v, want a single DirRemoved notes", got)
+	}
+}
+
+func TestWatcher_RelativeRoot(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	t.Chdir(dir)
+
+	events := watchEvents(t, New("."))
+	time.Sleep(100 * time.Millisecond)
+
+	os.WriteFile(filepath.Join(dir, "sub", "a.md"), []byte("# A"), 0644)
+	time.Sleep(600 * time.Millisecond)
+
+	got := events()
+	if len(got) != 1 || got[0] != (Event{Op: FileCreated, Path: filepath.Join("sub", "a.md")}) {
+		t.Errorf("events = %+v, want a FileCreated for sub/a.md", got)
+	}
+}
+
 func TestWatcher_Stop(t *testing.T) {
 	dir := t.TempDir()
 
@@ -206,3 +579,138 @@ func TestWatcher_Stop(t *testing.T) {
 	// Should not panic on double stop
 	w.Stop()
 }
+
+// gitRun runs git in dir.
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test",
+		"GIT_AUTHOR_EMAIL=test@test.com",
+		"GIT_COMMITTER_NAME=Test",
+		"GIT_COMMITTER_EMAIL=test@test.com",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newGitTree creates a repository whose main branch has a.md and b.md and
+// whose "other" branch modifies a.md, removes b.md and adds c.md. main is
+// checked out. It returns the directory and both commits.
+func newGitTree(t *testing.T) (dir, mainCommit, otherCommit string) {
+	t.Helper()
+	dir = t.TempDir()
+	gitRun(t, dir, "init", "-b", "main")
+	os.WriteFile(filepath.Join(dir, "a.md"), []byte("# A"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.md"), []byte("# B"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0644)
+	gitRun(t, dir, "add", ".")
+	gitRun(t, dir, "commit", "-m", "initial")
+	mainCommit = gitRun(t, dir, "rev-parse", "HEAD")
+
+	gitRun(t, dir, "checkout", "-q", "-b", "other")
+	os.WriteFile(filepath.Join(dir, "a.md"), []byte("# A\n\nChanged."), 0644)
+	os.WriteFile(filepath.Join(dir, "c.md"), []byte("# C"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("more notes"), 0644)
+	gitRun(t, dir, "rm", "-q", "b.md")
+	gitRun(t, dir, "add", ".")
+	gitRun(t, dir, "commit", "-m", "other")
+	otherCommit = gitRun(t, dir, "rev-parse", "HEAD")
+	gitRun(t, dir, "checkout", "-q", "main")
+	return dir, mainCommit, otherCommit
+}
+
+func TestWatcher_GitCheckout(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		t.Run(fmt.Sprintf("poll=%v", poll), func(t *testing.T) {
+			dir, mainCommit, otherCommit := newGitTree(t)
+			events := watchEvents(t, NewWithOptions(dir, Options{Poll: poll, PollInterval: 100 * time.Millisecond}))
+			time.Sleep(100 * time.Millisecond)
+
+			gitRun(t, dir, "checkout", "-q", "other")
+			time.Sleep(time.Second)
+
+			got := events()
+			if len(got) != 1 || got[0].Op != HeadMoved {
+				t.Fatalf("events = %+v, want one HeadMoved", got)
+			}
+			want := HeadMove{
+				From:     mainCommit,
+				To:       otherCommit,
+				Added:    []string{"c.md"},
+				Modified: []string{"a.md"},
+				Removed:  []string{"b.md"},
+			}
+			if !reflect.DeepEqual(*got[0].Head, want) {
+				t.Errorf("Head = %+v, want %+v", *got[0].Head, want)
+			}
+		})
+	}
+}
+
+func TestWatcher_GitCommit(t *testing.T) {
+	dir, _, _ := newGitTree(t)
+	events := watchEvents(t, New(dir))
+	time.Sleep(100 * time.Millisecond)
+
+	os.WriteFile(filepath.Join(dir, "a.md"), []byte("# A\n\nEdited."), 0644)
+	time.Sleep(600 * time.Millisecond)
+	gitRun(t, dir, "commit", "-q", "-am", "edit")
+	time.Sleep(time.Second)
+
+	// The edit is reported once; committing it moves HEAD but changes
+	// nothing on disk.
+	got := events()
+	if len(got) != 2 || got[0] != (Event{Op: FileChanged, Path: "a.md"}) ||
+		got[1].Op != HeadMoved || !got[1].Head.Empty() {
+		t.Errorf("events = %+v, want FileChanged for a.md and an empty HeadMoved", got)
+	}
+}
+
+func TestWatcher_WatchDoesNotReadDocuments(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		t.Run(fmt.Sprintf("poll=%v", poll), func(t *testing.T) {
+			dir := t.TempDir()
+			os.WriteFile(filepath.Join(dir, "a.md"), []byte("# A"), 0644)
+			os.WriteFile(filepath.Join(dir, "b.md"), []byte("# B"), 0644)
+
+			w := NewWithOptions(dir, Options{Poll: poll})
+			release := make(chan struct{})
+			read := make(chan string, 2)
+			w.readFile = func(name string) ([]byte, error) {
+				<-release
+				read <- name
+				return os.ReadFile(name)
+			}
+
+			done := make(chan error, 1)
+			go func() { done <- w.Watch(func(Event) {}) }()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("Watch: %v", err)
+				}
+			case <-time.After(2 * time.Second):
+				t.Error("Watch waited for documents to be read")
+				close(release)
+				<-done
+				return
+			}
+			defer w.Stop()
+
+			// The documents are hashed in the background.
+			close(release)
+			for range 2 {
+				select {
+				case <-read:
+				case <-time.After(2 * time.Second):
+					t.Fatal("documents were not hashed after Watch returned")
+				}
+			}
+		})
+	}
+}
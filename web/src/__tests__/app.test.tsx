@@ -1,6 +1,21 @@
 import { render, screen } from "@testing-library/preact";
-import { describe, it, expect } from "vitest";
+import { describe, it, expect, vi } from "vitest";
 import { App } from "../app";
+import type { WSEvent } from "../hooks/useWebSocket";
+
+let onEvent: ((event: WSEvent) => void) | undefined;
+
+vi.mock("../hooks/useWebSocket", async (importOriginal) => {
+  const actual =
+    await importOriginal<typeof import("../hooks/useWebSocket")>();
+  return {
+    ...actual,
+    useWebSocket: (options?: { onEvent?: (event: WSEvent) => void }) => {
+      onEvent = options?.onEvent;
+      return { connected: true };
+    },
+  };
+});
 
 describe("App", () => {
   it("renders the Markdown KB heading", () => {
@@ -12,4 +27,15 @@ describe("App", () => {
     render(<App />);
     expect(screen.getByText("Welcome")).toBeTruthy();
   });
+
+  it("ignores indexing events", () => {
+    render(<App />);
+    expect(() => {
+      onEvent?.({
+        type: "index_progress",
+        progress: { phase: "scanning", done: 1, total: 2 },
+      } as WSEvent);
+      onEvent?.({ type: "index_complete" } as WSEvent);
+    }).not.toThrow();
+  });
 });
@@ -0,0 +1,28 @@
+import { describe, it, expect, afterEach } from "vitest";
+import {
+  matchExtension,
+  setExtensions,
+  stripExtension,
+} from "../lib/extensions";
+
+describe("extensions", () => {
+  afterEach(() => setExtensions(undefined));
+
+  it("matches the longest configured extension", () => {
+    const exts = [".md", ".txt", ".md.txt"];
+    expect(matchExtension("notes/a.md.txt", exts)).toBe(".md.txt");
+    expect(matchExtension("README.MD", exts)).toBe(".md");
+    expect(matchExtension(".md", exts)).toBe("");
+  });
+
+  it("strips the configured extension", () => {
+    setExtensions([".markdown", ".mdx"]);
+    expect(stripExtension("docs/guide.markdown")).toBe("docs/guide");
+    expect(stripExtension("docs/page.mdx")).toBe("docs/page");
+    expect(stripExtension("docs/plain.md")).toBe("docs/plain.md");
+  });
+
+  it("defaults to .md", () => {
+    expect(stripExtension("guide.md")).toBe("guide");
+  });
+});
@@ -2,6 +2,7 @@ import { render, screen, fireEvent } from "@testing-library/preact";
 import { describe, it, expect, vi } from "vitest";
 import { ToastContainer, createToast } from "../components/LiveReload/Toast";
 import type { ToastMessage } from "../components/LiveReload/Toast";
+import { movedPath, changeCount } from "../hooks/useWebSocket";
 
 describe("ToastContainer", () => {
   it("renders toast messages", () => {
@@ -64,3 +65,42 @@ describe("createToast", () => {
     expect(t.type).toBe("info");
   });
 });
+
+describe("movedPath", () => {
+  it("follows a renamed document", () => {
+    const event = {
+      type: "renamed" as const,
+      from: "a.md",
+      path: "sub/a.md",
+    };
+    expect(movedPath(event, "a.md")).toBe("sub/a.md");
+    expect(movedPath(event, "b.md")).toBeUndefined();
+  });
+
+  it("follows documents in a renamed directory", () => {
+    const event = {
+      type: "dir_renamed" as const,
+      from: "notes",
+      path: "archive/notes",
+    };
+    expect(movedPath(event, "notes/deep/a.md")).toBe(
+      "archive/notes/deep/a.md",
+    );
+    expect(movedPath(event, "notes.md")).toBeUndefined();
+  });
+
+  it("ignores other events", () => {
+    expect(
+      movedPath({ type: "updated", path: "a.md" }, "a.md"),
+    ).toBeUndefined();
+  });
+});
+
+describe("changeCount", () => {
+  it("counts the documents in a reindex", () => {
+    expect(
+      changeCount({ created: ["a.md"], updated: ["b.md"], deleted: ["c.md"] }),
+    ).toBe(3);
+    expect(changeCount(undefined)).toBe(0);
+  });
+});
@@ -44,4 +44,12 @@ describe("renderMarkdown", () => {
     const html = renderMarkdown("![alt](https://example.com/img.png)", "docs/guide.md");
     expect(html).toContain("https://example.com/img.png");
   });
+
+  it("leaves wiki-link extensions to the server", () => {
+    const html = renderMarkdown("See [[notes/page]] and [[guide.md|Guide]].");
+    expect(html).toContain('href="/docs/notes/page"');
+    expect(html).toContain(">page</a>");
+    expect(html).toContain('href="/docs/guide.md"');
+    expect(html).toContain(">Guide</a>");
+  });
 });
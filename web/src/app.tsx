@@ -6,7 +6,7 @@ import { Home } from "./pages/Home";
 import { DocumentPage } from "./pages/DocumentPage";
 import { SearchPage } from "./pages/SearchPage";
 import { GraphPage } from "./pages/GraphPage";
-import { useWebSocket } from "./hooks/useWebSocket";
+import { useWebSocket, movedPath, changeCount } from "./hooks/useWebSocket";
 import type { WSEvent } from "./hooks/useWebSocket";
 import { ToastContainer, createToast } from "./components/LiveReload/Toast";
 import type { ToastMessage } from "./components/LiveReload/Toast";
@@ -15,6 +15,7 @@ export function App() {
   const [currentPath, setCurrentPath] = useState<string | undefined>(undefined);
   const [toasts, setToasts] = useState<ToastMessage[]>([]);
   const [refreshKey, setRefreshKey] = useState(0);
+  const [configVersion, setConfigVersion] = useState(0);
 
   const addToast = useCallback(
     (text: string, type: "info" | "success" | "warning" = "info") => {
@@ -29,6 +30,20 @@ export function App() {
 
   const handleWSEvent = useCallback(
     (event: WSEvent) => {
+      if (event.type === "config") {
+        setConfigVersion((v) => v + 1);
+        return;
+      }
+      if (event.type === "index_progress" || event.type === "index_complete") {
+        // No document path; the initial index reports its own progress.
+        return;
+      }
+      if (event.type === "reindexed" || event.type === "batch") {
+        const verb = event.type === "reindexed" ? "Reindexed" : "Updated";
+        addToast(`${verb}: ${changeCount(event.changes)} documents`, "info");
+        setRefreshKey((k) => k + 1);
+        return;
+      }
       const fileName = event.path.split("/").pop() || event.path;
       switch (event.type) {
         case "created":
@@ -38,13 +53,23 @@ export function App() {
           addToast(`Updated: ${fileName}`, "info");
           break;
         case "deleted":
+        case "dir_deleted":
           addToast(`Deleted: ${fileName}`, "warning");
           break;
+        case "renamed":
+        case "dir_renamed":
+          addToast(`Moved: ${fileName}`, "info");
+          break;
+      }
+      // Follow the open document if it moved
+      const moved = currentPath && movedPath(event, currentPath);
+      if (moved) {
+        route(`/docs/${moved}`);
       }
       // Trigger re-render for data-dependent components
       setRefreshKey((k) => k + 1);
     },
-    [addToast],
+    [addToast, currentPath],
   );
 
   useWebSocket({ onEvent: handleWSEvent });
@@ -63,7 +88,11 @@ export function App() {
   }, []);
 
   return (
-    <Layout currentPath={currentPath} onSearch={handleSearch}>
+    <Layout
+      currentPath={currentPath}
+      onSearch={handleSearch}
+      configVersion={configVersion}
+    >
       <Router onChange={handleRoute}>
         <Home path="/" key={`home-${refreshKey}`} />
         <SearchPage path="/search" />
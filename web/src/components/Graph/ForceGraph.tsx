@@ -1,6 +1,7 @@
 import { useRef, useEffect } from "preact/hooks";
 import * as d3 from "d3";
 import type { GraphNode, GraphEdge } from "../../types/api";
+import { stripExtension } from "../../lib/extensions";
 import styles from "./ForceGraph.module.css";
 
 interface Props {
@@ -130,7 +131,7 @@ export function ForceGraph({ nodes, edges, onNodeClick }: Props) {
 
     node
       .append("text")
-      .text((d) => d.title || d.path.replace(/\.md$/, ""))
+      .text((d) => d.title || stripExtension(d.path))
       .attr("dx", 12)
       .attr("dy", 4)
       .attr("class", styles.label);
@@ -1,6 +1,7 @@
 import { useRef, useEffect } from "preact/hooks";
 import * as d3 from "d3";
 import type { GraphNode, GraphEdge } from "../../types/api";
+import { stripExtension } from "../../lib/extensions";
 import styles from "./MiniGraph.module.css";
 
 interface Props {
@@ -146,7 +147,7 @@ export function MiniGraph({ centerPath, nodes, edges, onNodeClick }: Props) {
 
       // Label: position based on angle to avoid overlap with edges
       const label = truncate(
-        p.node.title || p.node.path.replace(/\.md$/, ""),
+        p.node.title || stripExtension(p.node.path),
         16,
       );
 
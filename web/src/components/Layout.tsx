@@ -10,13 +10,20 @@ import styles from "./Layout.module.css";
 interface Props {
   currentPath?: string;
   onSearch?: (query: string) => void;
+  /** Bumped to re-fetch the app config. */
+  configVersion?: number;
   children: ComponentChildren;
 }
 
-export function Layout({ currentPath, onSearch, children }: Props) {
+export function Layout({
+  currentPath,
+  onSearch,
+  configVersion,
+  children,
+}: Props) {
   const [sidebarOpen, setSidebarOpen] = useState(false);
   const { theme, toggleTheme } = useTheme();
-  const appConfig = useAppConfig();
+  const appConfig = useAppConfig(configVersion);
 
   return (
     <div class={styles.layout}>
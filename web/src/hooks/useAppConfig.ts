@@ -1,5 +1,6 @@
 import { useState, useEffect } from "preact/hooks";
 import { getConfig } from "../api/client";
+import { setExtensions } from "../lib/extensions";
 import type { AppConfig } from "../types/api";
 
 const DEFAULT_CONFIG: AppConfig = {
@@ -53,7 +54,11 @@ function applyFont(fontUrl: string, fontFamily: string) {
   }
 }
 
-export function useAppConfig() {
+/**
+ * Loads the app config, again whenever version changes (e.g. after a
+ * "config" WebSocket event).
+ */
+export function useAppConfig(version = 0) {
   const [config, setConfig] = useState<AppConfig>(DEFAULT_CONFIG);
 
   useEffect(() => {
@@ -63,11 +68,12 @@ export function useAppConfig() {
         applyAccent(cfg.theme);
         updateDocumentTitle(cfg.title);
         applyFont(cfg.font_url || "", cfg.font_family || "");
+        setExtensions(cfg.extensions);
       })
       .catch(() => {
         // Use defaults on error
       });
-  }, []);
+  }, [version]);
 
   return config;
 }
@@ -1,8 +1,66 @@
 import { useState, useEffect, useCallback, useRef } from "preact/hooks";
 
 export interface WSEvent {
-  type: "created" | "updated" | "deleted";
+  type:
+    | "created"
+    | "updated"
+    | "deleted"
+    | "renamed"
+    | "dir_deleted"
+    | "dir_renamed"
+    | "reindexed"
+    | "batch"
+    | "config"
+    | "index_progress"
+    | "index_complete";
+  /** Changed file or directory; empty for config and indexing events. */
   path: string;
+  /** Previous path, for "renamed" and "dir_renamed". */
+  from?: string;
+  /** New HEAD commit, for "reindexed". */
+  commit?: string;
+  /** Documents changed together, for "reindexed" and "batch". */
+  changes?: ChangeSet;
+  /** Initial index progress, for "index_progress". */
+  progress?: IndexProgress;
+}
+
+export interface IndexProgress {
+  phase: "scanning" | "indexing";
+  done: number;
+  total: number;
+}
+
+export interface ChangeSet {
+  created: string[];
+  updated: string[];
+  deleted: string[];
+}
+
+/** Returns the number of documents in a change set. */
+export function changeCount(changes: ChangeSet | undefined): number {
+  if (!changes) return 0;
+  return (
+    changes.created.length + changes.updated.length + changes.deleted.length
+  );
+}
+
+/**
+ * Returns where the document at docPath lives after a rename event, or
+ * undefined if the event doesn't move it.
+ */
+export function movedPath(
+  event: WSEvent,
+  docPath: string,
+): string | undefined {
+  if (!event.from) return undefined;
+  if (event.type === "renamed" && docPath === event.from) {
+    return event.path;
+  }
+  if (event.type === "dir_renamed" && docPath.startsWith(event.from + "/")) {
+    return event.path + docPath.slice(event.from.length);
+  }
+  return undefined;
 }
 
 interface UseWebSocketOptions {
@@ -0,0 +1,32 @@
+// Document file extensions accepted by the server, lower-case with a
+// leading dot. Updated from /api/v1/config by useAppConfig.
+let extensions: string[] = [".md"];
+
+export function setExtensions(exts: string[] | undefined): void {
+  extensions = exts && exts.length > 0 ? exts : [".md"];
+}
+
+/**
+ * Returns the longest configured extension path ends with, compared
+ * case-insensitively, or "" if none match. Multi-part extensions such as
+ * ".md.txt" are supported.
+ */
+export function matchExtension(path: string, exts = extensions): string {
+  const lower = path.toLowerCase();
+  let match = "";
+  for (const ext of exts) {
+    if (
+      ext.length > match.length &&
+      lower.length > ext.length &&
+      lower.endsWith(ext)
+    ) {
+      match = ext;
+    }
+  }
+  return match;
+}
+
+/** Returns path without its configured document extension. */
+export function stripExtension(path: string, exts = extensions): string {
+  return path.slice(0, path.length - matchExtension(path, exts).length);
+}
@@ -6,6 +6,7 @@ import {
 } from "marked";
 import { markedHighlight } from "marked-highlight";
 import hljs from "highlight.js/lib/core";
+import { stripExtension } from "./extensions";
 
 // --- Register highlight.js languages ---
 import go from "highlight.js/lib/languages/go";
@@ -110,15 +111,15 @@ const wikiLinkExtension: TokenizerExtension & RendererExtension = {
   tokenizer(src: string) {
     const match = src.match(/^\[\[([^\]|]+)(?:\|([^\]]+))?\]\]/);
     if (match) {
-      const rawPath = match[1].trim();
+      const path = match[1].trim();
       const label = match[2]?.trim();
-      // Ensure .md extension
-      const path = rawPath.endsWith(".md") ? rawPath : rawPath + ".md";
+      // The server resolves a path without an extension to the document
+      // with any configured one, so [[page]] finds page.mdx too.
       return {
         type: "wikiLink",
         raw: match[0],
         path,
-        label: label || rawPath.split("/").pop() || rawPath,
+        label: label || stripExtension(path.split("/").pop() || path),
       };
     }
     return undefined;
@@ -44,6 +44,11 @@ export function DocumentPage({ docPath }: Props) {
       .then(([docRes, graphRes]) => {
         if (cancelled) return;
         const raw = docRes as unknown as DocResponse;
+        if (raw.data.path !== docPath) {
+          // A [[page]] link resolved to the file with its extension.
+          route(`/docs/${raw.data.path}`, true);
+          return;
+        }
         setDoc(raw.data);
         setGitDates(raw.git_dates);
         setGraph(graphRes.data);
@@ -4,6 +4,7 @@ export interface TreeNode {
   path?: string;
   title?: string;
   tags?: string[];
+  git_status?: "clean" | "modified" | "staged" | "untracked";
   children?: TreeNode[];
 }
 
@@ -96,4 +97,5 @@ export interface AppConfig {
   font_url: string;
   font_family: string;
   tag_icons: TagIcon[];
+  extensions?: string[];
 }